@@ -0,0 +1,29 @@
+package main
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// followReader wraps a file and, instead of returning io.EOF, waits for new
+// data appended to the file (like `tail -f`)
+type followReader struct {
+	file     *os.File
+	interval time.Duration
+}
+
+// Read implements io.Reader and blocks on EOF until new data is available
+func (reader *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := reader.file.Read(p)
+		if err == io.EOF {
+			if n > 0 {
+				return n, nil
+			}
+			time.Sleep(reader.interval)
+			continue
+		}
+		return n, err
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/cha87de/tsprofiler/api"
 	"github.com/cha87de/tsprofiler/models"
 	"github.com/cha87de/tsprofiler/profiler"
 	flags "github.com/jessevdk/go-flags"
@@ -30,27 +33,47 @@ var options struct {
 	PhaseChangeLikeliness     float32 `long:"phasechangelikeliness" default:""`
 	PhaseChangeHistory        int64   `long:"phasechangehistory" default:"1"`
 	PhaseChangeHistoryFadeout bool    `long:"phasechangehistoryfadeout"`
+	PhaseDetector             string  `long:"phasedetector" default:"likeliness" choice:"likeliness" choice:"cusum" choice:"bocpd" description:"detector of phase changes, the likeliness detector requires phasechangelikeliness"`
+	PhaseCusumDrift           float32 `long:"phasecusumdrift" default:"0" description:"cusum detector: tolerated drop of likeliness per state, 0 for the default 0.05"`
+	PhaseCusumThreshold       float32 `long:"phasecusumthreshold" default:"0" description:"cusum detector: cumulative drop of likeliness to detect a change, 0 for the default 2"`
+	PhaseHazard               float32 `long:"phasehazard" default:"0" description:"bocpd detector: prior probability of a phase change per state, 0 for the default 0.01"`
+
+	ContextMinCount      int64   `long:"contextmincount" default:"0" description:"keep histories longer than one state counted at least this often, 0 for the default 2, negative to keep all histories unpruned and unsmoothed"`
+	ContextMinDivergence float32 `long:"contextmindivergence" default:"0" description:"keep histories longer than one state diverging at least this much from their shorter history (Jensen-Shannon divergence [0,1]), 0 for the default 0.01, negative for any"`
+
+	MaxPhases                  int     `long:"maxphases" default:"0" description:"max. amount of phases, the most similar phases are merged, 0 for no limit"`
+	PhaseMergeDivergence       float32 `long:"phasemergedivergence" default:"0" description:"merge phases diverging less (Jensen-Shannon divergence [0,1]), 0 to disable"`
+	PhaseMinCount              int64   `long:"phasemincount" default:"0" description:"prune phases counted less buffers, 0 to disable"`
+	PhaseConsolidationInterval int64   `long:"phaseconsolidationinterval" default:"0" description:"amount of buffers between phase consolidations, 0 to consolidate on new phases only"`
+
+	JointStates bool `long:"jointstates" description:"count the transitions of the joint states of all metrics"`
+	DwellTimes  bool `long:"dwelltimes" description:"record the dwell time histograms of the states and phases, for semi-markov simulations"`
 
 	Outputfile  string `long:"output" default:"-" description:"path to write profile to, stdout if '-'"`
+	Format      string `long:"format" default:"json" choice:"json" choice:"binary" description:"encoding of the written profile"`
 	Historyfile string `long:"out.history" default:"" description:"path to write last historic values to, stdout if '-', empty to disable"`
 	PhasesFile  string `long:"out.phases" default:""`
 	PeriodsFile string `long:"out.periods" default:""`
 	StatesFile  string `long:"out.states" default:""`
+	EventsFile  string `long:"out.events" default:"" description:"path to write phase change and period boundary events to as json lines, stdout if '-', empty to disable"`
+
+	Follow        bool          `long:"follow" description:"keep reading the input file as it grows, like tail -f"`
+	FlushRecords  int           `long:"flush.records" default:"0" description:"write profile and history every n records (0 to disable)"`
+	FlushInterval time.Duration `long:"flush.interval" default:"0" description:"write profile and history periodically, e.g. 30s (0 to disable)"`
 
 	Inputfile string
 }
 
-var tsprofiler api.TSProfiler
+var tsprofiler *profiler.Profiler
 var phasesfile *os.File
 var periodsfile *os.File
 var statesfile *os.File
+var eventsfile *os.File
+var outputAccess = &sync.Mutex{}
 
 func main() {
 	initializeFlags()
 
-	// create new ts profiler
-	initProfiler()
-
 	// create & open output file
 	if options.PhasesFile != "" && options.PhasesFile != "-" {
 		var err error
@@ -76,18 +99,59 @@ func main() {
 		}
 		defer statesfile.Close()
 	}
+	if options.EventsFile != "" && options.EventsFile != "-" {
+		var err error
+		eventsfile, err = os.OpenFile(options.EventsFile, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer eventsfile.Close()
+	}
+
+	// create new ts profiler
+	initProfiler()
+
+	// write profile and history on termination and while reading, also
+	// when reading from stdin
+	handleSignals()
+	if options.FlushInterval > 0 {
+		go flushPeriodically(options.FlushInterval)
+	}
 
 	// read file line by line
 	readFile(options.Inputfile)
 
-	// get and print profile
+	// get and print profile, print last states and positions
+	flush()
+
+}
+
+// flush writes the current profile and history to the configured outputs
+func flush() {
+	outputAccess.Lock()
+	defer outputAccess.Unlock()
+	tsprofiler.Flush()
 	outputProfile()
+	outputHistory()
+}
 
-	// print last states and positions
-	if options.Historyfile != "" {
-		outputHistory()
+// flushPeriodically calls flush every interval
+func flushPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		flush()
 	}
+}
 
+// handleSignals flushes the outputs and exits when SIGINT or SIGTERM is received
+func handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		flush()
+		os.Exit(0)
+	}()
 }
 
 func initializeFlags() {
@@ -117,6 +181,11 @@ func initializeFlags() {
 		os.Exit(1)
 	}
 	options.Inputfile = args[0]
+
+	if options.Follow && options.Inputfile == "-" {
+		fmt.Fprintf(os.Stderr, "follow mode is ignored when reading from stdin.\n")
+		options.Follow = false
+	}
 }
 
 func initProfiler() {
@@ -131,29 +200,60 @@ func initProfiler() {
 		periodSize = append(periodSize, si)
 	}
 
+	var eventCallback func(event models.Event)
+	if options.EventsFile != "" {
+		eventCallback = outputEvent
+	}
+
 	// create new profiler
 	tsprofiler = profiler.NewProfiler(models.Settings{
-		Name:                      "csv2tsprofile",
-		BufferSize:                options.BufferSize,
-		States:                    options.States,
-		FilterStdDevs:             options.FilterStdDevs,
-		History:                   options.History,
-		FixBound:                  options.FixedBound,
-		PeriodSize:                periodSize,
-		PhaseChangeLikeliness:     options.PhaseChangeLikeliness,
-		PhaseChangeHistory:        options.PhaseChangeHistory,
-		PhaseChangeHistoryFadeout: options.PhaseChangeHistoryFadeout,
+		Name:                       "csv2tsprofile",
+		BufferSize:                 options.BufferSize,
+		States:                     options.States,
+		FilterStdDevs:              options.FilterStdDevs,
+		History:                    options.History,
+		ContextMinCount:            options.ContextMinCount,
+		ContextMinDivergence:       options.ContextMinDivergence,
+		FixBound:                   options.FixedBound,
+		PeriodSize:                 periodSize,
+		PhaseChangeLikeliness:      options.PhaseChangeLikeliness,
+		PhaseChangeHistory:         options.PhaseChangeHistory,
+		PhaseChangeHistoryFadeout:  options.PhaseChangeHistoryFadeout,
+		PhaseDetector:              models.PhaseDetector(options.PhaseDetector),
+		PhaseCusumDrift:            options.PhaseCusumDrift,
+		PhaseCusumThreshold:        options.PhaseCusumThreshold,
+		PhaseHazard:                options.PhaseHazard,
+		MaxPhases:                  options.MaxPhases,
+		PhaseMergeDivergence:       options.PhaseMergeDivergence,
+		PhaseMinCount:              options.PhaseMinCount,
+		PhaseConsolidationInterval: options.PhaseConsolidationInterval,
+		JointStates:                options.JointStates,
+		DwellTimes:                 options.DwellTimes,
+		EventCallback:              eventCallback,
 	})
 }
 
 func readFile(filename string) {
-	file, err := os.Open(filename)
-	if err != nil {
-		log.Fatal(err)
+	var input io.Reader
+	if filename == "-" {
+		input = os.Stdin
+	} else {
+		file, err := os.Open(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		input = file
+		if options.Follow {
+			input = &followReader{
+				file:     file,
+				interval: time.Duration(500) * time.Millisecond,
+			}
+		}
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
+	reader := csv.NewReader(input)
+	records := 0
 	for {
 		record, err := reader.Read()
 		if err == io.EOF {
@@ -172,6 +272,11 @@ func readFile(filename string) {
 			utilValues = append(utilValues, utilValue)
 		}
 		putMeasurement(utilValues)
+
+		records++
+		if options.FlushRecords > 0 && records%options.FlushRecords == 0 {
+			flush()
+		}
 	}
 
 }
@@ -190,6 +295,10 @@ func putMeasurement(utilValue []float64) {
 		Metrics: metrics,
 	}
 	tsprofiler.Put(tsinput)
+	if options.PhasesFile == "" && options.PeriodsFile == "" && options.StatesFile == "" {
+		return
+	}
+	tsprofiler.Flush()
 
 	// print phases
 	phaseid := tsprofiler.GetCurrentPhase()
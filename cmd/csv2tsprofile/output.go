@@ -3,9 +3,11 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"log"
+	"os"
 
 	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/utils"
 )
 
 func outputHistory() {
@@ -21,6 +23,7 @@ func outputHistory() {
 	}
 
 	history := models.History{
+		Version:        models.HistoryVersion,
 		CurrentPhase:   tsprofiler.GetCurrentPhase(),
 		PeriodPath:     tsprofiler.GetCurrentPeriodPath(),
 		HistoricStates: historicStates,
@@ -38,7 +41,7 @@ func outputHistory() {
 		fmt.Printf("%s\n", json)
 	} else {
 		// write to file
-		err := ioutil.WriteFile(options.Historyfile, json, 0644)
+		err := utils.WriteFileAtomic(options.Historyfile, json, 0644)
 		if err != nil {
 			fmt.Printf("cannot write json to file %s: %s\n", options.Historyfile, err)
 			return
@@ -48,21 +51,42 @@ func outputHistory() {
 
 func outputProfile() {
 	profile := tsprofiler.Get()
-	json, err := json.Marshal(profile)
+	format := models.ProfileFormat(options.Format)
+	data, err := profile.Marshal(format)
 	if err != nil {
-		fmt.Printf("cannot create json: %s (original: %+v)\n", err, profile)
+		fmt.Printf("cannot create %s: %s (original: %+v)\n", format, err, profile)
 		return
 	}
 
 	if options.Outputfile == "-" {
 		// print to stdout
-		fmt.Printf("%s\n", json)
+		if format == models.ProfileFormatJSON {
+			fmt.Printf("%s\n", data)
+		} else {
+			os.Stdout.Write(data)
+		}
 	} else {
 		// write to file
-		err := ioutil.WriteFile(options.Outputfile, json, 0644)
+		err := utils.WriteFileAtomic(options.Outputfile, data, 0644)
 		if err != nil {
 			fmt.Printf("cannot write json to file %s: %s\n", options.Outputfile, err)
 			return
 		}
 	}
 }
+
+// outputEvent writes a profiler event as json line
+func outputEvent(event models.Event) {
+	json, err := json.Marshal(event)
+	if err != nil {
+		fmt.Printf("cannot create json: %s (original: %+v)\n", err, event)
+		return
+	}
+	row := fmt.Sprintf("%s\n", json)
+	if options.EventsFile == "-" {
+		// use stdout
+		fmt.Print(row)
+	} else if _, err := eventsfile.Write([]byte(row)); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cha87de/tsprofiler/cmd/tspredictor/task"
 
@@ -18,29 +21,87 @@ var options struct {
 	Mode        predictor.PredictionMode `long:"mode" default:"0"`
 	PeriodDepth int                      `long:"periodDepth" default:"0"`
 	Profilefile string                   `long:"profile" short:"p"`
+	Format      string                   `long:"format" default:"auto" choice:"auto" choice:"json" choice:"binary" description:"encoding of the profile file"`
 	Historyfile string                   `long:"history" short:"h"`
+	Intervals   string                   `long:"intervals" default:"80,95" description:"comma separated list of forecast interval coverages in percent"`
+	Runs        int                      `long:"runs" default:"1" description:"amount of simulations to aggregate, a single simulation is printed as is"`
+	Percentiles string                   `long:"percentiles" default:"50,90,99" description:"comma separated list of percentiles of aggregated simulations"`
+	Seed        int64                    `long:"seed" default:"0" description:"seed of simulations, 0 for a random seed"`
+	Output      string                   `long:"output" default:"csv" choice:"csv" choice:"json" description:"output format of forecast, aggregated simulations and scores"`
+	Inputfile   string                   `long:"input" short:"i" description:"csv file of observations to score, one value per metric and row"`
+	Threshold   float64                  `long:"threshold" default:"0.05" description:"likeliness below which scored observations are flagged as anomaly"`
+	FixedMin    float64                  `long:"fixedmin" default:"0" description:"if the profile has fixed bounds, the min value to discretize scored observations with"`
+	FixedMax    float64                  `long:"fixedmax" default:"100" description:"if the profile has fixed bounds, the max value to discretize scored observations with"`
 	Task        string
 }
 
 func main() {
 	initializeFlags()
 
-	profile := utils.ReadProfileFromFile(options.Profilefile)
-	history := models.ReadHistoryFromFile(options.Historyfile)
+	profile, err := utils.ReadProfileFromFileFormat(options.Profilefile, models.ProfileFormat(options.Format))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot read profile %s: %s\n", options.Profilefile, err)
+		os.Exit(1)
+	}
+	var history models.History
+	if options.Task != "score" || options.Historyfile != "" {
+		// the score task starts without history if none is given
+		history, err = models.ReadHistoryFromFile(options.Historyfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot read history %s: %s\n", options.Historyfile, err)
+			os.Exit(1)
+		}
+		if err := history.Validate(&profile); err != nil {
+			fmt.Fprintf(os.Stderr, "history %s does not match profile %s: %s\n", options.Historyfile, options.Profilefile, err)
+			os.Exit(1)
+		}
+	}
 
-	var err error
+	seed := options.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
 
 	switch options.Task {
 	case "simulate":
-		simulate := task.NewSimulate(profile, options.Mode, history)
+		if options.Runs > 1 {
+			percentiles, parseErr := parsePercentages(options.Percentiles)
+			if parseErr != nil {
+				fmt.Fprintf(os.Stderr, "invalid percentiles %s: %s\n", options.Percentiles, parseErr)
+				os.Exit(1)
+			}
+			ensemble := task.NewEnsemble(profile, options.Mode, history, options.Runs, percentiles, seed, options.Output)
+			err = ensemble.Run(options.Steps, options.PeriodDepth)
+			ensemble.Print()
+			break
+		}
+		simulate := task.NewSimulate(profile, options.Mode, history, seed)
 		err = simulate.Run(options.Steps, options.PeriodDepth)
 		simulate.Print()
 	case "likeliness":
 		likeliness := task.NewLikeliness(profile, options.Mode, history)
 		err = likeliness.Run(options.Steps, options.PeriodDepth)
 		likeliness.Print()
+	case "forecast":
+		coverages, parseErr := parsePercentages(options.Intervals)
+		if parseErr != nil {
+			fmt.Fprintf(os.Stderr, "invalid intervals %s: %s\n", options.Intervals, parseErr)
+			os.Exit(1)
+		}
+		forecast := task.NewForecast(profile, options.Mode, history, coverages, options.Output)
+		err = forecast.Run(options.Steps, options.PeriodDepth)
+		forecast.Print()
+	case "score":
+		rows, readErr := readRows(options.Inputfile)
+		if readErr != nil {
+			fmt.Fprintf(os.Stderr, "cannot read input %s: %s\n", options.Inputfile, readErr)
+			os.Exit(1)
+		}
+		score := task.NewScore(profile, options.Mode, history, rows, options.FixedMin, options.FixedMax, options.Threshold, options.Output)
+		err = score.Run(options.PeriodDepth)
+		score.Print()
 	default:
-		fmt.Printf("task %s unknown. Select \"simulate\" or \"likeliness\" as task.", options.Task)
+		fmt.Printf("task %s unknown. Select \"simulate\", \"likeliness\", \"forecast\" or \"score\" as task.", options.Task)
 	}
 
 	if err != nil {
@@ -50,11 +111,43 @@ func main() {
 	os.Exit(0)
 }
 
+// readRows reads the observations to score from csv file or stdin ("-")
+func readRows(filename string) ([][]float64, error) {
+	var input io.Reader
+	if filename == "-" {
+		input = os.Stdin
+	} else {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+	return utils.ReadCSV(input)
+}
+
+// parsePercentages converts the comma separated list of percentages to [0,1]
+func parsePercentages(percentages string) ([]float64, error) {
+	values := make([]float64, 0)
+	for _, s := range strings.Split(percentages, ",") {
+		if s == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value/100)
+	}
+	return values, nil
+}
+
 func initializeFlags() {
 	// initialize parser for flags
 	parser := flags.NewParser(&options, flags.Default)
 	parser.ShortDescription = "tspredictor"
-	parser.LongDescription = "Reads a TSProfile from file and runs tasks on in (Simulate or Likeliness)"
+	parser.LongDescription = "Reads a TSProfile from file and runs tasks on in (Simulate, Likeliness, Forecast or Score)"
 	parser.ArgsRequired = true
 
 	// Parse parameters
@@ -73,7 +166,7 @@ func initializeFlags() {
 	}
 
 	if len(args) < 1 {
-		fmt.Printf("No task specified. Select \"simulate\" or \"likeliness\" as task.\n")
+		fmt.Printf("No task specified. Select \"simulate\", \"likeliness\", \"forecast\" or \"score\" as task.\n")
 		os.Exit(1)
 	}
 	options.Task = strings.ToLower(args[0])
@@ -0,0 +1,87 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/predictor"
+)
+
+// Ensemble represents the ensemble simulation task of tspredictor
+type Ensemble struct {
+	profile     models.TSProfile
+	mode        predictor.PredictionMode
+	history     models.History
+	runs        int
+	percentiles []float64
+	seed        int64
+	output      string
+	ensemble    map[string][]predictor.EnsembleStep
+}
+
+// NewEnsemble creates and returns a new Ensemble task, aggregating `runs`
+// simulations to the mean and the given `percentiles`
+func NewEnsemble(profile models.TSProfile, mode predictor.PredictionMode, history models.History, runs int, percentiles []float64, seed int64, output string) *Ensemble {
+	return &Ensemble{
+		profile:     profile,
+		mode:        mode,
+		history:     history,
+		runs:        runs,
+		percentiles: percentiles,
+		seed:        seed,
+		output:      output,
+	}
+}
+
+// Run simulates given amount of steps `runs` times
+func (ensemble *Ensemble) Run(steps int, periodDepth int) error {
+	if ensemble.output != "csv" && ensemble.output != "json" {
+		return fmt.Errorf("output %s unknown. Select \"csv\" or \"json\".\n", ensemble.output)
+	}
+	predictor := createPredictor(ensemble.profile, ensemble.mode, ensemble.history, periodDepth)
+	var err error
+	ensemble.ensemble, err = predictor.Ensemble(steps, ensemble.runs, ensemble.percentiles, ensemble.seed)
+	return err
+}
+
+// Print prints the aggregated simulations to stdout, as csv one row per step and metric
+func (ensemble *Ensemble) Print() {
+	if ensemble.output == "json" {
+		data, err := json.MarshalIndent(ensemble.ensemble, "", "  ")
+		if err != nil {
+			fmt.Printf("cannot create json: %s\n", err)
+			return
+		}
+		fmt.Printf("%s\n", data)
+		return
+	}
+
+	metrics := make([]string, 0, len(ensemble.ensemble))
+	for metric := range ensemble.ensemble {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+	if len(metrics) <= 0 || len(ensemble.ensemble[metrics[0]]) <= 0 {
+		return
+	}
+
+	// print header
+	fmt.Printf("step,metric,runs,mean")
+	for _, percentile := range ensemble.ensemble[metrics[0]][0].Percentiles {
+		fmt.Printf(",p%g", percentile.Percentile*100)
+	}
+	fmt.Printf("\n")
+
+	// print rows
+	for _, metric := range metrics {
+		for step, ensembleStep := range ensemble.ensemble[metric] {
+			fmt.Printf("%d,%s,%d,%.2f", step+1, metric, ensembleStep.Runs, ensembleStep.Mean)
+			for _, percentile := range ensembleStep.Percentiles {
+				fmt.Printf(",%.2f", percentile.Value)
+			}
+			fmt.Printf("\n")
+		}
+	}
+}
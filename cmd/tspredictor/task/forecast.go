@@ -0,0 +1,83 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/predictor"
+)
+
+// Forecast represents the forecast task of tspredictor
+type Forecast struct {
+	profile   models.TSProfile
+	mode      predictor.PredictionMode
+	history   models.History
+	coverages []float64
+	output    string
+	forecast  map[string][]predictor.ForecastStep
+}
+
+// NewForecast creates and returns a new Forecast task, computing prediction
+// intervals for the given `coverages` and printing them as csv or json
+func NewForecast(profile models.TSProfile, mode predictor.PredictionMode, history models.History, coverages []float64, output string) *Forecast {
+	return &Forecast{
+		profile:   profile,
+		mode:      mode,
+		history:   history,
+		coverages: coverages,
+		output:    output,
+	}
+}
+
+// Run forecasts given amount of steps
+func (forecast *Forecast) Run(steps int, periodDepth int) error {
+	if forecast.output != "csv" && forecast.output != "json" {
+		return fmt.Errorf("output %s unknown. Select \"csv\" or \"json\".\n", forecast.output)
+	}
+	predictor := createPredictor(forecast.profile, forecast.mode, forecast.history, periodDepth)
+	var err error
+	forecast.forecast, err = predictor.Forecast(steps, forecast.coverages)
+	return err
+}
+
+// Print prints the forecast to stdout, as csv one row per step and metric
+func (forecast *Forecast) Print() {
+	if forecast.output == "json" {
+		data, err := json.MarshalIndent(forecast.forecast, "", "  ")
+		if err != nil {
+			fmt.Printf("cannot create json: %s\n", err)
+			return
+		}
+		fmt.Printf("%s\n", data)
+		return
+	}
+
+	metrics := make([]string, 0, len(forecast.forecast))
+	for metric := range forecast.forecast {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+	if len(metrics) <= 0 || len(forecast.forecast[metrics[0]]) <= 0 {
+		return
+	}
+
+	// print header
+	fmt.Printf("step,metric,expected,median")
+	for _, interval := range forecast.forecast[metrics[0]][0].Intervals {
+		fmt.Printf(",lower%g,upper%g", interval.Coverage*100, interval.Coverage*100)
+	}
+	fmt.Printf("\n")
+
+	// print rows
+	for _, metric := range metrics {
+		for step, forecastStep := range forecast.forecast[metric] {
+			fmt.Printf("%d,%s,%.2f,%.2f", step+1, metric, forecastStep.Expected, forecastStep.Median)
+			for _, interval := range forecastStep.Intervals {
+				fmt.Printf(",%.2f,%.2f", interval.Lower, interval.Upper)
+			}
+			fmt.Printf("\n")
+		}
+	}
+}
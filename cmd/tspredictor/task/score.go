@@ -0,0 +1,198 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/predictor"
+	"github.com/cha87de/tsprofiler/utils"
+)
+
+// Score represents the anomaly scoring task of tspredictor
+type Score struct {
+	profile   models.TSProfile
+	mode      predictor.PredictionMode
+	history   models.History
+	rows      [][]float64
+	fixedMin  float64
+	fixedMax  float64
+	threshold float64
+	output    string
+	scores    []ScoreStep
+}
+
+// ScoreStep is the likeliness of a discretized buffer of rows
+type ScoreStep struct {
+	// FirstRow and LastRow are the indices of the buffer's rows, starting with 0
+	FirstRow int `json:"firstRow"`
+	LastRow  int `json:"lastRow"`
+
+	States map[string]int `json:"states"`
+
+	// Likeliness holds per metric the likeliness [0,1] of the transition to
+	// the state, missing if no previous state is known
+	Likeliness map[string]float64 `json:"likeliness,omitempty"`
+
+	// Mean is the mean likeliness of all metrics
+	Mean float64 `json:"mean"`
+
+	// Anomaly is set if Mean is below the threshold
+	Anomaly bool `json:"anomaly"`
+}
+
+// NewScore creates and returns a new Score task, scoring the `rows` (one value
+// per metric metric_0, metric_1, ...) after the states of `history` (if any)
+// and flagging buffers with a likeliness below `threshold`. The rows are
+// discretized between `fixedMin` and `fixedMax` if the profile has fixed
+// bounds, i.e. like the profiler discretized the profiled values.
+func NewScore(profile models.TSProfile, mode predictor.PredictionMode, history models.History, rows [][]float64, fixedMin float64, fixedMax float64, threshold float64, output string) *Score {
+	return &Score{
+		profile:   profile,
+		mode:      mode,
+		history:   history,
+		rows:      rows,
+		fixedMin:  fixedMin,
+		fixedMax:  fixedMax,
+		threshold: threshold,
+		output:    output,
+		scores:    make([]ScoreStep, 0),
+	}
+}
+
+// Run discretizes the rows buffer by buffer with the profile's settings and
+// fixed bounds resp. root tx stats, and computes the likeliness of each buffer's states under the
+// prediction mode, moving on the predictor by the observed states
+func (score *Score) Run(periodDepth int) error {
+	if score.output != "csv" && score.output != "json" {
+		return fmt.Errorf("output %s unknown. Select \"csv\" or \"json\".\n", score.output)
+	}
+	bufferSize := score.profile.Settings.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+
+	var predictorInstance *predictor.Predictor
+	scored := len(score.history.HistoricStates) > 0
+	if scored {
+		predictorInstance = createPredictor(score.profile, score.mode, score.history, periodDepth)
+	} else {
+		// start at the beginning of the period tree, with the first buffer as state
+		predictorInstance = predictor.NewPredictor(score.profile)
+		predictorInstance.SetMode(score.mode)
+		predictorInstance.SetPeriodPath(make([]int, len(score.profile.Settings.PeriodSize)), periodDepth)
+	}
+
+	for first := 0; first+bufferSize <= len(score.rows); first += bufferSize {
+		states, err := score.discretize(score.rows[first : first+bufferSize])
+		if err != nil {
+			return fmt.Errorf("cannot discretize rows %d to %d: %s", first, first+bufferSize-1, err)
+		}
+		step := ScoreStep{
+			FirstRow: first,
+			LastRow:  first + bufferSize - 1,
+			States:   states,
+		}
+		if scored {
+			step.Likeliness, err = predictorInstance.ObservedLikeliness(states)
+			if err != nil {
+				return err
+			}
+			for _, likeliness := range step.Likeliness {
+				step.Mean += likeliness / float64(len(step.Likeliness))
+			}
+			step.Anomaly = len(step.Likeliness) > 0 && step.Mean < score.threshold
+			if err := predictorInstance.Observe(states); err != nil {
+				return err
+			}
+		} else {
+			// without history, the first buffer is the initial state
+			stateHistory := make(map[string]string)
+			for metric, state := range states {
+				stateHistory[metric] = fmt.Sprintf("%d", state)
+			}
+			predictorInstance.SetState(stateHistory)
+			scored = true
+		}
+		score.scores = append(score.scores, step)
+	}
+	return nil
+}
+
+// discretize computes the state of each metric from the average of the rows
+func (score *Score) discretize(rows [][]float64) (map[string]int, error) {
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, row := range rows {
+		for i, value := range row {
+			metric := fmt.Sprintf("metric_%d", i)
+			sums[metric] += value
+			counts[metric]++
+		}
+	}
+	states := make(map[string]int)
+	for metric, sum := range sums {
+		var stats *models.TSStats
+		for i := range score.profile.RootTx {
+			if score.profile.RootTx[i].Metric == metric {
+				stats = &score.profile.RootTx[i].Stats
+			}
+		}
+		if stats == nil {
+			return nil, fmt.Errorf("metric %s not found in profile", metric)
+		}
+		min, max := stats.Min, stats.Max
+		if score.profile.Settings.FixBound {
+			min, max = score.fixedMin, score.fixedMax
+		}
+		state := utils.ClosestDiscretize(sum/float64(counts[metric]), score.profile.Settings.States, min, max)
+		states[metric] = int(state.Value)
+	}
+	return states, nil
+}
+
+// Print prints the scores to stdout, as csv one row per buffer
+func (score *Score) Print() {
+	if score.output == "json" {
+		data, err := json.MarshalIndent(score.scores, "", "  ")
+		if err != nil {
+			fmt.Printf("cannot create json: %s\n", err)
+			return
+		}
+		fmt.Printf("%s\n", data)
+		return
+	}
+
+	if len(score.scores) <= 0 {
+		return
+	}
+	metrics := make([]string, 0, len(score.scores[0].States))
+	for metric := range score.scores[0].States {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	// print header
+	fmt.Printf("firstrow,lastrow")
+	for _, metric := range metrics {
+		fmt.Printf(",%s_state,%s_likeliness", metric, metric)
+	}
+	fmt.Printf(",likeliness,anomaly\n")
+
+	// print rows, unscored likeliness left empty
+	for _, step := range score.scores {
+		fmt.Printf("%d,%d", step.FirstRow, step.LastRow)
+		for _, metric := range metrics {
+			fmt.Printf(",%d,", step.States[metric])
+			if likeliness, exists := step.Likeliness[metric]; exists {
+				fmt.Printf("%.4f", likeliness)
+			}
+		}
+		fmt.Printf(",")
+		if len(step.Likeliness) > 0 {
+			fmt.Printf("%.4f", step.Mean)
+		}
+		fmt.Printf(",%t\n", step.Anomaly)
+	}
+}
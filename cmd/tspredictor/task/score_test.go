@@ -0,0 +1,114 @@
+package task
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/predictor"
+	"github.com/cha87de/tsprofiler/profiler"
+	"github.com/cha87de/tsprofiler/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// trainCSV alternates metric_0 between 90 and 10, metric_1 is constant. The
+// profiler starts in state 0, i.e. as if 10 was before.
+const trainCSV = `metric_0,metric_1
+90,50
+10,50
+90,50
+10,50
+90,50
+10,50
+90,50
+10,50
+`
+
+// scoreCSV breaks the alternation of metric_0 with 40 in the last row
+const scoreCSV = `metric_0,metric_1
+10,50
+90,50
+10,50
+40,50
+`
+
+// readCSV returns the rows of a CSV string
+func readCSV(input string) [][]float64 {
+	rows, err := utils.ReadCSV(strings.NewReader(input))
+	So(err, ShouldBeNil)
+	return rows
+}
+
+// trainProfile profiles the training rows with 4 states between 0 and 100
+func trainProfile() models.TSProfile {
+	tsprofiler := profiler.NewProfiler(models.Settings{
+		Name:          "score",
+		BufferSize:    1,
+		States:        4,
+		History:       1,
+		FilterStdDevs: 4,
+		FixBound:      true,
+	})
+	defer tsprofiler.Terminate()
+	for _, row := range readCSV(trainCSV) {
+		metrics := make([]models.TSInputMetric, len(row))
+		for i, value := range row {
+			metrics[i] = models.TSInputMetric{
+				Name:     []string{"metric_0", "metric_1"}[i],
+				Value:    value,
+				FixedMin: 0,
+				FixedMax: 100,
+			}
+		}
+		tsprofiler.Put(models.TSInput{Metrics: metrics})
+	}
+	tsprofiler.Flush()
+	return tsprofiler.Get()
+}
+
+func TestScore(t *testing.T) {
+	Convey("Should score the states of the observations", t, func() {
+		score := NewScore(trainProfile(), predictor.PredictionModeRootTx, models.History{}, readCSV(scoreCSV), 0, 100, 0.6, "json")
+		So(score.Run(0), ShouldBeNil)
+		So(score.scores, ShouldHaveLength, 4)
+
+		// the first buffer is the initial state
+		So(score.scores[0].States, ShouldResemble, map[string]int{"metric_0": 0, "metric_1": 2})
+		So(score.scores[0].Likeliness, ShouldBeEmpty)
+		So(score.scores[0].Anomaly, ShouldBeFalse)
+
+		So(score.scores[1].FirstRow, ShouldEqual, 1)
+		So(score.scores[1].States, ShouldResemble, map[string]int{"metric_0": 3, "metric_1": 2})
+		So(score.scores[1].Likeliness, ShouldResemble, map[string]float64{"metric_0": 1, "metric_1": 1})
+		So(score.scores[1].Mean, ShouldAlmostEqual, 1)
+		So(score.scores[1].Anomaly, ShouldBeFalse)
+
+		So(score.scores[3].States, ShouldResemble, map[string]int{"metric_0": 2, "metric_1": 2})
+		So(score.scores[3].Likeliness, ShouldResemble, map[string]float64{"metric_0": 0, "metric_1": 1})
+		So(score.scores[3].Mean, ShouldAlmostEqual, 0.5)
+		So(score.scores[3].Anomaly, ShouldBeTrue)
+	})
+
+	Convey("Should flag buffers below the threshold only", t, func() {
+		score := NewScore(trainProfile(), predictor.PredictionModeRootTx, models.History{}, readCSV(scoreCSV), 0, 100, 0.05, "csv")
+		So(score.Run(0), ShouldBeNil)
+		for _, step := range score.scores {
+			So(step.Anomaly, ShouldBeFalse)
+		}
+	})
+
+	Convey("Should discretize between the fixed bounds of the profile", t, func() {
+		score := NewScore(trainProfile(), predictor.PredictionModeRootTx, models.History{}, readCSV(scoreCSV), 0, 200, 0.6, "json")
+		So(score.Run(0), ShouldBeNil)
+		// 90 of [0,200] is state 2, never seen after state 0
+		So(score.scores[1].States["metric_0"], ShouldEqual, 2)
+		So(score.scores[1].Likeliness["metric_0"], ShouldEqual, 0)
+		So(score.scores[1].Anomaly, ShouldBeTrue)
+	})
+
+	Convey("Should reject unknown outputs and metrics", t, func() {
+		So(NewScore(trainProfile(), predictor.PredictionModeRootTx, models.History{}, nil, 0, 100, 0.05, "xml").Run(0), ShouldNotBeNil)
+		rows := [][]float64{{10, 50, 1}}
+		So(NewScore(trainProfile(), predictor.PredictionModeRootTx, models.History{}, rows, 0, 100, 0.05, "csv").Run(0), ShouldNotBeNil)
+	})
+}
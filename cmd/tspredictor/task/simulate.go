@@ -2,6 +2,7 @@ package task
 
 import (
 	"fmt"
+	"math/rand"
 
 	"github.com/cha87de/tsprofiler/models"
 	"github.com/cha87de/tsprofiler/predictor"
@@ -13,22 +14,25 @@ type Simulate struct {
 	mode       predictor.PredictionMode
 	simulation [][]models.TSState
 	history    models.History
+	seed       int64
 	//startStep  map[string]string
 }
 
-// NewSimulate creates and returns a new Simulate task
-func NewSimulate(profile models.TSProfile, mode predictor.PredictionMode, history models.History) *Simulate {
+// NewSimulate creates and returns a new Simulate task, drawing random numbers seeded with `seed`
+func NewSimulate(profile models.TSProfile, mode predictor.PredictionMode, history models.History, seed int64) *Simulate {
 	return &Simulate{
 		profile:    profile,
 		mode:       mode,
 		simulation: make([][]models.TSState, 0),
 		history:    history,
+		seed:       seed,
 	}
 }
 
 // Run simulates given amount of steps
 func (simulate *Simulate) Run(steps int, periodDepth int) error {
 	predictor := createPredictor(simulate.profile, simulate.mode, simulate.history, periodDepth)
+	predictor.SetRandom(rand.New(rand.NewSource(simulate.seed)))
 	var err error
 	simulate.simulation, err = predictor.Simulate(steps)
 	if err != nil {
@@ -58,7 +62,7 @@ func (simulate *Simulate) Print() {
 			if i > 0 {
 				fmt.Printf(",")
 			}
-			fmt.Printf("%d", tsstate.State.Value)
+			fmt.Printf("%.2f", tsstate.Value)
 		}
 		fmt.Printf("\n")
 	}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/utils"
+	flags "github.com/jessevdk/go-flags"
+)
+
+var options struct {
+	Format string `long:"format" default:"auto" choice:"auto" choice:"json" choice:"binary" description:"encoding of the profile files"`
+	Output string `long:"output" default:"text" choice:"text" choice:"json" description:"output format of the diff"`
+	Top    int    `long:"top" default:"10" description:"amount of most changed period nodes and transitions to list"`
+
+	Localfile  string
+	Remotefile string
+}
+
+func main() {
+	initializeFlags()
+
+	local := readProfile(options.Localfile)
+	remote := readProfile(options.Remotefile)
+	diff := local.Diff(remote)
+
+	if options.Output == "json" {
+		outputJSON(diff)
+	} else {
+		outputText(local, remote, diff)
+	}
+}
+
+func readProfile(filepath string) models.TSProfile {
+	profile, err := utils.ReadProfileFromFileFormat(filepath, models.ProfileFormat(options.Format))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot read profile %s: %s\n", filepath, err)
+		os.Exit(1)
+	}
+	return profile
+}
+
+func initializeFlags() {
+	// initialize parser for flags
+	parser := flags.NewParser(&options, flags.Default)
+	parser.ShortDescription = "tsprofile-diff"
+	parser.LongDescription = "Compares two TSProfiles and lists the period nodes and transitions that changed most"
+	parser.ArgsRequired = true
+
+	// Parse parameters
+	args, err := parser.Parse()
+	if err != nil {
+		code := 1
+		if fe, ok := err.(*flags.Error); ok {
+			if fe.Type == flags.ErrHelp {
+				code = 0
+			}
+		}
+		if code != 0 {
+			fmt.Printf("Error parsing flags: %s", err)
+		}
+		os.Exit(code)
+	}
+
+	if len(args) < 2 {
+		fmt.Printf("Two profiles required: local and remote profile.\n")
+		os.Exit(1)
+	}
+	options.Localfile = args[0]
+	options.Remotefile = args[1]
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cha87de/tsprofiler/models"
+)
+
+// scopedRow is a row divergence with the matrix it belongs to
+type scopedRow struct {
+	scope  string
+	metric string
+	row    models.RowDivergence
+}
+
+func outputJSON(diff models.ProfileDiff) {
+	json, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		fmt.Printf("cannot create json: %s\n", err)
+		return
+	}
+	fmt.Printf("%s\n", json)
+}
+
+func outputText(local models.TSProfile, remote models.TSProfile, diff models.ProfileDiff) {
+	rows := make([]scopedRow, 0)
+
+	fmt.Printf("root tx\n")
+	for _, divergence := range diff.RootTx {
+		printDivergence("  ", divergence)
+		rows = appendRows(rows, "root", divergence)
+	}
+
+	fmt.Printf("\nphases (local %d, remote %d)\n", len(local.Phases.Phases), len(remote.Phases.Phases))
+	for _, phase := range diff.Phases {
+		if phase.OnlyIn != "" {
+			fmt.Printf("  phase %d only in %s profile\n", phase.Phase, phase.OnlyIn)
+			continue
+		}
+		for _, divergence := range phase.Metrics {
+			printDivergence(fmt.Sprintf("  phase %d ", phase.Phase), divergence)
+			rows = appendRows(rows, fmt.Sprintf("phase %d", phase.Phase), divergence)
+		}
+	}
+	printDivergence("  phase tx ", diff.PhaseTx)
+	rows = appendRows(rows, "phase tx", diff.PhaseTx)
+
+	// period nodes, ordered by their most divergent metric
+	nodes := make([]models.PeriodNodeDivergence, len(diff.PeriodNodes))
+	copy(nodes, diff.PeriodNodes)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return maxTotalVariation(nodes[i]) > maxTotalVariation(nodes[j])
+	})
+	fmt.Printf("\nperiod nodes changed most\n")
+	for i, node := range nodes {
+		if i >= options.Top {
+			break
+		}
+		for _, divergence := range node.Metrics {
+			printDivergence(fmt.Sprintf("  %s ", pathString(node.Path)), divergence)
+		}
+	}
+	for _, node := range diff.PeriodNodes {
+		for _, divergence := range node.Metrics {
+			rows = appendRows(rows, "period "+pathString(node.Path), divergence)
+		}
+	}
+
+	// transitions
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].row.TotalVariation != rows[j].row.TotalVariation {
+			return rows[i].row.TotalVariation > rows[j].row.TotalVariation
+		}
+		return rows[i].row.Weight > rows[j].row.Weight
+	})
+	fmt.Printf("\ntransitions changed most\n")
+	for i, row := range rows {
+		if i >= options.Top {
+			break
+		}
+		if row.row.OnlyIn != "" {
+			fmt.Printf("  %s %s %s -> *  only in %s profile (weight %.3f)\n", row.scope, row.metric, row.row.From, row.row.OnlyIn, row.row.Weight)
+			continue
+		}
+		fmt.Printf("  %s %s %s -> %d  %+.2f (total variation %.3f, jensen-shannon %.3f, weight %.3f)\n",
+			row.scope, row.metric, row.row.From, row.row.To, row.row.Change, row.row.TotalVariation, row.row.JensenShannon, row.row.Weight)
+	}
+}
+
+func printDivergence(prefix string, divergence models.TxMatrixDivergence) {
+	if divergence.OnlyIn != "" {
+		fmt.Printf("%s%s only in %s profile\n", prefix, divergence.Metric, divergence.OnlyIn)
+		return
+	}
+	fmt.Printf("%s%s  total variation %.3f, jensen-shannon %.3f, avg %+.2f, stddev %+.2f, count %+d\n",
+		prefix, divergence.Metric, divergence.TotalVariation, divergence.JensenShannon,
+		divergence.StatsDelta.Avg, divergence.StatsDelta.Stddev, divergence.StatsDelta.Count)
+}
+
+func appendRows(rows []scopedRow, scope string, divergence models.TxMatrixDivergence) []scopedRow {
+	for _, row := range divergence.Rows {
+		rows = append(rows, scopedRow{
+			scope:  scope,
+			metric: divergence.Metric,
+			row:    row,
+		})
+	}
+	return rows
+}
+
+func maxTotalVariation(node models.PeriodNodeDivergence) float64 {
+	max := float64(0)
+	for _, divergence := range node.Metrics {
+		if divergence.TotalVariation > max {
+			max = divergence.TotalVariation
+		}
+	}
+	return max
+}
+
+func pathString(path []int) string {
+	if len(path) == 0 {
+		return "root"
+	}
+	return strings.Trim(strings.Replace(fmt.Sprint(path), " ", ".", -1), "[]")
+}
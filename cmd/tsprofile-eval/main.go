@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cha87de/tsprofiler/eval"
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/predictor"
+	"github.com/cha87de/tsprofiler/utils"
+	flags "github.com/jessevdk/go-flags"
+)
+
+var options struct {
+	States        int `long:"states" default:"4"`
+	BufferSize    int `long:"buffersize" default:"10"`
+	FilterStdDevs int `long:"filterstddevs" default:"2"`
+
+	FixedBound bool    `long:"fixedbound"`
+	FixedMin   float64 `long:"fixedmin" default:"0" description:"if fixedbound is set, set the min value"`
+	FixedMax   float64 `long:"fixedmax" default:"100" description:"if fixedbound is set, set the max value"`
+
+	PeriodSize string `long:"periodsize" default:"" description:"comma separated list of ints, specifies descrete states per period"`
+
+	PhaseChangeLikeliness     float32 `long:"phasechangelikeliness" default:""`
+	PhaseChangeHistory        int64   `long:"phasechangehistory" default:"1"`
+	PhaseChangeHistoryFadeout bool    `long:"phasechangehistoryfadeout"`
+	PhaseDetector             string  `long:"phasedetector" default:"likeliness" choice:"likeliness" choice:"cusum" choice:"bocpd" description:"detector of phase changes, the likeliness detector requires phasechangelikeliness"`
+	PhaseCusumDrift           float32 `long:"phasecusumdrift" default:"0" description:"cusum detector: tolerated drop of likeliness per state, 0 for the default 0.05"`
+	PhaseCusumThreshold       float32 `long:"phasecusumthreshold" default:"0" description:"cusum detector: cumulative drop of likeliness to detect a change, 0 for the default 2"`
+	PhaseHazard               float32 `long:"phasehazard" default:"0" description:"bocpd detector: prior probability of a phase change per state, 0 for the default 0.01"`
+
+	ContextMinCount      int64   `long:"contextmincount" default:"0" description:"keep histories longer than one state counted at least this often, 0 for the default 2, negative to keep all histories unpruned and unsmoothed"`
+	ContextMinDivergence float32 `long:"contextmindivergence" default:"0" description:"keep histories longer than one state diverging at least this much from their shorter history (Jensen-Shannon divergence [0,1]), 0 for the default 0.01, negative for any"`
+
+	JointStates bool `long:"jointstates" description:"count the transitions of the joint states of all metrics"`
+
+	Train        float64 `long:"train" default:"0.7" description:"fraction of the time series to train the profile on"`
+	Steps        int     `long:"steps" default:"1" description:"amount of steps to forecast ahead"`
+	Modes        string  `long:"modes" default:"" description:"comma separated list of prediction modes to evaluate, empty for all applicable"`
+	PeriodDepths string  `long:"periodDepths" default:"" description:"comma separated list of period depths to evaluate in mode 2, empty for all"`
+	Histories    string  `long:"histories" default:"1" description:"comma separated list of history settings to evaluate"`
+	Output       string  `long:"output" default:"text" choice:"text" choice:"csv" choice:"json" description:"output format of the scores"`
+
+	Inputfile string
+}
+
+func main() {
+	initializeFlags()
+
+	rows, err := readRows(options.Inputfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot read %s: %s\n", options.Inputfile, err)
+		os.Exit(1)
+	}
+
+	config, err := createConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	scores, err := eval.Backtest(rows, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backtest failed: %s\n", err)
+		os.Exit(1)
+	}
+
+	switch options.Output {
+	case "json":
+		outputJSON(scores)
+	case "csv":
+		outputCSV(scores)
+	default:
+		outputText(scores)
+	}
+}
+
+func readRows(filename string) ([][]float64, error) {
+	var input io.Reader
+	if filename == "-" {
+		input = os.Stdin
+	} else {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+	return utils.ReadCSV(input)
+}
+
+func createConfig() (eval.Config, error) {
+	periodSize, err := parseInts(options.PeriodSize)
+	if err != nil {
+		return eval.Config{}, fmt.Errorf("invalid period size: %s", err)
+	}
+	modes, err := parseInts(options.Modes)
+	if err != nil {
+		return eval.Config{}, fmt.Errorf("invalid modes: %s", err)
+	}
+	predictionModes := make([]predictor.PredictionMode, len(modes))
+	for i, mode := range modes {
+		predictionModes[i] = predictor.PredictionMode(mode)
+	}
+	periodDepths, err := parseInts(options.PeriodDepths)
+	if err != nil {
+		return eval.Config{}, fmt.Errorf("invalid period depths: %s", err)
+	}
+	histories, err := parseInts(options.Histories)
+	if err != nil {
+		return eval.Config{}, fmt.Errorf("invalid histories: %s", err)
+	}
+
+	settings := models.Settings{
+		Name:                      "tsprofile-eval",
+		BufferSize:                options.BufferSize,
+		States:                    options.States,
+		FilterStdDevs:             options.FilterStdDevs,
+		FixBound:                  options.FixedBound,
+		ContextMinCount:           options.ContextMinCount,
+		ContextMinDivergence:      options.ContextMinDivergence,
+		PeriodSize:                periodSize,
+		PhaseChangeLikeliness:     options.PhaseChangeLikeliness,
+		PhaseChangeHistory:        options.PhaseChangeHistory,
+		PhaseChangeHistoryFadeout: options.PhaseChangeHistoryFadeout,
+		PhaseDetector:             models.PhaseDetector(options.PhaseDetector),
+		PhaseCusumDrift:           options.PhaseCusumDrift,
+		PhaseCusumThreshold:       options.PhaseCusumThreshold,
+		PhaseHazard:               options.PhaseHazard,
+		JointStates:               options.JointStates,
+	}
+	if len(predictionModes) == 0 {
+		predictionModes = eval.Modes(settings)
+	}
+	if len(periodDepths) == 0 {
+		periodDepths = eval.PeriodDepths(settings)
+	}
+
+	return eval.Config{
+		Settings:     settings,
+		FixedMin:     options.FixedMin,
+		FixedMax:     options.FixedMax,
+		Train:        options.Train,
+		Steps:        options.Steps,
+		Modes:        predictionModes,
+		PeriodDepths: periodDepths,
+		Histories:    histories,
+	}, nil
+}
+
+// parseInts parses a comma separated list of ints, e.g. "1,2,3"
+func parseInts(list string) ([]int, error) {
+	ints := make([]int, 0)
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, i)
+	}
+	return ints, nil
+}
+
+func initializeFlags() {
+	// initialize parser for flags
+	parser := flags.NewParser(&options, flags.Default)
+	parser.ShortDescription = "tsprofile-eval"
+	parser.LongDescription = "Backtests profiles on a CSV file, scoring the forecasts per mode, period depth and history"
+	parser.ArgsRequired = true
+
+	// Parse parameters
+	args, err := parser.Parse()
+	if err != nil {
+		code := 1
+		if fe, ok := err.(*flags.Error); ok {
+			if fe.Type == flags.ErrHelp {
+				code = 0
+			}
+		}
+		if code != 0 {
+			fmt.Printf("Error parsing flags: %s", err)
+		}
+		os.Exit(code)
+	}
+
+	if len(args) < 1 {
+		fmt.Printf("No input file specified.\n")
+		os.Exit(1)
+	}
+	options.Inputfile = args[0]
+}
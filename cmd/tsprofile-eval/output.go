@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cha87de/tsprofiler/eval"
+)
+
+func outputJSON(scores []eval.Score) {
+	json, err := json.MarshalIndent(scores, "", "  ")
+	if err != nil {
+		fmt.Printf("cannot create json: %s\n", err)
+		return
+	}
+	fmt.Printf("%s\n", json)
+}
+
+func outputCSV(scores []eval.Score) {
+	fmt.Printf("mode,periodDepth,history,metric,forecasts,accuracy,logloss,brier,mae,rmse\n")
+	for _, score := range scores {
+		fmt.Printf("%d,%d,%d,%s,%d,%.4f,%.4f,%.4f,%.4f,%.4f\n",
+			score.Mode, score.PeriodDepth, score.History, score.Metric, score.Forecasts,
+			score.Accuracy, score.LogLoss, score.Brier, score.MAE, score.RMSE)
+	}
+}
+
+func outputText(scores []eval.Score) {
+	fmt.Printf("%-4s %-5s %-7s %-10s %9s %8s %8s %8s %10s %10s\n",
+		"mode", "depth", "history", "metric", "forecasts", "accuracy", "logloss", "brier", "mae", "rmse")
+	for _, score := range scores {
+		fmt.Printf("%-4d %-5d %-7d %-10s %9d %8.3f %8.3f %8.3f %10.2f %10.2f\n",
+			score.Mode, score.PeriodDepth, score.History, score.Metric, score.Forecasts,
+			score.Accuracy, score.LogLoss, score.Brier, score.MAE, score.RMSE)
+	}
+}
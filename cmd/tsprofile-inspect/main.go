@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/cha87de/tsprofiler/cmd/tsprofile-inspect/task"
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/render"
+	"github.com/cha87de/tsprofiler/utils"
+	flags "github.com/jessevdk/go-flags"
+)
+
+var options struct {
+	Profilefile string  `long:"profile" short:"p" description:"path to the profile, stdin if '-'"`
+	Format      string  `long:"format" default:"auto" choice:"auto" choice:"json" choice:"binary" description:"encoding of the profile file"`
+	Tolerance   float64 `long:"tolerance" default:"0" description:"additional percentage points probabilities may deviate from 100"`
+	Output      string  `long:"output" default:"text" choice:"text" choice:"json" description:"output format of the summary"`
+	Top         int     `long:"top" default:"5" description:"amount of most frequent transitions to list per metric and history length"`
+	Depth       int     `long:"depth" default:"0" description:"max. depth of the period tree to list, 0 for all"`
+	Outdir      string  `long:"outdir" default:"." description:"directory to write the rendered images and report to"`
+	Image       string  `long:"image" default:"svg" choice:"svg" choice:"png" description:"image format of the rendered heatmaps and plots"`
+	Statesfile  string  `long:"states" default:"" description:"path to a states log (csv2tsprofile --out.states) to plot"`
+	Matrix      string  `long:"matrix" default:"root" description:"tx matrix to export as dot: root, phases, phase:<id> or period:<path>"`
+	Metric      string  `long:"metric" default:"" description:"metric to export as dot, empty for all"`
+	Threshold   float64 `long:"threshold" default:"0.01" description:"min. probability of exported transitions"`
+	Task        string
+}
+
+func main() {
+	initializeFlags()
+
+	var err error
+
+	switch options.Task {
+	case "validate":
+		validate := task.NewValidate(readProfileFile(), models.ProfileFormat(options.Format), options.Tolerance)
+		err = validate.Run()
+		validate.Print()
+	case "summary":
+		summary := task.NewSummary(readProfile(), options.Top, options.Depth, options.Output)
+		err = summary.Run()
+		summary.Print()
+	case "render":
+		renderer := task.NewRender(readProfile(), readStates(), options.Outdir, options.Image)
+		err = renderer.Run()
+		renderer.Print()
+	case "dot":
+		dot := task.NewDot(readProfile(), options.Matrix, options.Metric, options.Threshold)
+		err = dot.Run()
+		dot.Print()
+	case "schema":
+		schema := task.NewSchema()
+		err = schema.Run()
+		schema.Print()
+	default:
+		err = fmt.Errorf("task %s unknown. Select \"summary\", \"validate\", \"render\", \"dot\" or \"schema\" as task.\n", options.Task)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// readProfileFile returns the raw content of the profile file
+func readProfileFile() []byte {
+	if options.Profilefile == "" {
+		fmt.Fprintf(os.Stderr, "No profile specified.\n")
+		os.Exit(1)
+	}
+	var data []byte
+	var err error
+	if options.Profilefile == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(options.Profilefile)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot read profile %s: %s\n", options.Profilefile, err)
+		os.Exit(1)
+	}
+	return data
+}
+
+// readProfile returns the validated profile
+func readProfile() models.TSProfile {
+	profile, err := utils.ReadProfile(bytes.NewReader(readProfileFile()), models.ProfileFormat(options.Format))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot read profile %s: %s\n", options.Profilefile, err)
+		os.Exit(1)
+	}
+	return profile
+}
+
+// readStates returns the states of the states log, nil if none specified
+func readStates() [][]int64 {
+	if options.Statesfile == "" {
+		return nil
+	}
+	file, err := os.Open(options.Statesfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot read states %s: %s\n", options.Statesfile, err)
+		os.Exit(1)
+	}
+	defer file.Close()
+	states, err := render.ReadStates(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot read states %s: %s\n", options.Statesfile, err)
+		os.Exit(1)
+	}
+	return states
+}
+
+func initializeFlags() {
+	// initialize parser for flags
+	parser := flags.NewParser(&options, flags.Default)
+	parser.ShortDescription = "tsprofile-inspect"
+	parser.LongDescription = "Reads a TSProfile from file and runs tasks on it (Summary, Validate, Render, Dot or Schema)"
+	parser.ArgsRequired = true
+
+	// Parse parameters
+	args, err := parser.Parse()
+	if err != nil {
+		code := 1
+		if fe, ok := err.(*flags.Error); ok {
+			if fe.Type == flags.ErrHelp {
+				code = 0
+			}
+		}
+		if code != 0 {
+			fmt.Printf("Error parsing flags: %s", err)
+		}
+		os.Exit(code)
+	}
+
+	if len(args) < 1 {
+		fmt.Printf("No task specified. Select \"summary\", \"validate\", \"render\", \"dot\" or \"schema\" as task.\n")
+		os.Exit(1)
+	}
+	options.Task = strings.ToLower(args[0])
+}
@@ -0,0 +1,88 @@
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/render"
+)
+
+// Dot represents the dot task of tsprofile-inspect
+type Dot struct {
+	profile   models.TSProfile
+	matrix    string
+	metric    string
+	threshold float64
+	dot       []byte
+}
+
+// NewDot creates and returns a new Dot task, exporting the tx matrices
+// selected by `matrix` ("root", "phases", "phase:<id>" or "period:<path>")
+// and `metric` (empty for all metrics) as Graphviz DOT
+func NewDot(profile models.TSProfile, matrix string, metric string, threshold float64) *Dot {
+	return &Dot{
+		profile:   profile,
+		matrix:    matrix,
+		metric:    metric,
+		threshold: threshold,
+	}
+}
+
+// Run exports the selected tx matrices
+func (dot *Dot) Run() error {
+	profile := dot.profile
+	selector := strings.SplitN(dot.matrix, ":", 2)
+
+	var txMatrices []models.TxMatrix
+	switch selector[0] {
+	case "root":
+		txMatrices = profile.RootTx
+	case "phases":
+		dot.dot = render.PhaseGraphDOT(profile.Phases.Tx, len(profile.Phases.Phases), dot.threshold)
+		return nil
+	case "phase":
+		if len(selector) < 2 {
+			return fmt.Errorf("no phase id specified, use \"phase:<id>\".\n")
+		}
+		phase, err := strconv.Atoi(selector[1])
+		if err != nil || phase < 0 || phase >= len(profile.Phases.Phases) {
+			return fmt.Errorf("phase %s unknown, profile has %d phases.\n", selector[1], len(profile.Phases.Phases))
+		}
+		txMatrices = profile.Phases.Phases[phase]
+	case "period":
+		node := &profile.PeriodTree.Root
+		if len(selector) > 1 && selector[1] != "" {
+			for _, part := range strings.Split(selector[1], ".") {
+				child, err := strconv.Atoi(part)
+				if err != nil || child < 0 || child >= len(node.Children) {
+					return fmt.Errorf("period tree node %s unknown.\n", selector[1])
+				}
+				node = &node.Children[child]
+			}
+		}
+		txMatrices = node.TxMatrix
+	default:
+		return fmt.Errorf("matrix %s unknown. Select \"root\", \"phases\", \"phase:<id>\" or \"period:<path>\".\n", dot.matrix)
+	}
+
+	var buf bytes.Buffer
+	for _, txMatrix := range sortedTxMatrices(txMatrices) {
+		if dot.metric != "" && txMatrix.Metric != dot.metric {
+			continue
+		}
+		buf.Write(render.DOT(txMatrix, profile.Settings.States, dot.threshold))
+	}
+	if buf.Len() == 0 {
+		return fmt.Errorf("no tx matrix found for matrix %s and metric %s.\n", dot.matrix, dot.metric)
+	}
+	dot.dot = buf.Bytes()
+	return nil
+}
+
+// Print prints the DOT graphs to stdout
+func (dot *Dot) Print() {
+	fmt.Printf("%s", dot.dot)
+}
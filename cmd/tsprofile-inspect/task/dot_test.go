@@ -0,0 +1,59 @@
+package task
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func dotProfile() models.TSProfile {
+	txMatrix := func(metric string) models.TxMatrix {
+		return models.TxMatrix{
+			Metric: metric,
+			Transitions: map[string]models.TXStep{
+				"0": {NextStateProbs: []int{50, 50}, StepProb: 50},
+				"1": {NextStateProbs: []int{0, 100}, StepProb: 50},
+			},
+		}
+	}
+	profile := models.TSProfile{
+		RootTx:     []models.TxMatrix{txMatrix("mem"), txMatrix("cpu")},
+		PeriodTree: models.NewPeriodTree([]int{2, 4}),
+		Settings: models.Settings{
+			States:     2,
+			History:    1,
+			PeriodSize: []int{2, 4},
+		},
+	}
+	profile.PeriodTree.Root.Children[1].TxMatrix = []models.TxMatrix{txMatrix("cpu")}
+	return profile
+}
+
+func TestDot(t *testing.T) {
+	Convey("Should export a graph per selected metric", t, func() {
+		dot := NewDot(dotProfile(), "root", "", 0)
+		So(dot.Run(), ShouldBeNil)
+		So(strings.Count(string(dot.dot), "digraph"), ShouldEqual, 2)
+		So(strings.Index(string(dot.dot), `digraph "cpu"`), ShouldBeLessThan, strings.Index(string(dot.dot), `digraph "mem"`))
+
+		dot = NewDot(dotProfile(), "root", "mem", 0)
+		So(dot.Run(), ShouldBeNil)
+		So(string(dot.dot), ShouldStartWith, `digraph "mem" {`)
+		So(strings.Count(string(dot.dot), "digraph"), ShouldEqual, 1)
+
+		dot = NewDot(dotProfile(), "period:1", "", 0)
+		So(dot.Run(), ShouldBeNil)
+		So(string(dot.dot), ShouldStartWith, `digraph "cpu" {`)
+	})
+
+	Convey("Should fail for unknown matrices and metrics", t, func() {
+		So(NewDot(dotProfile(), "root", "io", 0).Run(), ShouldNotBeNil)
+		So(NewDot(dotProfile(), "phase", "", 0).Run(), ShouldNotBeNil)
+		So(NewDot(dotProfile(), "phase:0", "", 0).Run(), ShouldNotBeNil)
+		So(NewDot(dotProfile(), "period:2", "", 0).Run(), ShouldNotBeNil)
+		So(NewDot(dotProfile(), "period:0", "", 0).Run(), ShouldNotBeNil)
+		So(NewDot(dotProfile(), "tree", "", 0).Run(), ShouldNotBeNil)
+	})
+}
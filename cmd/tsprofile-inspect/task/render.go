@@ -0,0 +1,145 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/render"
+	"github.com/cha87de/tsprofiler/utils"
+)
+
+// Render represents the render task of tsprofile-inspect
+type Render struct {
+	profile models.TSProfile
+	states  [][]int64
+	outdir  string
+	image   string
+	files   []string
+}
+
+// unsafeFilenameChars matches characters not used in output file names
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// NewRender creates and returns a new Render task, writing `image` (png or
+// svg) heatmaps, the states plot (if `states` is not nil) and the html report
+// to `outdir`
+func NewRender(profile models.TSProfile, states [][]int64, outdir string, image string) *Render {
+	return &Render{
+		profile: profile,
+		states:  states,
+		outdir:  outdir,
+		image:   image,
+	}
+}
+
+// Run renders the profile and writes the files
+func (task *Render) Run() error {
+	if task.image != "png" && task.image != "svg" {
+		return fmt.Errorf("image format %s unknown. Select \"png\" or \"svg\".\n", task.image)
+	}
+	if err := os.MkdirAll(task.outdir, 0755); err != nil {
+		return err
+	}
+	profile := task.profile
+	states := profile.Settings.States
+
+	for _, txMatrix := range profile.RootTx {
+		if err := task.writeHeatmap(txMatrix, states, "roottx-"+txMatrix.Metric); err != nil {
+			return err
+		}
+	}
+	if len(profile.Phases.Phases) > 0 {
+		if err := task.writeHeatmap(profile.Phases.Tx, len(profile.Phases.Phases), "phasetx"); err != nil {
+			return err
+		}
+	}
+	for i, phase := range profile.Phases.Phases {
+		for _, txMatrix := range phase {
+			if err := task.writeHeatmap(txMatrix, states, fmt.Sprintf("phase-%d-%s", i, txMatrix.Metric)); err != nil {
+				return err
+			}
+		}
+	}
+	if len(profile.Settings.PeriodSize) > 0 {
+		if err := task.writePeriodNode(&profile.PeriodTree.Root, []int{}); err != nil {
+			return err
+		}
+	}
+
+	if task.states != nil {
+		var data []byte
+		var err error
+		if task.image == "png" {
+			data, err = render.StatesPNG(task.states)
+		} else {
+			data = render.StatesSVG(task.states, nil)
+		}
+		if err != nil {
+			return err
+		}
+		if err := task.write("states."+task.image, data); err != nil {
+			return err
+		}
+	}
+
+	report, err := render.Report(profile, task.states)
+	if err != nil {
+		return err
+	}
+	return task.write("report.html", report)
+}
+
+func (task *Render) writePeriodNode(node *models.PeriodTreeNode, path []int) error {
+	name := "root"
+	if len(path) > 0 {
+		name = strings.Trim(strings.Replace(fmt.Sprint(path), " ", ".", -1), "[]")
+	}
+	for _, txMatrix := range node.TxMatrix {
+		if err := task.writeHeatmap(txMatrix, task.profile.Settings.States, fmt.Sprintf("period-%s-%s", name, txMatrix.Metric)); err != nil {
+			return err
+		}
+	}
+	for i := range node.Children {
+		childPath := make([]int, len(path)+1)
+		copy(childPath, path)
+		childPath[len(path)] = i
+		if err := task.writePeriodNode(&node.Children[i], childPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (task *Render) writeHeatmap(txMatrix models.TxMatrix, states int, name string) error {
+	var data []byte
+	var err error
+	if task.image == "png" {
+		data, err = render.HeatmapPNG(txMatrix, states)
+	} else {
+		data = render.HeatmapSVG(txMatrix, states, name)
+	}
+	if err != nil {
+		return err
+	}
+	return task.write(unsafeFilenameChars.ReplaceAllString(name, "_")+"."+task.image, data)
+}
+
+func (task *Render) write(name string, data []byte) error {
+	filename := filepath.Join(task.outdir, name)
+	if err := utils.WriteFileAtomic(filename, data, 0644); err != nil {
+		return fmt.Errorf("cannot write %s: %s\n", filename, err)
+	}
+	task.files = append(task.files, filename)
+	return nil
+}
+
+// Print lists the written files
+func (task *Render) Print() {
+	for _, file := range task.files {
+		fmt.Printf("%s\n", file)
+	}
+}
@@ -0,0 +1,34 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/utils"
+)
+
+// Schema represents the schema task of tsprofile-inspect
+type Schema struct {
+	schema []byte
+}
+
+// NewSchema creates and returns a new Schema task
+func NewSchema() *Schema {
+	return &Schema{}
+}
+
+// Run generates the JSON Schema of TSProfile
+func (schema *Schema) Run() error {
+	var err error
+	schema.schema, err = json.MarshalIndent(utils.JSONSchema(models.TSProfile{}, "TSProfile"), "", "  ")
+	return err
+}
+
+// Print prints the JSON Schema to stdout
+func (schema *Schema) Print() {
+	if len(schema.schema) <= 0 {
+		return
+	}
+	fmt.Printf("%s\n", schema.schema)
+}
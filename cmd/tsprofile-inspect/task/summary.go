@@ -0,0 +1,280 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cha87de/tsprofiler/models"
+)
+
+// Summary represents the summary task of tsprofile-inspect
+type Summary struct {
+	profile models.TSProfile
+	top     int
+	depth   int
+	output  string
+	summary profileSummary
+}
+
+type profileSummary struct {
+	Name       string             `json:"name"`
+	Version    int                `json:"version"`
+	Settings   models.Settings    `json:"settings"`
+	Metrics    []metricSummary    `json:"metrics"`
+	Phases     phasesSummary      `json:"phases"`
+	PeriodTree *periodNodeSummary `json:"periodTree,omitempty"`
+}
+
+type metricSummary struct {
+	Metric      string              `json:"metric"`
+	Stats       models.TSStats      `json:"stats"`
+	Stationary  []float64           `json:"stationary"`
+	Transitions []transitionSummary `json:"transitions"`
+}
+
+type transitionSummary struct {
+	History     int     `json:"history"`
+	From        string  `json:"from"`
+	To          int     `json:"to"`
+	Probability float64 `json:"probability"`
+	Frequency   float64 `json:"frequency"`
+}
+
+type phasesSummary struct {
+	Count  int            `json:"count"`
+	Phases []phaseSummary `json:"phases"`
+}
+
+type phaseSummary struct {
+	Phase        int     `json:"phase"`
+	Occupancy    float64 `json:"occupancy"`
+	Stationary   float64 `json:"stationary"`
+	Visits       int64   `json:"visits,omitempty"`
+	MeanDuration float64 `json:"meanDuration,omitempty"`
+}
+
+type periodNodeSummary struct {
+	Path     []int                 `json:"path"`
+	Metrics  []periodMetricSummary `json:"metrics"`
+	Children []periodNodeSummary   `json:"children,omitempty"`
+}
+
+type periodMetricSummary struct {
+	Metric        string  `json:"metric"`
+	DominantState int     `json:"dominantState"`
+	Avg           float64 `json:"avg"`
+	Stddev        float64 `json:"stddev"`
+}
+
+// NewSummary creates and returns a new Summary task, listing the `top` most
+// frequent transitions and period tree nodes up to `depth` (0 for all)
+func NewSummary(profile models.TSProfile, top int, depth int, output string) *Summary {
+	return &Summary{
+		profile: profile,
+		top:     top,
+		depth:   depth,
+		output:  output,
+	}
+}
+
+// Run computes the summary of the profile
+func (summary *Summary) Run() error {
+	if summary.output != "text" && summary.output != "json" {
+		return fmt.Errorf("output %s unknown. Select \"text\" or \"json\".\n", summary.output)
+	}
+	profile := summary.profile
+	states := profile.Settings.States
+
+	summary.summary = profileSummary{
+		Name:     profile.Name,
+		Version:  profile.Version,
+		Settings: profile.Settings,
+		Metrics:  make([]metricSummary, 0),
+	}
+
+	// root tx per metric
+	rootTx := sortedTxMatrices(profile.RootTx)
+	for _, txMatrix := range rootTx {
+		summary.summary.Metrics = append(summary.summary.Metrics, metricSummary{
+			Metric:      txMatrix.Metric,
+			Stats:       txMatrix.Stats,
+			Stationary:  txMatrix.StationaryDistribution(states),
+			Transitions: topTransitions(txMatrix, summary.top),
+		})
+	}
+
+	// phases
+	phaseCount := len(profile.Phases.Phases)
+	summary.summary.Phases = phasesSummary{
+		Count:  phaseCount,
+		Phases: make([]phaseSummary, phaseCount),
+	}
+	if phaseCount > 0 {
+		occupancy := profile.Phases.Tx.StateDistribution(phaseCount)
+		stationary := profile.Phases.Tx.StationaryDistribution(phaseCount)
+		for i := range summary.summary.Phases.Phases {
+			summary.summary.Phases.Phases[i] = phaseSummary{
+				Phase:      i,
+				Occupancy:  occupancy[i],
+				Stationary: stationary[i],
+			}
+			if i < len(profile.Phases.Meta) {
+				summary.summary.Phases.Phases[i].Visits = profile.Phases.Meta[i].Visits
+				summary.summary.Phases.Phases[i].MeanDuration = profile.Phases.Meta[i].MeanDuration
+			}
+		}
+	}
+
+	// period tree
+	if len(profile.Settings.PeriodSize) > 0 {
+		root := summarizePeriodNode(&profile.PeriodTree.Root, []int{}, summary.depth)
+		summary.summary.PeriodTree = &root
+	}
+	return nil
+}
+
+// sortedTxMatrices returns the matrices sorted by metric name
+func sortedTxMatrices(txMatrices []models.TxMatrix) []models.TxMatrix {
+	sorted := make([]models.TxMatrix, len(txMatrices))
+	copy(sorted, txMatrices)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Metric < sorted[j].Metric
+	})
+	return sorted
+}
+
+// topTransitions returns per history length the `top` transitions with the
+// highest frequency (step probability times next state probability). The
+// step probabilities are shares of the counts of one history length, hence
+// the frequencies of different history lengths are not compared.
+func topTransitions(txMatrix models.TxMatrix, top int) []transitionSummary {
+	transitions := make([]transitionSummary, 0)
+	for from, txStep := range txMatrix.Transitions {
+		history := strings.Count(from, "-") + 1
+		for to, prob := range txStep.NextStateProbs {
+			if prob <= 0 {
+				continue
+			}
+			transitions = append(transitions, transitionSummary{
+				History:     history,
+				From:        from,
+				To:          to,
+				Probability: float64(prob) / 100,
+				Frequency:   float64(txStep.StepProb) / 100 * float64(prob) / 100,
+			})
+		}
+	}
+	sort.Slice(transitions, func(i, j int) bool {
+		if transitions[i].History != transitions[j].History {
+			return transitions[i].History < transitions[j].History
+		}
+		if transitions[i].Frequency != transitions[j].Frequency {
+			return transitions[i].Frequency > transitions[j].Frequency
+		}
+		if transitions[i].From != transitions[j].From {
+			return transitions[i].From < transitions[j].From
+		}
+		return transitions[i].To < transitions[j].To
+	})
+	topPerHistory := make([]transitionSummary, 0)
+	for i, transition := range transitions {
+		if i < top || transitions[i-top].History != transition.History {
+			topPerHistory = append(topPerHistory, transition)
+		}
+	}
+	return topPerHistory
+}
+
+func summarizePeriodNode(node *models.PeriodTreeNode, path []int, depth int) periodNodeSummary {
+	nodeSummary := periodNodeSummary{
+		Path:    path,
+		Metrics: make([]periodMetricSummary, 0),
+	}
+	for _, txMatrix := range sortedTxMatrices(node.TxMatrix) {
+		nodeSummary.Metrics = append(nodeSummary.Metrics, periodMetricSummary{
+			Metric:        txMatrix.Metric,
+			DominantState: txMatrix.DominantState(),
+			Avg:           txMatrix.Stats.Avg,
+			Stddev:        txMatrix.Stats.Stddev,
+		})
+	}
+	if depth > 0 && len(path) >= depth {
+		return nodeSummary
+	}
+	for i := range node.Children {
+		childPath := make([]int, len(path)+1)
+		copy(childPath, path)
+		childPath[len(path)] = i
+		nodeSummary.Children = append(nodeSummary.Children, summarizePeriodNode(&node.Children[i], childPath, depth))
+	}
+	return nodeSummary
+}
+
+// Print prints the summary as text or json to stdout
+func (summary *Summary) Print() {
+	if summary.output == "json" {
+		data, err := json.MarshalIndent(summary.summary, "", "  ")
+		if err != nil {
+			fmt.Printf("cannot create json: %s\n", err)
+			return
+		}
+		fmt.Printf("%s\n", data)
+		return
+	}
+	if summary.output != "text" {
+		return
+	}
+
+	s := summary.summary
+	fmt.Printf("profile %s (version %d): %d states, history %d, buffersize %d, period size %v\n",
+		s.Name, s.Version, s.Settings.States, s.Settings.History, s.Settings.BufferSize, s.Settings.PeriodSize)
+
+	for _, metric := range s.Metrics {
+		fmt.Printf("\nmetric %s\n", metric.Metric)
+		fmt.Printf("  stats: min %.2f, max %.2f, avg %.2f, stddev %.2f, count %d\n",
+			metric.Stats.Min, metric.Stats.Max, metric.Stats.Avg, metric.Stats.Stddev, metric.Stats.Count)
+		stationary := make([]string, len(metric.Stationary))
+		for state, p := range metric.Stationary {
+			stationary[state] = fmt.Sprintf("%d:%.3f", state, p)
+		}
+		fmt.Printf("  stationary: %s\n", strings.Join(stationary, " "))
+		for i, transition := range metric.Transitions {
+			if i == 0 || metric.Transitions[i-1].History != transition.History {
+				fmt.Printf("  most frequent transitions of history %d:\n", transition.History)
+			}
+			fmt.Printf("    %s -> %d  probability %.2f, frequency %.3f\n", transition.From, transition.To, transition.Probability, transition.Frequency)
+		}
+	}
+
+	fmt.Printf("\nphases: %d\n", s.Phases.Count)
+	for _, phase := range s.Phases.Phases {
+		fmt.Printf("  phase %d: occupancy %.3f, stationary %.3f", phase.Phase, phase.Occupancy, phase.Stationary)
+		if phase.Visits > 0 {
+			fmt.Printf(", visits %d, mean duration %.1f", phase.Visits, phase.MeanDuration)
+		}
+		fmt.Printf("\n")
+	}
+
+	if s.PeriodTree != nil {
+		fmt.Printf("\nperiod tree:\n")
+		printPeriodNode(*s.PeriodTree)
+	}
+}
+
+func printPeriodNode(node periodNodeSummary) {
+	indent := strings.Repeat("  ", len(node.Path)+1)
+	name := "root"
+	if len(node.Path) > 0 {
+		name = strings.Trim(strings.Replace(fmt.Sprint(node.Path), " ", ".", -1), "[]")
+	}
+	metrics := make([]string, len(node.Metrics))
+	for i, metric := range node.Metrics {
+		metrics[i] = fmt.Sprintf("%s: state %d (avg %.2f, stddev %.2f)", metric.Metric, metric.DominantState, metric.Avg, metric.Stddev)
+	}
+	fmt.Printf("%s%s\n", indent, strings.Join(append([]string{name}, metrics...), "  "))
+	for _, child := range node.Children {
+		printPeriodNode(child)
+	}
+}
@@ -0,0 +1,58 @@
+package task
+
+import (
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSummary(t *testing.T) {
+	txMatrix := models.TxMatrix{
+		Metric: "cpu",
+		Transitions: map[string]models.TXStep{
+			"0":   {NextStateProbs: []int{20, 80}, StepProb: 60},
+			"1":   {NextStateProbs: []int{50, 50}, StepProb: 40},
+			"0-0": {NextStateProbs: []int{0, 100}, StepProb: 10},
+			"1-0": {NextStateProbs: []int{90, 10}, StepProb: 50},
+		},
+		Stats: models.TSStats{Min: 0, Max: 100, Count: 100},
+	}
+
+	Convey("Should list the most frequent transitions per history length", t, func() {
+		transitions := topTransitions(txMatrix, 2)
+		So(transitions, ShouldHaveLength, 4)
+		So(transitions[0], ShouldResemble, transitionSummary{History: 1, From: "0", To: 1, Probability: 0.8, Frequency: 0.6 * 0.8})
+		So(transitions[1].From, ShouldEqual, "1")
+		So(transitions[1].To, ShouldEqual, 0)
+		// 1-0 -> 0 is more frequent than 1 -> 0, but of another history length
+		So(transitions[2], ShouldResemble, transitionSummary{History: 2, From: "1-0", To: 0, Probability: 0.9, Frequency: 0.5 * 0.9})
+		So(transitions[3].From, ShouldEqual, "0-0")
+
+		So(topTransitions(txMatrix, 10), ShouldHaveLength, 7)
+		So(topTransitions(txMatrix, 0), ShouldBeEmpty)
+	})
+
+	Convey("Should summarize the metrics, phases and period tree", t, func() {
+		profile := models.TSProfile{
+			Name:       "test",
+			Version:    models.ProfileVersion,
+			RootTx:     []models.TxMatrix{txMatrix},
+			PeriodTree: models.NewPeriodTree([]int{2, 4}),
+			Settings: models.Settings{
+				States:     2,
+				History:    2,
+				PeriodSize: []int{2, 4},
+			},
+		}
+		summary := NewSummary(profile, 1, 0, "json")
+		So(summary.Run(), ShouldBeNil)
+		So(summary.summary.Metrics, ShouldHaveLength, 1)
+		So(summary.summary.Metrics[0].Transitions, ShouldHaveLength, 2)
+		So(summary.summary.Metrics[0].Stationary, ShouldHaveLength, 2)
+		So(summary.summary.Phases.Count, ShouldEqual, 0)
+		So(summary.summary.PeriodTree.Children, ShouldHaveLength, 2)
+
+		So(NewSummary(profile, 1, 0, "yaml").Run(), ShouldNotBeNil)
+	})
+}
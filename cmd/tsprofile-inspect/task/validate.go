@@ -0,0 +1,112 @@
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/utils"
+)
+
+// Validate represents the validate task of tsprofile-inspect
+type Validate struct {
+	data      []byte
+	format    models.ProfileFormat
+	tolerance float64
+
+	notices    []string
+	violations []string
+}
+
+// NewValidate creates and returns a new Validate task for the raw profile data
+func NewValidate(data []byte, format models.ProfileFormat, tolerance float64) *Validate {
+	return &Validate{
+		data:       data,
+		format:     format,
+		tolerance:  tolerance,
+		notices:    make([]string, 0),
+		violations: make([]string, 0),
+	}
+}
+
+// Run checks the profile against the JSON Schema, its structure and its semantics
+func (validate *Validate) Run() error {
+	format := validate.format
+	if format == models.ProfileFormatAuto {
+		format = models.DetectProfileFormat(validate.data)
+	}
+
+	if format == models.ProfileFormatJSON {
+		validate.validateSchema()
+	}
+
+	profile, err := models.UnmarshalProfile(validate.data, format)
+	if _, isSchemaErr := err.(*models.SchemaError); err != nil && (!isSchemaErr || format != models.ProfileFormatJSON) {
+		validate.addViolations("decode", err)
+		return fmt.Errorf("%d violations found\n", len(validate.violations))
+	}
+
+	validate.addViolations("structure", profile.Validate())
+	validate.addViolations("semantics", profile.ValidateSemantics(validate.tolerance))
+
+	if len(validate.violations) > 0 {
+		return fmt.Errorf("%d violations found\n", len(validate.violations))
+	}
+	return nil
+}
+
+// validateSchema checks the (migrated) json document against the JSON Schema of TSProfile
+func (validate *Validate) validateSchema() {
+	data := validate.data
+	var versioned struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &versioned); err == nil && versioned.Version < models.ProfileVersion {
+		migrated, err := models.MigrateProfileJSON(data)
+		if err != nil {
+			validate.violations = append(validate.violations, fmt.Sprintf("migration: %s", err))
+			return
+		}
+		validate.notices = append(validate.notices, fmt.Sprintf("profile version %d migrated to version %d", versioned.Version, models.ProfileVersion))
+		data = migrated
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		validate.violations = append(validate.violations, fmt.Sprintf("json: %s", err))
+		return
+	}
+	schema := utils.JSONSchema(models.TSProfile{}, "TSProfile")
+	for _, violation := range utils.ValidateJSONSchema(schema, document) {
+		validate.violations = append(validate.violations, fmt.Sprintf("schema: %s", violation))
+	}
+}
+
+func (validate *Validate) addViolations(category string, err error) {
+	if err == nil {
+		return
+	}
+	if validationErr, ok := err.(*models.ValidationError); ok {
+		for _, issue := range validationErr.Issues {
+			validate.violations = append(validate.violations, fmt.Sprintf("%s: %s", category, issue))
+		}
+		return
+	}
+	validate.violations = append(validate.violations, fmt.Sprintf("%s: %s", category, err))
+}
+
+// Print prints the notices and violations to stdout
+func (validate *Validate) Print() {
+	for _, notice := range validate.notices {
+		fmt.Printf("notice: %s\n", notice)
+	}
+	for _, violation := range validate.violations {
+		fmt.Printf("%s\n", violation)
+	}
+	if len(validate.violations) == 0 {
+		fmt.Printf("ok\n")
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cha87de/tsprofiler/eval"
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/utils"
+	flags "github.com/jessevdk/go-flags"
+)
+
+var options struct {
+	States                string `long:"states" default:"4" description:"comma separated list of states to search"`
+	BufferSize            string `long:"buffersize" default:"10" description:"comma separated list of buffer sizes to search"`
+	History               string `long:"history" default:"1" description:"comma separated list of histories to search"`
+	PeriodSize            string `long:"periodsize" default:"none" description:"semicolon separated list of period sizes to search, each a comma separated list of ints or none"`
+	PhaseChangeLikeliness string `long:"phasechangelikeliness" default:"0" description:"comma separated list of phase change likeliness to search, 0 disables phase detection"`
+
+	FilterStdDevs             int     `long:"filterstddevs" default:"2"`
+	FixedBound                bool    `long:"fixedbound"`
+	FixedMin                  float64 `long:"fixedmin" default:"0" description:"if fixedbound is set, set the min value"`
+	FixedMax                  float64 `long:"fixedmax" default:"100" description:"if fixedbound is set, set the max value"`
+	PhaseChangeHistory        int64   `long:"phasechangehistory" default:"1"`
+	PhaseChangeHistoryFadeout bool    `long:"phasechangehistoryfadeout"`
+	JointStates               bool    `long:"jointstates" description:"count the transitions of the joint states of all metrics"`
+
+	Train     float64 `long:"train" default:"0.7" description:"fraction of the time series to train the profiles on"`
+	Steps     int     `long:"steps" default:"1" description:"amount of steps to forecast ahead"`
+	Samples   int     `long:"samples" default:"0" description:"amount of random candidates to search, 0 for a grid search"`
+	Seed      int64   `long:"seed" default:"0" description:"seed of the random search, 0 for a random seed"`
+	Objective string  `long:"objective" default:"mae" choice:"mae" choice:"rmse" choice:"logloss" choice:"brier" choice:"accuracy" description:"score to rank the candidates by"`
+	Workers   int     `long:"workers" default:"0" description:"amount of parallel backtests, 0 for the amount of CPUs"`
+	Top       int     `long:"top" default:"10" description:"amount of trials to list in the leaderboard"`
+	Output    string  `long:"output" default:"text" choice:"text" choice:"json" description:"output format of best settings and leaderboard"`
+
+	Inputfile string
+}
+
+func main() {
+	initializeFlags()
+
+	rows, err := readRows(options.Inputfile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot read %s: %s\n", options.Inputfile, err)
+		os.Exit(1)
+	}
+
+	config, err := createConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	trials, err := eval.Tune(rows, config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tuning failed: %s\n", err)
+		os.Exit(1)
+	}
+	if len(trials) == 0 || trials[0].Error != "" {
+		fmt.Fprintf(os.Stderr, "no candidate could be backtested\n")
+		for _, trial := range trials {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", trial, trial.Error)
+		}
+		os.Exit(1)
+	}
+
+	if options.Output == "json" {
+		outputJSON(trials)
+	} else {
+		outputText(trials)
+	}
+}
+
+func readRows(filename string) ([][]float64, error) {
+	var input io.Reader
+	if filename == "-" {
+		input = os.Stdin
+	} else {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+	return utils.ReadCSV(input)
+}
+
+func createConfig() (eval.TuneConfig, error) {
+	var space eval.SearchSpace
+	var err error
+	if space.States, err = parseInts(options.States); err != nil {
+		return eval.TuneConfig{}, fmt.Errorf("invalid states: %s", err)
+	}
+	if space.BufferSize, err = parseInts(options.BufferSize); err != nil {
+		return eval.TuneConfig{}, fmt.Errorf("invalid buffer sizes: %s", err)
+	}
+	if space.History, err = parseInts(options.History); err != nil {
+		return eval.TuneConfig{}, fmt.Errorf("invalid histories: %s", err)
+	}
+	for _, periodSizeStr := range strings.Split(options.PeriodSize, ";") {
+		periodSizeStr = strings.TrimSpace(periodSizeStr)
+		if periodSizeStr == "none" {
+			periodSizeStr = ""
+		}
+		periodSize, err := parseInts(periodSizeStr)
+		if err != nil {
+			return eval.TuneConfig{}, fmt.Errorf("invalid period sizes: %s", err)
+		}
+		space.PeriodSize = append(space.PeriodSize, periodSize)
+	}
+	for _, s := range strings.Split(options.PhaseChangeLikeliness, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		likeliness, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return eval.TuneConfig{}, fmt.Errorf("invalid phase change likeliness: %s", err)
+		}
+		space.PhaseChangeLikeliness = append(space.PhaseChangeLikeliness, float32(likeliness))
+	}
+
+	seed := options.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return eval.TuneConfig{
+		Config: eval.Config{
+			Settings: models.Settings{
+				Name:                      "tsprofile-tune",
+				BufferSize:                10,
+				States:                    4,
+				History:                   1,
+				FilterStdDevs:             options.FilterStdDevs,
+				FixBound:                  options.FixedBound,
+				PhaseChangeHistory:        options.PhaseChangeHistory,
+				PhaseChangeHistoryFadeout: options.PhaseChangeHistoryFadeout,
+				JointStates:               options.JointStates,
+			},
+			FixedMin: options.FixedMin,
+			FixedMax: options.FixedMax,
+			Train:    options.Train,
+			Steps:    options.Steps,
+		},
+		Space:     space,
+		Samples:   options.Samples,
+		Seed:      seed,
+		Objective: options.Objective,
+		Workers:   options.Workers,
+	}, nil
+}
+
+// parseInts parses a comma separated list of ints, e.g. "1,2,3"
+func parseInts(list string) ([]int, error) {
+	ints := make([]int, 0)
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, i)
+	}
+	return ints, nil
+}
+
+func initializeFlags() {
+	// initialize parser for flags
+	parser := flags.NewParser(&options, flags.Default)
+	parser.ShortDescription = "tsprofile-tune"
+	parser.LongDescription = "Searches the settings which profile a CSV file best, via backtests of grid or random candidates"
+	parser.ArgsRequired = true
+
+	// Parse parameters
+	args, err := parser.Parse()
+	if err != nil {
+		code := 1
+		if fe, ok := err.(*flags.Error); ok {
+			if fe.Type == flags.ErrHelp {
+				code = 0
+			}
+		}
+		if code != 0 {
+			fmt.Printf("Error parsing flags: %s", err)
+		}
+		os.Exit(code)
+	}
+
+	if len(args) < 1 {
+		fmt.Printf("No input file specified.\n")
+		os.Exit(1)
+	}
+	options.Inputfile = args[0]
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cha87de/tsprofiler/eval"
+)
+
+// result is the json output of the search
+type result struct {
+	Best        eval.Trial   `json:"best"`
+	Leaderboard []eval.Trial `json:"leaderboard"`
+	Failed      []eval.Trial `json:"failed,omitempty"`
+}
+
+func outputJSON(trials []eval.Trial) {
+	leaderboard, failed := splitTrials(trials)
+	json, err := json.MarshalIndent(result{
+		Best:        trials[0],
+		Leaderboard: leaderboard,
+		Failed:      failed,
+	}, "", "  ")
+	if err != nil {
+		fmt.Printf("cannot create json: %s\n", err)
+		return
+	}
+	fmt.Printf("%s\n", json)
+}
+
+func outputText(trials []eval.Trial) {
+	leaderboard, failed := splitTrials(trials)
+
+	best := trials[0]
+	settings, err := json.MarshalIndent(best.Settings, "", "  ")
+	if err != nil {
+		fmt.Printf("cannot create json: %s\n", err)
+		return
+	}
+	fmt.Printf("best settings (mode %d, period depth %d, %s %.4f)\n%s\n", best.Mode, best.PeriodDepth, options.Objective, objectiveValue(best), settings)
+
+	fmt.Printf("\nleaderboard\n")
+	fmt.Printf("%-4s %10s %-4s %-5s %-6s %-10s %-7s %-12s %-10s %8s %8s %8s %10s %10s\n",
+		"rank", options.Objective, "mode", "depth", "states", "buffersize", "history", "periodsize", "likeliness",
+		"accuracy", "logloss", "brier", "mae", "rmse")
+	for i, trial := range leaderboard {
+		fmt.Printf("%-4d %10.4f %-4d %-5d %-6d %-10d %-7d %-12s %-10.2f %8.3f %8.3f %8.3f %10.2f %10.2f\n",
+			i+1, objectiveValue(trial), trial.Mode, trial.PeriodDepth,
+			trial.Settings.States, trial.Settings.BufferSize, trial.Settings.History,
+			periodSizeString(trial.Settings.PeriodSize), trial.Settings.PhaseChangeLikeliness,
+			trial.Score.Accuracy, trial.Score.LogLoss, trial.Score.Brier, trial.Score.MAE, trial.Score.RMSE)
+	}
+
+	if len(failed) > 0 {
+		fmt.Printf("\nfailed candidates\n")
+		for _, trial := range failed {
+			fmt.Printf("  %s: %s\n", trial, trial.Error)
+		}
+	}
+}
+
+// splitTrials returns the top successful trials and the failed trials
+func splitTrials(trials []eval.Trial) ([]eval.Trial, []eval.Trial) {
+	leaderboard := make([]eval.Trial, 0)
+	failed := make([]eval.Trial, 0)
+	for _, trial := range trials {
+		if trial.Error != "" {
+			failed = append(failed, trial)
+		} else if len(leaderboard) < options.Top {
+			leaderboard = append(leaderboard, trial)
+		}
+	}
+	return leaderboard, failed
+}
+
+// objectiveValue returns the ranked score as is, i.e. the accuracy not negated
+func objectiveValue(trial eval.Trial) float64 {
+	if options.Objective == "accuracy" {
+		return -trial.Objective
+	}
+	return trial.Objective
+}
+
+func periodSizeString(periodSize []int) string {
+	if len(periodSize) == 0 {
+		return "none"
+	}
+	return strings.Trim(strings.Replace(fmt.Sprint(periodSize), " ", ",", -1), "[]")
+}
@@ -0,0 +1,327 @@
+package eval
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/predictor"
+	"github.com/cha87de/tsprofiler/profiler"
+	"github.com/cha87de/tsprofiler/utils"
+)
+
+// Config defines the backtest of a time series
+type Config struct {
+	// Settings of the trained profiles, History is taken from Histories
+	Settings models.Settings
+
+	// FixedMin and FixedMax bound the values if Settings.FixBound is set
+	FixedMin float64
+	FixedMax float64
+
+	// Train is the fraction (0,1) of the time series to train the profile on
+	Train float64
+
+	// Steps is the amount of steps (discretized buffers) to forecast ahead
+	Steps int
+
+	// Modes are the prediction modes to evaluate
+	Modes []predictor.PredictionMode
+
+	// PeriodDepths are the period tree depths to evaluate in PredictionModePeriods
+	PeriodDepths []int
+
+	// Histories are the History settings to train profiles with
+	Histories []int
+}
+
+// observation is the profiler's position after a discretized buffer
+type observation struct {
+	states     map[string]models.TSState
+	phase      int
+	periodPath []int
+}
+
+// Backtest trains for each History setting a profile on the first part of the
+// rows (one value per metric metric_0, metric_1, ...), then walks the
+// remainder. At each discretized buffer, the predictor forecasts the next
+// `Steps` steps with the trained profile for each mode and period depth, which
+// are scored against the observed states and values of the remainder. The
+// remainder is discretized with the trained profile's stats, and phases and
+// period paths are followed within the trained profile like Predictor.Observe
+// does, hence the profile and its phase ids are frozen after the training.
+func Backtest(rows [][]float64, config Config) ([]Score, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	bufferSize := config.Settings.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+	// train on complete buffers only
+	trainRows := int(float64(len(rows))*config.Train) / bufferSize * bufferSize
+	if trainRows < bufferSize || len(rows)-trainRows < bufferSize*config.Steps {
+		return nil, fmt.Errorf("too few rows (%d) to train and forecast %d steps", len(rows), config.Steps)
+	}
+
+	scores := make([]Score, 0)
+	for _, history := range config.Histories {
+		settings := config.Settings
+		settings.History = history
+		profile, observations := observe(rows, trainRows, settings, config.FixedMin, config.FixedMax)
+		trainSteps := trainRows / bufferSize
+
+		for _, mode := range config.Modes {
+			periodDepths := []int{0}
+			if mode == predictor.PredictionModePeriods {
+				periodDepths = config.PeriodDepths
+			}
+			for _, periodDepth := range periodDepths {
+				metricScores, err := backtestMode(profile, observations, trainSteps, mode, periodDepth, config.Steps)
+				if err != nil {
+					return nil, fmt.Errorf("mode %d, period depth %d, history %d: %s", mode, periodDepth, history, err)
+				}
+				for _, metric := range sortedScoreMetrics(metricScores) {
+					score := metricScores[metric].score()
+					score.Mode = mode
+					score.PeriodDepth = periodDepth
+					score.History = history
+					score.Metric = metric
+					scores = append(scores, score)
+				}
+			}
+		}
+	}
+	return scores, nil
+}
+
+// Modes returns the prediction modes applicable to profiles of the settings
+func Modes(settings models.Settings) []predictor.PredictionMode {
+	modes := []predictor.PredictionMode{predictor.PredictionModeRootTx}
+	if settings.PhaseDetection() {
+		modes = append(modes, predictor.PredictionModePhases)
+	}
+	if len(settings.PeriodSize) > 0 {
+		modes = append(modes, predictor.PredictionModePeriods)
+	}
+	if settings.JointStates {
+		modes = append(modes, predictor.PredictionModeJoint)
+	}
+	return modes
+}
+
+// PeriodDepths returns all period depths of profiles of the settings
+func PeriodDepths(settings models.Settings) []int {
+	periodDepths := make([]int, 0, len(settings.PeriodSize)+1)
+	for depth := 0; depth <= len(settings.PeriodSize); depth++ {
+		periodDepths = append(periodDepths, depth)
+	}
+	return periodDepths
+}
+
+func (config *Config) validate() error {
+	if config.Train <= 0 || config.Train >= 1 {
+		return fmt.Errorf("invalid train fraction %v, must be in (0,1)", config.Train)
+	}
+	if config.Steps < 1 {
+		return fmt.Errorf("invalid amount of steps %d", config.Steps)
+	}
+	if len(config.Histories) == 0 {
+		return fmt.Errorf("no history settings to evaluate")
+	}
+	for _, history := range config.Histories {
+		if history < 1 {
+			return fmt.Errorf("invalid history %d", history)
+		}
+	}
+	for _, mode := range config.Modes {
+		switch mode {
+		case predictor.PredictionModeRootTx:
+		case predictor.PredictionModePhases:
+			if !config.Settings.PhaseDetection() {
+				return fmt.Errorf("mode %d requires phase detection (phase change likeliness or detector)", mode)
+			}
+		case predictor.PredictionModePeriods:
+			if len(config.Settings.PeriodSize) == 0 {
+				return fmt.Errorf("mode %d requires a period size", mode)
+			}
+			if len(config.PeriodDepths) == 0 {
+				return fmt.Errorf("mode %d requires period depths", mode)
+			}
+			for _, periodDepth := range config.PeriodDepths {
+				if periodDepth < 0 || periodDepth > len(config.Settings.PeriodSize) {
+					return fmt.Errorf("invalid period depth %d for %d period levels", periodDepth, len(config.Settings.PeriodSize))
+				}
+			}
+		case predictor.PredictionModeJoint:
+			if !config.Settings.JointStates {
+				return fmt.Errorf("mode %d requires joint states", mode)
+			}
+		default:
+			return fmt.Errorf("invalid mode %d", mode)
+		}
+	}
+	return nil
+}
+
+// observe puts the first trainRows rows to a new profiler and returns the
+// trained profile and the observations after each discretized buffer: the
+// profiler's during the training, then the remaining rows' discretized with
+// the trained profile's stats
+func observe(rows [][]float64, trainRows int, settings models.Settings, fixedMin float64, fixedMax float64) (models.TSProfile, []observation) {
+	tsprofiler := profiler.NewProfiler(settings)
+	defer tsprofiler.Terminate()
+
+	bufferSize := settings.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+	observations := make([]observation, 0, len(rows)/bufferSize)
+	for i, row := range rows[:trainRows] {
+		metrics := make([]models.TSInputMetric, len(row))
+		for m, value := range row {
+			metrics[m] = models.TSInputMetric{
+				Name:     fmt.Sprintf("metric_%d", m),
+				Value:    value,
+				FixedMin: fixedMin,
+				FixedMax: fixedMax,
+			}
+		}
+		tsprofiler.Put(models.TSInput{
+			Metrics: metrics,
+		})
+		if (i+1)%bufferSize != 0 {
+			continue
+		}
+		tsprofiler.Flush()
+		observations = append(observations, currentObservation(tsprofiler))
+	}
+	profile := tsprofiler.Get()
+
+	for first := trainRows; first+bufferSize <= len(rows); first += bufferSize {
+		observations = append(observations, observation{
+			states: discretize(profile, rows[first:first+bufferSize], fixedMin, fixedMax),
+		})
+	}
+	return profile, observations
+}
+
+// discretize returns per metric the state of the average of the rows, between
+// the fixed bounds if the profile has fixed bounds, else the root tx stats.
+// Metrics unknown to the profile are missing.
+func discretize(profile models.TSProfile, rows [][]float64, fixedMin float64, fixedMax float64) map[string]models.TSState {
+	states := make(map[string]models.TSState)
+	for m := range rows[0] {
+		metric := fmt.Sprintf("metric_%d", m)
+		for _, txmatrix := range profile.RootTx {
+			if txmatrix.Metric != metric {
+				continue
+			}
+			var sum float64
+			for _, row := range rows {
+				sum += row[m]
+			}
+			value := sum / float64(len(rows))
+			min, max := txmatrix.Stats.Min, txmatrix.Stats.Max
+			if profile.Settings.FixBound {
+				min, max = fixedMin, fixedMax
+			}
+			states[metric] = models.TSState{
+				Metric: metric,
+				State:  utils.ClosestDiscretize(value, profile.Settings.States, min, max),
+				Value:  value,
+			}
+		}
+	}
+	return states
+}
+
+func currentObservation(tsprofiler *profiler.Profiler) observation {
+	states := make(map[string]models.TSState)
+	for _, tsstate := range tsprofiler.GetCurrentState() {
+		if tsstate.Metric == "" {
+			// no valid state discretized
+			continue
+		}
+		states[tsstate.Metric] = tsstate
+	}
+	return observation{
+		states:     states,
+		phase:      tsprofiler.GetCurrentPhase(),
+		periodPath: append([]int{}, tsprofiler.GetCurrentPeriodPath()...),
+	}
+}
+
+// backtestMode forecasts from the end of the training and each observation
+// after it, and scores the forecasts per metric
+func backtestMode(profile models.TSProfile, observations []observation, trainSteps int, mode predictor.PredictionMode, periodDepth int, steps int) (map[string]*scorer, error) {
+	scorers := make(map[string]*scorer)
+	origin := trainSteps - 1
+
+	// start at the profiler's position at the end of the training
+	tspredictor := predictor.NewPredictor(profile)
+	tspredictor.SetMode(mode)
+	tspredictor.SetState(stateHistories(observations, origin, profile.Settings.History))
+	if observations[origin].phase < len(profile.Phases.Phases) {
+		tspredictor.SetPhase(observations[origin].phase)
+	}
+	tspredictor.SetPeriodPath(append([]int{}, observations[origin].periodPath...), periodDepth)
+
+	for ; origin+steps < len(observations); origin++ {
+		if origin >= trainSteps {
+			// move on by the observed states within the trained profile
+			states := make(map[string]int)
+			for metric, tsstate := range observations[origin].states {
+				states[metric] = int(tsstate.State.Value)
+			}
+			if err := tspredictor.Observe(states); err != nil {
+				return nil, err
+			}
+		}
+		forecast, err := tspredictor.Forecast(steps, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for metric, forecastSteps := range forecast {
+			if _, exists := scorers[metric]; !exists {
+				scorers[metric] = &scorer{}
+			}
+			for step, forecastStep := range forecastSteps {
+				observed, exists := observations[origin+step+1].states[metric]
+				if !exists {
+					continue
+				}
+				scorers[metric].add(forecastStep, int(observed.State.Value), observed.Value)
+			}
+		}
+	}
+	return scorers, nil
+}
+
+// stateHistories returns per metric the history of up to `history` states observed until origin
+func stateHistories(observations []observation, origin int, history int) map[string]string {
+	currentState := make(map[string]string)
+	for metric := range observations[origin].states {
+		states := make([]string, 0, history)
+		for i := origin; i >= 0 && origin-i < history; i-- {
+			tsstate, exists := observations[i].states[metric]
+			if !exists {
+				break
+			}
+			states = append([]string{fmt.Sprintf("%d", tsstate.State.Value)}, states...)
+		}
+		currentState[metric] = strings.Join(states, "-")
+	}
+	return currentState
+}
+
+func sortedScoreMetrics(scorers map[string]*scorer) []string {
+	metrics := make([]string, 0, len(scorers))
+	for metric := range scorers {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+	return metrics
+}
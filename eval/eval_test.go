@@ -0,0 +1,76 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/cha87de/tsprofiler/predictor"
+	"github.com/cha87de/tsprofiler/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBacktest(t *testing.T) {
+	Convey("Should forecast a deterministic cycle perfectly", t, func() {
+		// the states 0, 1, 2, 3 in turn
+		scores, err := Backtest(cycleRows(200, 0, 25, 50, 75), testConfig())
+		So(err, ShouldBeNil)
+		So(scores, ShouldHaveLength, 1)
+		So(scores[0].Metric, ShouldEqual, "metric_0")
+		// from the last trained buffer to the second last buffer
+		So(scores[0].Forecasts, ShouldEqual, 2*(200-100-1))
+		So(scores[0].Accuracy, ShouldEqual, 1)
+		So(scores[0].LogLoss, ShouldAlmostEqual, 0, 0.05)
+		So(scores[0].MAE, ShouldBeLessThan, 1)
+	})
+
+	Convey("Should score each mode, period depth and history", t, func() {
+		config := testConfig()
+		config.Settings.PeriodSize = []int{4}
+		config.Modes = []predictor.PredictionMode{predictor.PredictionModeRootTx, predictor.PredictionModePeriods}
+		config.PeriodDepths = PeriodDepths(config.Settings)
+		config.Histories = []int{1, 2}
+		scores, err := Backtest(cycleRows(200, 0, 25, 50, 75), config)
+		So(err, ShouldBeNil)
+		So(scores, ShouldHaveLength, 2*3)
+		So(scores[1].Mode, ShouldEqual, predictor.PredictionModePeriods)
+		So(scores[2].PeriodDepth, ShouldEqual, 1)
+		So(scores[5].History, ShouldEqual, 2)
+	})
+
+	Convey("Should reject invalid configs and too short series", t, func() {
+		config := testConfig()
+		config.Train = 1
+		_, err := Backtest(cycleRows(200, 0), config)
+		So(err, ShouldNotBeNil)
+
+		config = testConfig()
+		config.Modes = []predictor.PredictionMode{predictor.PredictionModePhases}
+		_, err = Backtest(cycleRows(200, 0), config)
+		So(err, ShouldNotBeNil)
+
+		_, err = Backtest(cycleRows(2, 0), testConfig())
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestObserve(t *testing.T) {
+	Convey("Should discretize the rows after the training with the trained profile's stats", t, func() {
+		config := testConfig()
+		config.Settings.FixBound = false
+		config.Settings.BufferSize = 2
+		// the values after the training exceed the trained max
+		rows := append(cycleRows(100, 0, 20, 40, 60), cycleRows(20, 100, 120)...)
+		profile, observations := observe(rows, 100, config.Settings, 0, 0)
+		So(observations, ShouldHaveLength, 60)
+		So(profile.RootTx[0].Stats.Max, ShouldBeLessThan, 100)
+
+		observed := observations[50].states["metric_0"]
+		So(observed.Value, ShouldEqual, 110)
+		So(observed.State, ShouldResemble, utils.ClosestDiscretize(110, 4, profile.RootTx[0].Stats.Min, profile.RootTx[0].Stats.Max))
+		So(observed.State.Value, ShouldEqual, 3)
+
+		// fixed bounds
+		config.Settings.FixBound = true
+		_, observations = observe(rows, 100, config.Settings, 0, 400)
+		So(observations[50].states["metric_0"].State.Value, ShouldEqual, 1)
+	})
+}
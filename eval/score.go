@@ -0,0 +1,143 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/cha87de/tsprofiler/predictor"
+)
+
+// minProbability limits the log-loss of observed states forecasted with probability 0
+const minProbability = 1e-6
+
+// Score holds the forecast scores of a metric, over all forecasted steps
+type Score struct {
+	Mode        predictor.PredictionMode `json:"mode"`
+	PeriodDepth int                      `json:"periodDepth"`
+	History     int                      `json:"history"`
+	Metric      string                   `json:"metric"`
+
+	// Forecasts is the amount of scored forecasted steps
+	Forecasts int `json:"forecasts"`
+
+	// Accuracy is the fraction of forecasts with the observed state as most probable state
+	Accuracy float64 `json:"accuracy"`
+
+	// LogLoss is the mean negative log probability of the observed states
+	LogLoss float64 `json:"logloss"`
+
+	// Brier is the mean squared error of the state probabilities
+	Brier float64 `json:"brier"`
+
+	// MAE and RMSE are the mean absolute and root mean squared error of the expected values
+	MAE  float64 `json:"mae"`
+	RMSE float64 `json:"rmse"`
+}
+
+// scorer sums up the errors of forecasted steps
+type scorer struct {
+	forecasts    int
+	hits         int
+	logLoss      float64
+	brier        float64
+	absError     float64
+	squaredError float64
+}
+
+func (scorer *scorer) add(forecastStep predictor.ForecastStep, state int, value float64) {
+	scorer.forecasts++
+
+	mostProbable := 0
+	for s, prob := range forecastStep.States {
+		if prob > forecastStep.States[mostProbable] {
+			mostProbable = s
+		}
+		observed := float64(0)
+		if s == state {
+			observed = 1
+		}
+		scorer.brier += (prob - observed) * (prob - observed)
+	}
+	if mostProbable == state {
+		scorer.hits++
+	}
+
+	prob := float64(0)
+	if state >= 0 && state < len(forecastStep.States) {
+		prob = forecastStep.States[state]
+	}
+	scorer.logLoss -= math.Log(math.Max(prob, minProbability))
+
+	diff := forecastStep.Expected - value
+	scorer.absError += math.Abs(diff)
+	scorer.squaredError += diff * diff
+}
+
+func (scorer *scorer) score() Score {
+	if scorer.forecasts == 0 {
+		return Score{}
+	}
+	n := float64(scorer.forecasts)
+	return Score{
+		Forecasts: scorer.forecasts,
+		Accuracy:  float64(scorer.hits) / n,
+		LogLoss:   scorer.logLoss / n,
+		Brier:     scorer.brier / n,
+		MAE:       scorer.absError / n,
+		RMSE:      math.Sqrt(scorer.squaredError / n),
+	}
+}
+
+// MeanScore combines the scores of several metrics, weighted by their
+// forecasts, as score of the metric "mean" with mode, period depth and history
+// of the first score
+func MeanScore(scores []Score) Score {
+	mean := Score{
+		Metric: "mean",
+	}
+	if len(scores) > 0 {
+		mean.Mode = scores[0].Mode
+		mean.PeriodDepth = scores[0].PeriodDepth
+		mean.History = scores[0].History
+	}
+	squaredError := float64(0)
+	for _, score := range scores {
+		n := float64(score.Forecasts)
+		mean.Forecasts += score.Forecasts
+		mean.Accuracy += score.Accuracy * n
+		mean.LogLoss += score.LogLoss * n
+		mean.Brier += score.Brier * n
+		mean.MAE += score.MAE * n
+		squaredError += score.RMSE * score.RMSE * n
+	}
+	if mean.Forecasts == 0 {
+		return mean
+	}
+	n := float64(mean.Forecasts)
+	mean.Accuracy /= n
+	mean.LogLoss /= n
+	mean.Brier /= n
+	mean.MAE /= n
+	mean.RMSE = math.Sqrt(squaredError / n)
+	return mean
+}
+
+// Objectives lists the scores to rank by, see (Score).Objective
+var Objectives = []string{"mae", "rmse", "logloss", "brier", "accuracy"}
+
+// Objective returns the named score, oriented so that lower is better
+func (score Score) Objective(objective string) (float64, error) {
+	switch objective {
+	case "mae":
+		return score.MAE, nil
+	case "rmse":
+		return score.RMSE, nil
+	case "logloss":
+		return score.LogLoss, nil
+	case "brier":
+		return score.Brier, nil
+	case "accuracy":
+		return -score.Accuracy, nil
+	}
+	return 0, fmt.Errorf("unknown objective %s", objective)
+}
@@ -0,0 +1,188 @@
+package eval
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/predictor"
+)
+
+// SearchSpace holds the candidate values of the settings to search, empty
+// lists keep the value of the base settings
+type SearchSpace struct {
+	States                []int
+	BufferSize            []int
+	History               []int
+	PeriodSize            [][]int
+	PhaseChangeLikeliness []float32
+}
+
+// TuneConfig defines a hyperparameter search
+type TuneConfig struct {
+	// Config is the backtest of each candidate, its Settings are the base
+	// settings, its Modes, PeriodDepths and Histories are ignored
+	Config Config
+
+	// Space holds the candidate values of the settings
+	Space SearchSpace
+
+	// Samples is the amount of random candidates, 0 to search the full grid
+	Samples int
+
+	// Seed of the random search
+	Seed int64
+
+	// Objective is the score to rank by, one of Objectives
+	Objective string
+
+	// Workers is the amount of parallel backtests, 0 for the amount of CPUs
+	Workers int
+}
+
+// Trial is the backtest of a candidate with a prediction mode and period depth
+type Trial struct {
+	Settings    models.Settings          `json:"settings"`
+	Mode        predictor.PredictionMode `json:"mode"`
+	PeriodDepth int                      `json:"periodDepth"`
+
+	// Score is the mean score of all metrics
+	Score Score `json:"score"`
+
+	// Objective is the value ranked by, lower is better
+	Objective float64 `json:"objective"`
+
+	// Error is set if the candidate could not be backtested
+	Error string `json:"error,omitempty"`
+}
+
+// Tune backtests the candidates of the search space in parallel, each with
+// independent profilers, and returns the trials of all applicable prediction
+// modes and period depths as leaderboard, best first. Note that state based
+// scores (accuracy, log-loss and Brier) are only comparable among candidates
+// with the same amount of states.
+func Tune(rows [][]float64, config TuneConfig) ([]Trial, error) {
+	if config.Objective == "" {
+		config.Objective = Objectives[0]
+	}
+	if _, err := (Score{}).Objective(config.Objective); err != nil {
+		return nil, err
+	}
+	candidates := config.Space.candidates(config.Config.Settings)
+	if config.Samples > 0 && config.Samples < len(candidates) {
+		random := rand.New(rand.NewSource(config.Seed))
+		sampled := make([]models.Settings, config.Samples)
+		for i, c := range random.Perm(len(candidates))[:config.Samples] {
+			sampled[i] = candidates[c]
+		}
+		candidates = sampled
+	}
+	workers := config.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([][]Trial, len(candidates))
+	candidateQueue := make(chan int)
+	wg := &sync.WaitGroup{}
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range candidateQueue {
+				// each candidate writes its own results only
+				results[c] = backtestCandidate(rows, config.Config, candidates[c], config.Objective)
+			}
+		}()
+	}
+	for c := range candidates {
+		candidateQueue <- c
+	}
+	close(candidateQueue)
+	wg.Wait()
+
+	trials := make([]Trial, 0)
+	for _, candidateTrials := range results {
+		trials = append(trials, candidateTrials...)
+	}
+	sort.SliceStable(trials, func(i, j int) bool {
+		if (trials[i].Error == "") != (trials[j].Error == "") {
+			return trials[i].Error == ""
+		}
+		return trials[i].Objective < trials[j].Objective
+	})
+	return trials, nil
+}
+
+// backtestCandidate backtests the settings with all applicable modes and period depths
+func backtestCandidate(rows [][]float64, config Config, settings models.Settings, objective string) []Trial {
+	config.Settings = settings
+	config.Histories = []int{settings.History}
+	config.Modes = Modes(settings)
+	config.PeriodDepths = PeriodDepths(settings)
+	scores, err := Backtest(rows, config)
+	if err != nil {
+		return []Trial{{
+			Settings: settings,
+			Error:    err.Error(),
+		}}
+	}
+
+	// group the metrics' scores by mode and period depth, in order
+	trials := make([]Trial, 0)
+	metricScores := make([]Score, 0)
+	for i, score := range scores {
+		metricScores = append(metricScores, score)
+		if i+1 < len(scores) && scores[i+1].Mode == score.Mode && scores[i+1].PeriodDepth == score.PeriodDepth {
+			continue
+		}
+		mean := MeanScore(metricScores)
+		value, _ := mean.Objective(objective)
+		trials = append(trials, Trial{
+			Settings:    settings,
+			Mode:        score.Mode,
+			PeriodDepth: score.PeriodDepth,
+			Score:       mean,
+			Objective:   value,
+		})
+		metricScores = make([]Score, 0)
+	}
+	return trials
+}
+
+// candidates returns all combinations of the search space's values, based on `base`
+func (space *SearchSpace) candidates(base models.Settings) []models.Settings {
+	candidates := []models.Settings{base}
+	expand := func(n int, set func(settings *models.Settings, i int)) {
+		if n == 0 {
+			return
+		}
+		expanded := make([]models.Settings, 0, len(candidates)*n)
+		for _, candidate := range candidates {
+			for i := 0; i < n; i++ {
+				settings := candidate
+				set(&settings, i)
+				expanded = append(expanded, settings)
+			}
+		}
+		candidates = expanded
+	}
+	expand(len(space.States), func(settings *models.Settings, i int) { settings.States = space.States[i] })
+	expand(len(space.BufferSize), func(settings *models.Settings, i int) { settings.BufferSize = space.BufferSize[i] })
+	expand(len(space.History), func(settings *models.Settings, i int) { settings.History = space.History[i] })
+	expand(len(space.PeriodSize), func(settings *models.Settings, i int) { settings.PeriodSize = space.PeriodSize[i] })
+	expand(len(space.PhaseChangeLikeliness), func(settings *models.Settings, i int) {
+		settings.PhaseChangeLikeliness = space.PhaseChangeLikeliness[i]
+	})
+	return candidates
+}
+
+// String describes the searched settings of the trial
+func (trial Trial) String() string {
+	return fmt.Sprintf("states %d, buffersize %d, history %d, periodsize %v, phasechangelikeliness %v",
+		trial.Settings.States, trial.Settings.BufferSize, trial.Settings.History,
+		trial.Settings.PeriodSize, trial.Settings.PhaseChangeLikeliness)
+}
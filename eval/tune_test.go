@@ -0,0 +1,77 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/predictor"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// cycleRows returns n rows of a single metric repeating `values`
+func cycleRows(n int, values ...float64) [][]float64 {
+	rows := make([][]float64, n)
+	for i := range rows {
+		rows[i] = []float64{values[i%len(values)]}
+	}
+	return rows
+}
+
+// testConfig backtests 4 states between the fixed bounds 0 and 100
+func testConfig() Config {
+	return Config{
+		Settings: models.Settings{
+			States:        4,
+			BufferSize:    1,
+			FilterStdDevs: 4,
+			FixBound:      true,
+		},
+		FixedMin:  0,
+		FixedMax:  100,
+		Train:     0.5,
+		Steps:     2,
+		Modes:     []predictor.PredictionMode{predictor.PredictionModeRootTx},
+		Histories: []int{1},
+	}
+}
+
+func TestTune(t *testing.T) {
+	// the states 0, 0, 3, 3 in turn: the next state depends on the last two
+	rows := cycleRows(400, 0, 0, 75, 75)
+
+	Convey("Should rank the setting predicting best first", t, func() {
+		trials, err := Tune(rows, TuneConfig{
+			Config:    testConfig(),
+			Space:     SearchSpace{History: []int{1, 2}},
+			Objective: "logloss",
+			Workers:   2,
+		})
+		So(err, ShouldBeNil)
+		So(trials, ShouldHaveLength, 2)
+		So(trials[0].Settings.History, ShouldEqual, 2)
+		So(trials[0].Score.Accuracy, ShouldEqual, 1)
+		So(trials[0].Objective, ShouldBeLessThan, trials[1].Objective)
+		So(trials[1].Settings.History, ShouldEqual, 1)
+		So(trials[1].Score.Accuracy, ShouldBeLessThan, 1)
+	})
+
+	Convey("Should sample random candidates reproducibly", t, func() {
+		config := TuneConfig{
+			Config:  testConfig(),
+			Space:   SearchSpace{States: []int{2, 4, 8}, History: []int{1, 2}},
+			Samples: 2,
+			Seed:    3,
+		}
+		trials, err := Tune(rows, config)
+		So(err, ShouldBeNil)
+		So(trials, ShouldHaveLength, 2)
+		again, err := Tune(rows, config)
+		So(err, ShouldBeNil)
+		So(again, ShouldResemble, trials)
+	})
+
+	Convey("Should reject unknown objectives", t, func() {
+		_, err := Tune(rows, TuneConfig{Config: testConfig(), Objective: "r2"})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -6,7 +6,6 @@ require (
 	github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b
 	github.com/jessevdk/go-flags v1.4.0
 	github.com/jinzhu/copier v0.0.0-20190924061706-b57f9002281a
-	github.com/jmcvetta/randutil v0.0.0-20150817122601-2bb1b664bcff
 	github.com/micro/go-micro v1.18.0 // indirect
 	github.com/smartystreets/goconvey v1.6.4
 	gonum.org/v1/gonum v0.6.2
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"math"
+	"sort"
+)
+
+// Anomaly sources, i.e. the tx matrices a transition's likeliness is computed on
+const (
+	AnomalySourceRoot   = "root"
+	AnomalySourcePhase  = "phase"
+	AnomalySourcePeriod = "period"
+)
+
+// minAnomalyLikeliness bounds the surprise of never seen transitions
+const minAnomalyLikeliness = 1e-6
+
+// AnomalyScore describes how unusual a discretized buffer is, compared to the
+// transitions counted before
+type AnomalyScore struct {
+	// Likeliness holds per source the mean likeliness [0,1] of all metrics
+	Likeliness map[string]float32 `json:"likeliness"`
+
+	// Surprise is the mean surprise (in bits) of all metrics, higher is more unusual
+	Surprise float64 `json:"surprise"`
+
+	// Metrics holds the scored metrics, the most surprising first
+	Metrics []MetricAnomaly `json:"metrics"`
+
+	// Phase and PeriodPath are the phase and period tree position the buffer was scored in
+	Phase      int   `json:"phase"`
+	PeriodPath []int `json:"periodPath,omitempty"`
+}
+
+// MetricAnomaly describes how unusual the transition of a single metric is
+type MetricAnomaly struct {
+	Metric string  `json:"metric"`
+	State  int64   `json:"state"`
+	Value  float64 `json:"value"`
+
+	// Likeliness holds per source the likeliness [0,1] of the transition,
+	// sources without known previous states are missing
+	Likeliness map[string]float32 `json:"likeliness"`
+
+	// Surprise is the mean over the sources of -log2(likeliness)
+	Surprise float64 `json:"surprise"`
+
+	// Contribution is the metric's share [0,1] of the summed surprise of all metrics
+	Contribution float64 `json:"contribution"`
+}
+
+// NewAnomalyScore combines the metrics' likeliness per source into an AnomalyScore
+func NewAnomalyScore(tsstates []TSState, likeliness map[string]map[string]float32) AnomalyScore {
+	score := AnomalyScore{
+		Likeliness: make(map[string]float32),
+		Metrics:    make([]MetricAnomaly, 0),
+	}
+	sourceCounts := make(map[string]int)
+	var surpriseSum float64
+	for _, tsstate := range tsstates {
+		metric := MetricAnomaly{
+			Metric:     tsstate.Metric,
+			State:      tsstate.State.Value,
+			Value:      tsstate.Value,
+			Likeliness: make(map[string]float32),
+		}
+		for source, metricLikeliness := range likeliness {
+			l, exists := metricLikeliness[tsstate.Metric]
+			if !exists {
+				continue
+			}
+			metric.Likeliness[source] = l
+			metric.Surprise += Surprise(l)
+			score.Likeliness[source] += l
+			sourceCounts[source]++
+		}
+		if len(metric.Likeliness) == 0 {
+			// no transition known yet to compare with
+			continue
+		}
+		metric.Surprise /= float64(len(metric.Likeliness))
+		surpriseSum += metric.Surprise
+		score.Metrics = append(score.Metrics, metric)
+	}
+	for source, count := range sourceCounts {
+		score.Likeliness[source] /= float32(count)
+	}
+	if len(score.Metrics) > 0 {
+		score.Surprise = surpriseSum / float64(len(score.Metrics))
+	}
+	for i := range score.Metrics {
+		if surpriseSum > 0 {
+			score.Metrics[i].Contribution = score.Metrics[i].Surprise / surpriseSum
+		}
+	}
+	sort.SliceStable(score.Metrics, func(i, j int) bool {
+		return score.Metrics[i].Surprise > score.Metrics[j].Surprise
+	})
+	return score
+}
+
+// Surprise returns the information content -log2(likeliness) in bits, never
+// seen transitions are bounded by a minimal likeliness
+func Surprise(likeliness float32) float64 {
+	return math.Log2(1 / math.Max(float64(likeliness), minAnomalyLikeliness))
+}
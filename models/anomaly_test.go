@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewAnomalyScore(t *testing.T) {
+
+	Convey("Should combine the metrics' likeliness per source", t, func() {
+		tsstates := []TSState{
+			{Metric: "cpu", State: State{Value: 1}},
+			{Metric: "io", State: State{Value: 3}},
+			{Metric: "net", State: State{Value: 0}},
+		}
+		score := NewAnomalyScore(tsstates, map[string]map[string]float32{
+			AnomalySourceRoot:   {"cpu": 1, "io": 0.25},
+			AnomalySourcePeriod: {"io": 0.0625},
+		})
+
+		So(score.Metrics, ShouldHaveLength, 2)
+		So(score.Metrics[0].Metric, ShouldEqual, "io")
+		So(score.Metrics[0].Surprise, ShouldAlmostEqual, 3)
+		So(score.Metrics[0].Contribution, ShouldAlmostEqual, 1)
+		So(score.Metrics[1].Metric, ShouldEqual, "cpu")
+		So(score.Metrics[1].Surprise, ShouldAlmostEqual, 0)
+		So(score.Surprise, ShouldAlmostEqual, 1.5)
+		So(score.Likeliness[AnomalySourceRoot], ShouldAlmostEqual, 0.625)
+		So(score.Likeliness[AnomalySourcePeriod], ShouldAlmostEqual, 0.0625)
+	})
+
+	Convey("Should bound the surprise of never seen transitions", t, func() {
+		So(Surprise(0), ShouldAlmostEqual, Surprise(minAnomalyLikeliness))
+		So(Surprise(0.5), ShouldAlmostEqual, 1)
+	})
+
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// Event describes a change point detected by the profiler, either a phase
+// change or a period boundary
+type Event struct {
+	// Step is the index of the discretized buffer the event occurred at, starting with 0
+	Step int64 `json:"step"`
+
+	// Timestamp is the time the buffer was processed
+	Timestamp time.Time `json:"timestamp"`
+
+	PhaseChanged   *PhaseChanged   `json:"phaseChanged,omitempty"`
+	PeriodBoundary *PeriodBoundary `json:"periodBoundary,omitempty"`
+}
+
+// PhaseChanged describes the change of the current phase
+type PhaseChanged struct {
+	From  int  `json:"from"`
+	To    int  `json:"to"`
+	IsNew bool `json:"isNew"`
+
+	// Likeliness is the likeliness of the current phase which triggered the change,
+	// e.g. the history likeliness which fell below the phase change likeliness
+	Likeliness float32 `json:"likeliness"`
+}
+
+// PeriodBoundary describes the move on to the next node in the period tree
+type PeriodBoundary struct {
+	// Level is the highest level of the period tree which moved on, i.e. the
+	// positions from Level on changed
+	Level int `json:"level"`
+
+	// Path is the new period path
+	Path []int `json:"path"`
+}
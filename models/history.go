@@ -1,31 +1,35 @@
 package models
 
 import (
-	"encoding/json"
-	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 )
 
 // History defines the historic path and next step for tspredictor
 type History struct {
+	Version        int                 `json:"version"`
 	CurrentPhase   int                 `json:"currentPhase"`
 	HistoricStates []map[string]string `json:"historicStates"`
 	PeriodPath     []int               `json:"periodPath"`
 	NextState      map[string]string   `json:"nextState"`
 }
 
+// ReadHistory reads a json encoded History from reader
+func ReadHistory(reader io.Reader) (History, error) {
+	byteValue, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return History{}, err
+	}
+	return UnmarshalHistoryJSON(byteValue)
+}
+
 // ReadHistoryFromFile reads History from json file and returns as object
-func ReadHistoryFromFile(filepath string) History {
+func ReadHistoryFromFile(filepath string) (History, error) {
 	filehandler, err := os.Open(filepath)
 	if err != nil {
-		fmt.Println(err)
+		return History{}, err
 	}
 	defer filehandler.Close()
-	byteValue, _ := ioutil.ReadAll(filehandler)
-
-	var history History
-	json.Unmarshal(byteValue, &history)
-
-	return history
+	return ReadHistory(filehandler)
 }
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JointTx holds the transitions between the joint states of all metrics,
+// preserving the correlation between the metrics
+type JointTx struct {
+	// Metrics lists the metrics in the order of their states in a joint state key
+	Metrics []string `json:"metrics"`
+
+	// Transitions maps a joint state key to the probabilities of the next joint states
+	Transitions map[string]JointStep `json:"transitions"`
+}
+
+// JointStep holds the probabilities [0,100] of the next joint states, stored
+// sparsely by joint state key, and the probability of the joint state itself
+type JointStep struct {
+	NextStateProbs map[string]int `json:"nextProbs"`
+	StepProb       int            `json:"probability"`
+}
+
+// JointKey returns the joint state key of the states, ordered like JointTx.Metrics
+func JointKey(states []int64) string {
+	parts := make([]string, len(states))
+	for i, state := range states {
+		parts[i] = strconv.FormatInt(state, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseJointKey returns the states of the joint state key
+func ParseJointKey(key string) ([]int64, error) {
+	parts := strings.Split(key, ",")
+	states := make([]int64, len(parts))
+	for i, part := range parts {
+		state, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid joint state key %s", key)
+		}
+		states[i] = state
+	}
+	return states, nil
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJointKey(t *testing.T) {
+
+	Convey("Should join and parse joint state keys", t, func() {
+		So(JointKey([]int64{3, 0, 12}), ShouldEqual, "3,0,12")
+		states, err := ParseJointKey("3,0,12")
+		So(err, ShouldBeNil)
+		So(states, ShouldResemble, []int64{3, 0, 12})
+		_, err = ParseJointKey("3-0")
+		So(err, ShouldNotBeNil)
+	})
+
+}
@@ -0,0 +1,206 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// ProfileVersion is the current schema version of TSProfile. It is
+	// increased with every change of the model, additive fields included,
+	// hence older readers refuse newer profiles instead of misreading them.
+	ProfileVersion = 2
+
+	// HistoryVersion is the current schema version of History
+	HistoryVersion = 1
+)
+
+// migration upgrades a generic json document by one version
+type migration func(document map[string]interface{}) error
+
+// profileMigrations upgrade TSProfile documents, index i migrates from version i to i+1
+var profileMigrations = []migration{
+	migrateProfileV0,
+	migrateProfileV1,
+}
+
+// historyMigrations upgrade History documents, index i migrates from version i to i+1
+var historyMigrations = []migration{
+	migrateHistoryV0,
+}
+
+// MigrateProfileJSON upgrades a json encoded TSProfile of any older version to ProfileVersion
+func MigrateProfileJSON(data []byte) ([]byte, error) {
+	document, err := decodeDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(document, ProfileVersion, profileMigrations); err != nil {
+		return nil, err
+	}
+	return json.Marshal(document)
+}
+
+// UnmarshalProfileJSON migrates and decodes a json encoded TSProfile. If the
+// document does not match the model exactly, the decoded profile is returned
+// together with a *SchemaError.
+func UnmarshalProfileJSON(data []byte) (TSProfile, error) {
+	var profile TSProfile
+	document, err := decodeDocument(data)
+	if err != nil {
+		return profile, err
+	}
+	if err := migrate(document, ProfileVersion, profileMigrations); err != nil {
+		return profile, err
+	}
+	err = decodeStrict(document, &profile)
+	return profile, err
+}
+
+// UnmarshalHistoryJSON migrates and decodes a json encoded History. If the
+// document does not match the model exactly, the decoded history is returned
+// together with a *SchemaError.
+func UnmarshalHistoryJSON(data []byte) (History, error) {
+	var history History
+	document, err := decodeDocument(data)
+	if err != nil {
+		return history, err
+	}
+	if err := migrate(document, HistoryVersion, historyMigrations); err != nil {
+		return history, err
+	}
+	err = decodeStrict(document, &history)
+	return history, err
+}
+
+// migrate applies all migrations from the document's version up to the current version
+func migrate(document map[string]interface{}, current int, migrations []migration) error {
+	version, err := documentVersion(document)
+	if err != nil {
+		return err
+	}
+	if version > current {
+		return fmt.Errorf("version %d is newer than the supported version %d", version, current)
+	}
+	for ; version < current; version++ {
+		if err := migrations[version](document); err != nil {
+			return fmt.Errorf("cannot migrate from version %d: %s", version, err)
+		}
+		document["version"] = version + 1
+	}
+	return nil
+}
+
+// documentVersion returns the version field of a document, 0 if not present
+func documentVersion(document map[string]interface{}) (int, error) {
+	raw, exists := document["version"]
+	if !exists {
+		return 0, nil
+	}
+	number, ok := raw.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("invalid version %v", raw)
+	}
+	version, err := number.Int64()
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid version %v", raw)
+	}
+	return int(version), nil
+}
+
+// fillDefaults adds all fields of defaults (encoded as json) which are missing in the object
+func fillDefaults(object map[string]interface{}, defaults interface{}) error {
+	data, err := json.Marshal(defaults)
+	if err != nil {
+		return err
+	}
+	defaultObject, err := decodeDocument(data)
+	if err != nil {
+		return err
+	}
+	for key, value := range defaultObject {
+		if _, exists := object[key]; !exists {
+			object[key] = value
+		}
+	}
+	return nil
+}
+
+// migrateProfileV0 upgrades unversioned profiles: period tree nodes had an
+// untagged "UUID" field and older profiles may lack phases or settings fields
+func migrateProfileV0(document map[string]interface{}) error {
+	if err := fillDefaults(document, TSProfile{
+		RootTx: make([]TxMatrix, 0),
+		Phases: Phases{
+			Phases: make([][]TxMatrix, 0),
+		},
+	}); err != nil {
+		return err
+	}
+
+	// settings
+	if settings, ok := document["settings"].(map[string]interface{}); ok {
+		if err := fillDefaults(settings, Settings{}); err != nil {
+			return err
+		}
+	}
+
+	// period tree nodes
+	if periodTree, ok := document["periodTree"].(map[string]interface{}); ok {
+		if root, ok := periodTree["root"].(map[string]interface{}); ok {
+			if err := migratePeriodTreeNodeV0(root); err != nil {
+				return err
+			}
+		} else {
+			periodTree["root"] = NewPeriodTreeNode([]int{})
+		}
+	}
+	return nil
+}
+
+func migratePeriodTreeNodeV0(node map[string]interface{}) error {
+	if uuid, exists := node["UUID"]; exists {
+		node["uuid"] = uuid
+		delete(node, "UUID")
+	}
+	if err := fillDefaults(node, PeriodTreeNode{
+		Children: make([]PeriodTreeNode, 0),
+		TxMatrix: make([]TxMatrix, 0),
+	}); err != nil {
+		return err
+	}
+	if children, ok := node["children"].([]interface{}); ok {
+		for _, child := range children {
+			if childNode, ok := child.(map[string]interface{}); ok {
+				if err := migratePeriodTreeNodeV0(childNode); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// migrateProfileV1 upgrades profiles of version 1. Version 2 added optional
+// fields (the settings of the context pruning and of the phase detectors, the
+// joint tx, the phase meta, the state stats and dwell times of tx matrices),
+// whose absence keeps the behavior of version 1, except for the context
+// pruning: version 1 kept all histories unpruned.
+func migrateProfileV1(document map[string]interface{}) error {
+	settings, ok := document["settings"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if _, exists := settings["contextMinCount"]; !exists {
+		settings["contextMinCount"] = -1
+	}
+	return nil
+}
+
+// migrateHistoryV0 upgrades unversioned histories, which may lack fields
+func migrateHistoryV0(document map[string]interface{}) error {
+	return fillDefaults(document, History{
+		HistoricStates: make([]map[string]string, 0),
+		PeriodPath:     make([]int, 0),
+	})
+}
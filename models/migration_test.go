@@ -0,0 +1,119 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMigration(t *testing.T) {
+
+	profileV0 := `{
+		"name": "csv2tsprofile",
+		"roottx": [
+			{
+				"metric": "metric_0",
+				"transitions": {
+					"0": {
+						"nextProbs": [0, 100],
+						"probability": 100
+					}
+				},
+				"stats": {"min": 0, "max": 100, "stddev": 1, "avg": 50, "count": 10, "stddevsum": 10}
+			}
+		],
+		"periodTree": {
+			"root": {
+				"UUID": 840,
+				"maxChilds": 1,
+				"maxCounts": 2,
+				"children": [
+					{
+						"UUID": 630,
+						"maxChilds": 0,
+						"maxCounts": 2,
+						"children": [],
+						"txmatrix": []
+					}
+				],
+				"txmatrix": []
+			}
+		},
+		"settings": {
+			"buffersize": 1,
+			"states": 2,
+			"history": 1,
+			"filterstddevs": 2,
+			"fixbound": false,
+			"periodsize": [1, 2]
+		}
+	}`
+
+	Convey("Should migrate unversioned profiles", t, func() {
+		profile, err := UnmarshalProfileJSON([]byte(profileV0))
+		So(err, ShouldBeNil)
+		So(profile.Version, ShouldEqual, ProfileVersion)
+		So(profile.RootTx[0].Transitions["0"].NextStateProbs, ShouldResemble, []int{0, 100})
+		So(profile.PeriodTree.Root.UUID, ShouldEqual, 840)
+		So(profile.PeriodTree.GetNode([]int{0}).UUID, ShouldEqual, 630)
+		So(profile.Settings.PeriodSize, ShouldResemble, []int{1, 2})
+		So(len(profile.Phases.Phases), ShouldEqual, 0)
+
+		migrated, err := MigrateProfileJSON([]byte(profileV0))
+		So(err, ShouldBeNil)
+		var document map[string]interface{}
+		json.Unmarshal(migrated, &document)
+		So(document["version"], ShouldEqual, ProfileVersion)
+		So(document["phases"], ShouldNotBeNil)
+	})
+
+	Convey("Should report unknown and missing fields", t, func() {
+		profile, err := UnmarshalProfileJSON([]byte(`{
+			"version": 1,
+			"name": "test",
+			"unknown": true,
+			"roottx": [{"metric": "metric_0", "stats": {}}]
+		}`))
+		So(profile.Name, ShouldEqual, "test")
+		So(err, ShouldHaveSameTypeAs, &SchemaError{})
+		schemaErr := err.(*SchemaError)
+		So(schemaErr.Unknown, ShouldResemble, []string{"unknown"})
+		So(schemaErr.Missing, ShouldContain, "roottx[0].transitions")
+		So(schemaErr.Missing, ShouldContain, "roottx[0].stats.avg")
+		So(schemaErr.Missing, ShouldContain, "settings")
+	})
+
+	Convey("Should migrate version 1 profiles", t, func() {
+		migrated, err := MigrateProfileJSON([]byte(`{"version": 1, "name": "test"}`))
+		So(err, ShouldBeNil)
+		var document map[string]interface{}
+		json.Unmarshal(migrated, &document)
+		So(document["version"], ShouldEqual, 2)
+		So(document["name"], ShouldEqual, "test")
+
+		// version 1 kept all histories unpruned
+		profile, _ := UnmarshalProfileJSON([]byte(`{"version": 1, "settings": {"history": 3}}`))
+		So(profile.Settings.ContextMinCount, ShouldEqual, -1)
+		profile, _ = UnmarshalProfileJSON([]byte(`{"version": 1, "settings": {"history": 3, "contextMinCount": 4}}`))
+		So(profile.Settings.ContextMinCount, ShouldEqual, 4)
+		profile, _ = UnmarshalProfileJSON([]byte(`{"version": 2, "settings": {"history": 3}}`))
+		So(profile.Settings.ContextMinCount, ShouldEqual, 0)
+	})
+
+	Convey("Should refuse newer versions", t, func() {
+		_, err := UnmarshalProfileJSON([]byte(`{"version": 99}`))
+		So(err, ShouldNotBeNil)
+		_, err = UnmarshalHistoryJSON([]byte(`{"version": 99}`))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Should migrate unversioned histories", t, func() {
+		history, err := UnmarshalHistoryJSON([]byte(`{"currentPhase": 2, "historicStates": [{"metric_0": "1"}]}`))
+		So(err, ShouldBeNil)
+		So(history.Version, ShouldEqual, HistoryVersion)
+		So(history.CurrentPhase, ShouldEqual, 2)
+		So(history.HistoricStates[0]["metric_0"], ShouldEqual, "1")
+	})
+
+}
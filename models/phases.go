@@ -7,4 +7,28 @@ type Phases struct {
 
 	// Tx holds the transitions between the phases
 	Tx TxMatrix `json:"tx"`
+
+	// Meta holds the metadata of each detected phase, in the order of Phases
+	Meta []PhaseMeta `json:"meta,omitempty"`
+}
+
+// PhaseMeta describes the occurrences of a detected phase
+type PhaseMeta struct {
+	// FirstSeen is the step (index of the discretized buffer) the phase was first current at
+	FirstSeen int64 `json:"firstSeen"`
+
+	// LastSeen is the step the phase was last current at
+	LastSeen int64 `json:"lastSeen"`
+
+	// Visits is how often the phase became the current phase
+	Visits int64 `json:"visits"`
+
+	// States is the total amount of states (discretized buffers) spent in the phase
+	States int64 `json:"states"`
+
+	// MeanDuration is the mean amount of states spent in the phase per visit
+	MeanDuration float64 `json:"meanDuration"`
+
+	// Stats summarizes per metric the values (buffer averages) counted in the phase
+	Stats map[string]TSStats `json:"stats"`
 }
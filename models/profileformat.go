@@ -0,0 +1,100 @@
+package models
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+// ProfileFormat defines the serialization format of a TSProfile
+type ProfileFormat string
+
+const (
+	// ProfileFormatAuto detects the format from the encoded data
+	ProfileFormatAuto ProfileFormat = "auto"
+
+	// ProfileFormatJSON encodes profiles as JSON
+	ProfileFormatJSON ProfileFormat = "json"
+
+	// ProfileFormatBinary encodes profiles in the compact binary format
+	ProfileFormatBinary ProfileFormat = "binary"
+)
+
+// profileBinaryMagic prefixes every binary encoded profile
+var profileBinaryMagic = []byte("TSPB")
+
+// tsProfileWire strips the methods of TSProfile, so gob does not call them recursively
+type tsProfileWire TSProfile
+
+// Marshal encodes the profile in the given format
+func (profile *TSProfile) Marshal(format ProfileFormat) ([]byte, error) {
+	switch format {
+	case ProfileFormatJSON:
+		return json.Marshal(profile)
+	case ProfileFormatBinary:
+		return profile.MarshalBinary()
+	default:
+		return nil, fmt.Errorf("unknown profile format %q", format)
+	}
+}
+
+// MarshalBinary encodes the profile in the compact binary format: a gob
+// stream with varint packed, sparse transition matrices
+func (profile *TSProfile) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(profileBinaryMagic)
+	wire := tsProfileWire(*profile)
+	if err := gob.NewEncoder(&buf).Encode(&wire); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a profile encoded with MarshalBinary
+func (profile *TSProfile) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, profileBinaryMagic) {
+		return fmt.Errorf("not a binary encoded profile")
+	}
+	var wire tsProfileWire
+	if err := gob.NewDecoder(bytes.NewReader(data[len(profileBinaryMagic):])).Decode(&wire); err != nil {
+		return err
+	}
+	if wire.Version > ProfileVersion {
+		return fmt.Errorf("version %d is newer than the supported version %d", wire.Version, ProfileVersion)
+	}
+	*profile = TSProfile(wire)
+	if profile.Version < 2 && profile.Settings.ContextMinCount == 0 {
+		// like migrateProfileV1: version 1 kept all histories unpruned
+		profile.Settings.ContextMinCount = -1
+	}
+	profile.Version = ProfileVersion
+	return nil
+}
+
+// DetectProfileFormat returns the format of the encoded profile data
+func DetectProfileFormat(data []byte) ProfileFormat {
+	if bytes.HasPrefix(data, profileBinaryMagic) {
+		return ProfileFormatBinary
+	}
+	return ProfileFormatJSON
+}
+
+// UnmarshalProfile decodes a profile in the given format, or detects the
+// format if ProfileFormatAuto is given. JSON profiles of older versions are
+// migrated, see UnmarshalProfileJSON.
+func UnmarshalProfile(data []byte, format ProfileFormat) (TSProfile, error) {
+	var profile TSProfile
+	if format == ProfileFormatAuto || format == "" {
+		format = DetectProfileFormat(data)
+	}
+	switch format {
+	case ProfileFormatJSON:
+		return UnmarshalProfileJSON(data)
+	case ProfileFormatBinary:
+		err := profile.UnmarshalBinary(data)
+		return profile, err
+	default:
+		return profile, fmt.Errorf("unknown profile format %q", format)
+	}
+}
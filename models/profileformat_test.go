@@ -0,0 +1,92 @@
+package models
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProfileFormat(t *testing.T) {
+
+	profile := TSProfile{
+		Name: "test",
+		RootTx: []TxMatrix{
+			{
+				Metric: "metric_0",
+				Transitions: map[string]TXStep{
+					"0":   {NextStateProbs: []int{0, 100, 0, 0}, StepProb: 40},
+					"1":   {NextStateProbs: []int{25, 0, 0, 75}, StepProb: 35},
+					"3-1": {NextStateProbs: []int{0, 0, 0, 100}, StepProb: 25},
+				},
+				Stats: TSStats{Min: 1.5, Max: 97.25, Stddev: 3.2, Avg: 42.1, Count: 120, StddevSum: 12.5},
+				StateStats: []TSStats{
+					{Min: 1.5, Max: 10, Stddev: 2.1, Avg: 5.5, Count: 48, StddevSum: 211.68},
+					{Min: 20, Max: 35.5, Stddev: 4, Avg: 27, Count: 42, StddevSum: 672},
+					{},
+					{Min: 80, Max: 97.25, Stddev: 5, Avg: 90, Count: 30, StddevSum: 750},
+				},
+				DwellTimes: [][]int{{0, 3, 1}, {2}, {0}, {1, 0, 0, 4}},
+			},
+		},
+		PeriodTree: NewPeriodTree([]int{2, 3}),
+		Phases: Phases{
+			Phases: [][]TxMatrix{},
+			Tx: TxMatrix{
+				Metric:      "phasetx",
+				Transitions: map[string]TXStep{},
+			},
+		},
+		Settings: Settings{
+			States:     4,
+			History:    2,
+			PeriodSize: []int{2, 3},
+		},
+	}
+
+	Convey("Should encode and decode binary profiles", t, func() {
+		data, err := profile.Marshal(ProfileFormatBinary)
+		So(err, ShouldBeNil)
+		So(DetectProfileFormat(data), ShouldEqual, ProfileFormatBinary)
+
+		decoded, err := UnmarshalProfile(data, ProfileFormatAuto)
+		So(err, ShouldBeNil)
+		So(decoded.Name, ShouldEqual, profile.Name)
+		So(decoded.RootTx, ShouldResemble, profile.RootTx)
+		So(decoded.Settings.PeriodSize, ShouldResemble, profile.Settings.PeriodSize)
+		So(len(decoded.PeriodTree.Root.Children), ShouldEqual, 2)
+		So(decoded.PeriodTree.Root.MaxCounts, ShouldEqual, 6)
+	})
+
+	Convey("Should detect and decode json profiles", t, func() {
+		data, err := profile.Marshal(ProfileFormatJSON)
+		So(err, ShouldBeNil)
+		So(DetectProfileFormat(data), ShouldEqual, ProfileFormatJSON)
+
+		decoded, err := UnmarshalProfile(data, ProfileFormatAuto)
+		So(err, ShouldBeNil)
+		So(decoded.RootTx, ShouldResemble, profile.RootTx)
+	})
+
+	Convey("Should migrate the context pruning of binary version 1 profiles", t, func() {
+		old := profile
+		old.Version = 1
+		data, _ := old.Marshal(ProfileFormatBinary)
+		decoded, err := UnmarshalProfile(data, ProfileFormatAuto)
+		So(err, ShouldBeNil)
+		So(decoded.Version, ShouldEqual, ProfileVersion)
+		So(decoded.Settings.ContextMinCount, ShouldEqual, -1)
+
+		current := profile
+		current.Version = ProfileVersion
+		data, _ = current.Marshal(ProfileFormatBinary)
+		decoded, _ = UnmarshalProfile(data, ProfileFormatAuto)
+		So(decoded.Settings.ContextMinCount, ShouldEqual, 0)
+	})
+
+	Convey("Should reject corrupt binary profiles", t, func() {
+		data, _ := profile.Marshal(ProfileFormatBinary)
+		_, err := UnmarshalProfile(data[:len(data)/2], ProfileFormatAuto)
+		So(err, ShouldNotBeNil)
+	})
+
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// maxSchemaErrorFields limits the amount of fields listed in SchemaError's message
+const maxSchemaErrorFields = 10
+
+// SchemaError lists the fields of an encoded document which do not match the model
+type SchemaError struct {
+	Unknown []string
+	Missing []string
+}
+
+func (schemaErr *SchemaError) Error() string {
+	parts := make([]string, 0)
+	if len(schemaErr.Unknown) > 0 {
+		parts = append(parts, "unknown fields: "+listFields(schemaErr.Unknown))
+	}
+	if len(schemaErr.Missing) > 0 {
+		parts = append(parts, "missing fields: "+listFields(schemaErr.Missing))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func listFields(fields []string) string {
+	if len(fields) <= maxSchemaErrorFields {
+		return strings.Join(fields, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(fields[:maxSchemaErrorFields], ", "), len(fields)-maxSchemaErrorFields)
+}
+
+// decodeDocument decodes json into a generic document, keeping numbers as json.Number
+func decodeDocument(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document map[string]interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	if document == nil {
+		return nil, fmt.Errorf("document is empty")
+	}
+	return document, nil
+}
+
+// decodeStrict decodes the generic document into target and returns a
+// *SchemaError if the document has unknown or misses required fields.
+// Fields tagged with omitempty are optional.
+func decodeStrict(document map[string]interface{}, target interface{}) error {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return err
+	}
+	schemaErr := &SchemaError{}
+	checkSchema(document, reflect.TypeOf(target), "", schemaErr)
+	if len(schemaErr.Unknown) > 0 || len(schemaErr.Missing) > 0 {
+		sort.Strings(schemaErr.Unknown)
+		sort.Strings(schemaErr.Missing)
+		return schemaErr
+	}
+	return nil
+}
+
+// checkSchema walks through the generic document along the json fields of type t
+func checkSchema(document interface{}, t reflect.Type, path string, schemaErr *SchemaError) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := document.(map[string]interface{})
+		if !ok {
+			// type mismatches are reported by the json decoder
+			return
+		}
+		known := make(map[string]bool)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, optional, skip := jsonField(field)
+			if skip {
+				continue
+			}
+			known[strings.ToLower(name)] = true
+			value, exists := object[name]
+			if !exists {
+				if !optional {
+					schemaErr.Missing = append(schemaErr.Missing, joinPath(path, name))
+				}
+				continue
+			}
+			checkSchema(value, field.Type, joinPath(path, name), schemaErr)
+		}
+		for key := range object {
+			// encoding/json matches field names case insensitive
+			if !known[strings.ToLower(key)] {
+				schemaErr.Unknown = append(schemaErr.Unknown, joinPath(path, key))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		list, ok := document.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range list {
+			checkSchema(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), schemaErr)
+		}
+	case reflect.Map:
+		object, ok := document.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, value := range object {
+			checkSchema(value, t.Elem(), joinPath(path, key), schemaErr)
+		}
+	}
+}
+
+// jsonField returns the json name of a struct field, if it is optional
+// (omitempty) and if it is skipped by encoding/json
+func jsonField(field reflect.StructField) (string, bool, bool) {
+	if field.PkgPath != "" {
+		// unexported field
+		return "", false, true
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	if field.Type.Kind() == reflect.Func || field.Type.Kind() == reflect.Chan {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	optional := false
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			optional = true
+		}
+	}
+	return name, optional, false
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -18,6 +18,11 @@ type Settings struct {
 	// History defines the amount of previous, historic state changes to be considered
 	History int `json:"history"`
 
+	// ContextMinCount defines how often a history longer than one state must be counted to be kept in the tx matrices, 0 for the default 2. A negative value keeps all histories with their observed frequencies, unpruned and unsmoothed
+	ContextMinCount int64 `json:"contextMinCount,omitempty"`
+	// ContextMinDivergence defines how much the next states of a history longer than one state must diverge from the next states of the history without its oldest state to be kept, 0 for the default 0.01, negative to keep them regardless of their divergence
+	ContextMinDivergence float32 `json:"contextMinDivergence,omitempty"`
+
 	// FilterStdDevs defines the amount of stddevs which are max. allowed for data items before skipped as outliers
 	FilterStdDevs int `json:"filterstddevs"`
 
@@ -30,13 +35,71 @@ type Settings struct {
 	// OutputCallback defines the callback function for `TSProfile`s every `OutputFreq`
 	OutputCallback func(data TSProfile) `json:"-"`
 
+	// AnomalyCallback is called with the anomaly score of each completed buffer, before it is counted.
+	// Like EventCallback, it is called by the profiler's input goroutine before the Score or Flush call
+	// returns, hence it must not call Put, Score or Flush itself (they would block forever)
+	AnomalyCallback func(score AnomalyScore) `json:"-"`
+
+	// EventCallback is called with the change points detected, i.e. phase changes and period boundaries,
+	// after the AnomalyCallback and like it before the Score or Flush call returns
+	EventCallback func(event Event) `json:"-"`
+
 	// PeriodSize defines the amount and size of periods
 	PeriodSize []int `json:"periodsize"`
 
 	// Phase Change Detection settings (likeliness over history)
 	PhaseChangeLikeliness float32 `json:"phaseChangeLikeliness"`
-	// Phase Change Detection settings (state history length)
+	// Phase Change Detection settings (state history length, also the states existing phases are matched against on a change, at least 2)
 	PhaseChangeHistory int64 `json:"phaseChangeHistory"`
 	// Phase Change Detection settings (state history fade out)
 	PhaseChangeHistoryFadeout bool `json:"phaseChangeHistoryFadeout"`
+	// Phase Change Detection settings (detector of phase changes, defaults to the likeliness over history)
+	PhaseDetector PhaseDetector `json:"phaseDetector,omitempty"`
+	// Phase Change Detection settings (CUSUM detector: tolerated drop of likeliness per state, defaults to 0.05)
+	PhaseCusumDrift float32 `json:"phaseCusumDrift,omitempty"`
+	// Phase Change Detection settings (CUSUM detector: cumulative drop of likeliness to detect a change, defaults to 2)
+	PhaseCusumThreshold float32 `json:"phaseCusumThreshold,omitempty"`
+	// Phase Change Detection settings (Bayesian detector: prior probability of a phase change per state, defaults to 0.01)
+	PhaseHazard float32 `json:"phaseHazard,omitempty"`
+
+	// Phase consolidation settings (max. amount of phases, the most similar phases are merged, 0 for no limit)
+	MaxPhases int `json:"maxPhases,omitempty"`
+	// Phase consolidation settings (phases diverging less are merged, Jensen-Shannon divergence [0,1], 0 to disable)
+	PhaseMergeDivergence float32 `json:"phaseMergeDivergence,omitempty"`
+	// Phase consolidation settings (phases counted less buffers are pruned, i.e. merged into the most similar phase, 0 to disable)
+	PhaseMinCount int64 `json:"phaseMinCount,omitempty"`
+	// Phase consolidation settings (amount of buffers between consolidations besides on new phases, 0 to consolidate on new phases only)
+	PhaseConsolidationInterval int64 `json:"phaseConsolidationInterval,omitempty"`
+
+	// JointStates enables counting the transitions of the joint states of all metrics (root tx only, without history)
+	JointStates bool `json:"jointStates,omitempty"`
+
+	// DwellTimes enables recording per state the histogram of the dwell times, i.e. how many states a metric stays in a state (root tx and phases), and of the phases in the phase tx
+	DwellTimes bool `json:"dwellTimes,omitempty"`
+}
+
+// PhaseDetector defines the detector of phase changes
+type PhaseDetector string
+
+const (
+	// PhaseDetectorLikeliness detects a change when the likeliness over the
+	// phase change history falls below the phase change likeliness
+	PhaseDetectorLikeliness PhaseDetector = "likeliness"
+
+	// PhaseDetectorCusum detects a change when the cumulative sum of the
+	// likeliness' drops exceeds the CUSUM threshold
+	PhaseDetectorCusum PhaseDetector = "cusum"
+
+	// PhaseDetectorBayesian detects a change by Bayesian online change point
+	// detection on the likeliness
+	PhaseDetectorBayesian PhaseDetector = "bocpd"
+)
+
+// PhaseDetection returns whether phase detection is enabled, i.e. a phase
+// change likeliness or a detector other than the likeliness detector is set
+func (settings Settings) PhaseDetection() bool {
+	if settings.PhaseDetector != "" && settings.PhaseDetector != PhaseDetectorLikeliness {
+		return true
+	}
+	return settings.PhaseChangeLikeliness != 0
 }
@@ -16,3 +16,10 @@ type PeriodTree struct {
 func (periodTree *PeriodTree) GetNode(path []int) *PeriodTreeNode {
 	return periodTree.Root.GetNode(path)
 }
+
+// Copy returns a deep copy of the PeriodTree
+func (periodTree *PeriodTree) Copy() PeriodTree {
+	return PeriodTree{
+		Root: periodTree.Root.Copy(),
+	}
+}
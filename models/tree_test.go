@@ -167,3 +167,31 @@ func TestTreeGetNode(t *testing.T) {
 	})
 
 }
+
+func TestNewPeriodTree(t *testing.T) {
+
+	tree := NewPeriodTree([]int{2, 3})
+
+	Convey("Should number nodes deterministically in pre-order", t, func() {
+		So(tree.GetNode([]int{}).UUID, ShouldEqual, 0)
+		So(tree.GetNode([]int{0}).UUID, ShouldEqual, 1)
+		So(tree.GetNode([]int{1}).UUID, ShouldEqual, 2)
+		So(NewPeriodTree([]int{2, 3}), ShouldResemble, tree)
+	})
+
+	Convey("Should copy the tree deeply", t, func() {
+		original := NewPeriodTree([]int{2, 3})
+		original.GetNode([]int{1}).TxMatrix = []TxMatrix{{
+			Metric:      "metric_0",
+			Transitions: map[string]TXStep{"0": {NextStateProbs: []int{50, 50}, StepProb: 100}},
+		}}
+		copied := original.Copy()
+		So(copied, ShouldResemble, original)
+
+		original.GetNode([]int{1}).TxMatrix[0].Transitions["0"].NextStateProbs[0] = 0
+		original.GetNode([]int{0}).MaxCounts = 0
+		So(copied.GetNode([]int{1}).TxMatrix[0].Transitions["0"].NextStateProbs[0], ShouldEqual, 50)
+		So(copied.GetNode([]int{0}).MaxCounts, ShouldEqual, 3)
+	})
+
+}
@@ -1,11 +1,15 @@
 package models
 
-import (
-	"math/rand"
-)
-
-// NewPeriodTreeNode instantiates and returns a PeriodTreeNode with `size` children (recursively)
+// NewPeriodTreeNode instantiates and returns a PeriodTreeNode with `size`
+// children (recursively). Nodes are numbered in pre-order, starting with 0.
 func NewPeriodTreeNode(size []int) PeriodTreeNode {
+	nextUUID := 0
+	return newPeriodTreeNode(size, &nextUUID)
+}
+
+func newPeriodTreeNode(size []int, nextUUID *int) PeriodTreeNode {
+	uuid := *nextUUID
+	*nextUUID++
 	maxChilds := 0
 	maxCounts := 0
 	children := make([]PeriodTreeNode, 0)
@@ -14,7 +18,7 @@ func NewPeriodTreeNode(size []int) PeriodTreeNode {
 			// build children
 			maxChilds = size[0]
 			for i := 0; i < maxChilds; i++ {
-				child := NewPeriodTreeNode(size[1:])
+				child := newPeriodTreeNode(size[1:], nextUUID)
 				maxCounts += child.MaxCounts
 				children = append(children, child)
 			}
@@ -23,7 +27,7 @@ func NewPeriodTreeNode(size []int) PeriodTreeNode {
 		}
 	}
 	return PeriodTreeNode{
-		UUID:      rand.Intn(999),
+		UUID:      uuid,
 		MaxChilds: maxChilds,
 		Children:  children,
 		MaxCounts: maxCounts,
@@ -33,7 +37,7 @@ func NewPeriodTreeNode(size []int) PeriodTreeNode {
 
 // PeriodTreeNode describes a node holding a TxMatrix and (if not leaf node) children
 type PeriodTreeNode struct {
-	UUID      int
+	UUID      int              `json:"uuid"`
 	MaxChilds int              `json:"maxChilds"`
 	MaxCounts int              `json:"maxCounts"`
 	Children  []PeriodTreeNode `json:"children"`
@@ -54,3 +58,17 @@ func (periodTreeNode *PeriodTreeNode) GetNode(path []int) *PeriodTreeNode {
 	}
 	return periodTreeNode
 }
+
+// Copy returns a deep copy of the TreeNode and its children
+func (periodTreeNode *PeriodTreeNode) Copy() PeriodTreeNode {
+	node := *periodTreeNode
+	node.Children = make([]PeriodTreeNode, len(periodTreeNode.Children))
+	for i := range periodTreeNode.Children {
+		node.Children[i] = periodTreeNode.Children[i].Copy()
+	}
+	node.TxMatrix = make([]TxMatrix, len(periodTreeNode.TxMatrix))
+	for i, txMatrix := range periodTreeNode.TxMatrix {
+		node.TxMatrix[i] = txMatrix.Copy()
+	}
+	return node
+}
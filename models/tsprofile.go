@@ -2,11 +2,15 @@ package models
 
 // TSProfile contains the resulting statistical profile
 type TSProfile struct {
+	Version    int        `json:"version"`
 	Name       string     `json:"name"`
 	RootTx     []TxMatrix `json:"roottx"`
 	PeriodTree PeriodTree `json:"periodTree"`
 	Phases     Phases     `json:"phases"`
 	Settings   Settings   `json:"settings"`
+
+	// JointTx holds the transitions of the joint states of all metrics, if Settings.JointStates is set
+	JointTx *JointTx `json:"jointtx,omitempty"`
 }
 
 // Likeliness returns how likely [0,1] the current value appears according to the root tx matrix
@@ -0,0 +1,307 @@
+package models
+
+import (
+	"math"
+	"sort"
+)
+
+// ProfileDiff describes the divergence between two TSProfiles
+type ProfileDiff struct {
+	// RootTx holds the divergence of the root tx matrices per metric
+	RootTx []TxMatrixDivergence `json:"roottx"`
+
+	// Phases holds the divergence per phase (matched by phase id) and metric
+	Phases []PhaseDivergence `json:"phases"`
+
+	// PhaseTx holds the divergence of the transitions between phases
+	PhaseTx TxMatrixDivergence `json:"phaseTx"`
+
+	// PeriodNodes holds the divergence per period tree node (matched by path) and metric
+	PeriodNodes []PeriodNodeDivergence `json:"periodNodes"`
+}
+
+// PhaseDivergence describes the divergence of a single phase
+type PhaseDivergence struct {
+	Phase   int                  `json:"phase"`
+	OnlyIn  string               `json:"onlyIn,omitempty"`
+	Metrics []TxMatrixDivergence `json:"metrics"`
+}
+
+// PeriodNodeDivergence describes the divergence of a single period tree node
+type PeriodNodeDivergence struct {
+	Path    []int                `json:"path"`
+	Metrics []TxMatrixDivergence `json:"metrics"`
+}
+
+// TxMatrixDivergence describes the divergence between two TxMatrix of the same metric
+type TxMatrixDivergence struct {
+	Metric string `json:"metric"`
+
+	// OnlyIn is "local" or "remote" if the matrix exists in one profile only
+	OnlyIn string `json:"onlyIn,omitempty"`
+
+	// TotalVariation is the mean total variation distance [0,1] of the rows, weighted by step probabilities
+	TotalVariation float64 `json:"totalVariation"`
+
+	// JensenShannon is the mean Jensen-Shannon distance [0,1] of the rows, weighted by step probabilities
+	JensenShannon float64 `json:"jensenShannon"`
+
+	// StatsDelta holds the remote minus the local statistics
+	StatsDelta TSStats `json:"statsDelta"`
+
+	// Rows holds the divergence of each row, sorted by descending total variation
+	Rows []RowDivergence `json:"rows"`
+}
+
+// RowDivergence describes the divergence of the next state probabilities of one state (history)
+type RowDivergence struct {
+	From string `json:"from"`
+
+	// OnlyIn is "local" or "remote" if the row exists in one matrix only
+	OnlyIn string `json:"onlyIn,omitempty"`
+
+	TotalVariation float64 `json:"totalVariation"`
+	JensenShannon  float64 `json:"jensenShannon"`
+
+	// Weight is the mean step probability [0,1] of the row in both matrices
+	Weight float64 `json:"weight"`
+
+	// To is the next state with the largest change, Change its remote minus local probability [-1,1]
+	To     int     `json:"to"`
+	Change float64 `json:"change"`
+}
+
+// Diff compares the profile with the remote profile per metric, phase and period tree node
+func (profile *TSProfile) Diff(remote TSProfile) ProfileDiff {
+	diff := ProfileDiff{
+		RootTx:      divergences(profile.RootTx, remote.RootTx),
+		Phases:      make([]PhaseDivergence, 0),
+		PhaseTx:     profile.Phases.Tx.Divergence(remote.Phases.Tx),
+		PeriodNodes: make([]PeriodNodeDivergence, 0),
+	}
+
+	phases := len(profile.Phases.Phases)
+	if len(remote.Phases.Phases) > phases {
+		phases = len(remote.Phases.Phases)
+	}
+	for i := 0; i < phases; i++ {
+		phaseDivergence := PhaseDivergence{
+			Phase: i,
+		}
+		var local, other []TxMatrix
+		if i < len(profile.Phases.Phases) {
+			local = profile.Phases.Phases[i]
+		} else {
+			phaseDivergence.OnlyIn = "remote"
+		}
+		if i < len(remote.Phases.Phases) {
+			other = remote.Phases.Phases[i]
+		} else {
+			phaseDivergence.OnlyIn = "local"
+		}
+		phaseDivergence.Metrics = divergences(local, other)
+		diff.Phases = append(diff.Phases, phaseDivergence)
+	}
+
+	diff.PeriodNodes = periodNodeDivergences(&profile.PeriodTree.Root, &remote.PeriodTree.Root, []int{}, diff.PeriodNodes)
+	return diff
+}
+
+// periodNodeDivergences compares the nodes present in both trees, recursively
+func periodNodeDivergences(local *PeriodTreeNode, remote *PeriodTreeNode, path []int, output []PeriodNodeDivergence) []PeriodNodeDivergence {
+	output = append(output, PeriodNodeDivergence{
+		Path:    path,
+		Metrics: divergences(local.TxMatrix, remote.TxMatrix),
+	})
+	for i := 0; i < len(local.Children) && i < len(remote.Children); i++ {
+		childPath := make([]int, len(path)+1)
+		copy(childPath, path)
+		childPath[len(path)] = i
+		output = periodNodeDivergences(&local.Children[i], &remote.Children[i], childPath, output)
+	}
+	return output
+}
+
+// divergences matches the matrices by metric name and compares them
+func divergences(local []TxMatrix, remote []TxMatrix) []TxMatrixDivergence {
+	output := make([]TxMatrixDivergence, 0)
+	remoteIndex := make(map[string]int)
+	for i, txMatrix := range remote {
+		remoteIndex[txMatrix.Metric] = i
+	}
+	seen := make(map[string]bool)
+	for _, txMatrix := range local {
+		seen[txMatrix.Metric] = true
+		i, exists := remoteIndex[txMatrix.Metric]
+		if !exists {
+			output = append(output, TxMatrixDivergence{
+				Metric:         txMatrix.Metric,
+				OnlyIn:         "local",
+				TotalVariation: 1,
+				JensenShannon:  1,
+				Rows:           make([]RowDivergence, 0),
+			})
+			continue
+		}
+		output = append(output, txMatrix.Divergence(remote[i]))
+	}
+	for _, txMatrix := range remote {
+		if seen[txMatrix.Metric] {
+			continue
+		}
+		output = append(output, TxMatrixDivergence{
+			Metric:         txMatrix.Metric,
+			OnlyIn:         "remote",
+			TotalVariation: 1,
+			JensenShannon:  1,
+			Rows:           make([]RowDivergence, 0),
+		})
+	}
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].Metric < output[j].Metric
+	})
+	return output
+}
+
+// Divergence compares the next state probabilities row by row with the remote
+// TxMatrix. Rows existing in one matrix only count as fully divergent.
+func (txMatrix *TxMatrix) Divergence(txMatrixRemote TxMatrix) TxMatrixDivergence {
+	divergence := TxMatrixDivergence{
+		Metric: txMatrix.Metric,
+		StatsDelta: TSStats{
+			Min:       txMatrixRemote.Stats.Min - txMatrix.Stats.Min,
+			Max:       txMatrixRemote.Stats.Max - txMatrix.Stats.Max,
+			Stddev:    txMatrixRemote.Stats.Stddev - txMatrix.Stats.Stddev,
+			Avg:       txMatrixRemote.Stats.Avg - txMatrix.Stats.Avg,
+			Count:     txMatrixRemote.Stats.Count - txMatrix.Stats.Count,
+			StddevSum: txMatrixRemote.Stats.StddevSum - txMatrix.Stats.StddevSum,
+		},
+		Rows: make([]RowDivergence, 0),
+	}
+
+	keys := make(map[string]bool)
+	for key := range txMatrix.Transitions {
+		keys[key] = true
+	}
+	for key := range txMatrixRemote.Transitions {
+		keys[key] = true
+	}
+
+	weightSum := float64(0)
+	for key := range keys {
+		local, localExists := txMatrix.Transitions[key]
+		remote, remoteExists := txMatrixRemote.Transitions[key]
+		row := RowDivergence{
+			From:   key,
+			Weight: float64(local.StepProb+remote.StepProb) / 200,
+		}
+		if !localExists {
+			row.OnlyIn = "remote"
+		} else if !remoteExists {
+			row.OnlyIn = "local"
+		}
+
+		p := normalizeProbs(local.NextStateProbs)
+		q := normalizeProbs(remote.NextStateProbs)
+		for len(p) < len(q) {
+			p = append(p, 0)
+		}
+		for len(q) < len(p) {
+			q = append(q, 0)
+		}
+		if row.OnlyIn != "" {
+			row.TotalVariation = 1
+			row.JensenShannon = 1
+		} else {
+			row.TotalVariation = totalVariation(p, q)
+			row.JensenShannon = math.Sqrt(JSDivergence(p, q))
+		}
+		for i := range p {
+			if math.Abs(q[i]-p[i]) > math.Abs(row.Change) {
+				row.To = i
+				row.Change = q[i] - p[i]
+			}
+		}
+
+		divergence.Rows = append(divergence.Rows, row)
+		divergence.TotalVariation += row.TotalVariation * row.Weight
+		divergence.JensenShannon += row.JensenShannon * row.Weight
+		weightSum += row.Weight
+	}
+
+	if weightSum > 0 {
+		divergence.TotalVariation /= weightSum
+		divergence.JensenShannon /= weightSum
+	} else if len(divergence.Rows) > 0 {
+		// no step probabilities, fall back to the unweighted mean
+		divergence.TotalVariation = 0
+		divergence.JensenShannon = 0
+		for _, row := range divergence.Rows {
+			divergence.TotalVariation += row.TotalVariation / float64(len(divergence.Rows))
+			divergence.JensenShannon += row.JensenShannon / float64(len(divergence.Rows))
+		}
+	}
+
+	sort.Slice(divergence.Rows, func(i, j int) bool {
+		if divergence.Rows[i].TotalVariation != divergence.Rows[j].TotalVariation {
+			return divergence.Rows[i].TotalVariation > divergence.Rows[j].TotalVariation
+		}
+		return divergence.Rows[i].From < divergence.Rows[j].From
+	})
+	return divergence
+}
+
+// normalizeProbs converts percentages to a probability distribution summing up to 1
+func normalizeProbs(probs []int) []float64 {
+	output := make([]float64, len(probs))
+	total := 0
+	for _, prob := range probs {
+		if prob > 0 {
+			total += prob
+		}
+	}
+	for i, prob := range probs {
+		if total > 0 && prob > 0 {
+			output[i] = float64(prob) / float64(total)
+		}
+	}
+	return output
+}
+
+// totalVariation returns the total variation distance [0,1] of two distributions
+func totalVariation(p []float64, q []float64) float64 {
+	sum := float64(0)
+	for i := range p {
+		sum += math.Abs(p[i] - q[i])
+	}
+	return sum / 2
+}
+
+// JSDivergence returns the Jensen-Shannon divergence [0,1] (in bits) of the
+// distributions p and q, missing probabilities of the shorter one are 0. Its
+// square root is the Jensen-Shannon distance.
+func JSDivergence(p []float64, q []float64) float64 {
+	length := len(p)
+	if len(q) > length {
+		length = len(q)
+	}
+	divergence := float64(0)
+	for i := 0; i < length; i++ {
+		var probP, probQ float64
+		if i < len(p) {
+			probP = p[i]
+		}
+		if i < len(q) {
+			probQ = q[i]
+		}
+		mean := (probP + probQ) / 2
+		if probP > 0 {
+			divergence += probP * math.Log2(probP/mean) / 2
+		}
+		if probQ > 0 {
+			divergence += probQ * math.Log2(probQ/mean) / 2
+		}
+	}
+	// rounding errors
+	return math.Min(math.Max(divergence, 0), 1)
+}
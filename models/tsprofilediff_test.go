@@ -0,0 +1,79 @@
+package models
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProfileDiff(t *testing.T) {
+
+	local := TSProfile{
+		RootTx: []TxMatrix{
+			{
+				Metric: "metric_0",
+				Transitions: map[string]TXStep{
+					"0": {NextStateProbs: []int{100, 0}, StepProb: 50},
+					"1": {NextStateProbs: []int{0, 100}, StepProb: 50},
+				},
+				Stats: TSStats{Avg: 10},
+			},
+		},
+		PeriodTree: NewPeriodTree([]int{2}),
+	}
+	remote := TSProfile{
+		RootTx: []TxMatrix{
+			{
+				Metric: "metric_0",
+				Transitions: map[string]TXStep{
+					"0": {NextStateProbs: []int{50, 50}, StepProb: 50},
+					"1": {NextStateProbs: []int{0, 100}, StepProb: 50},
+				},
+				Stats: TSStats{Avg: 15},
+			},
+			{
+				Metric: "metric_1",
+			},
+		},
+		PeriodTree: NewPeriodTree([]int{2}),
+	}
+
+	Convey("Should compare profiles per metric", t, func() {
+		diff := local.Diff(remote)
+		So(diff.RootTx, ShouldHaveLength, 2)
+
+		metric0 := diff.RootTx[0]
+		So(metric0.Metric, ShouldEqual, "metric_0")
+		So(metric0.TotalVariation, ShouldAlmostEqual, 0.25, 0.0001)
+		So(metric0.StatsDelta.Avg, ShouldEqual, 5)
+		So(metric0.Rows[0].From, ShouldEqual, "0")
+		So(metric0.Rows[0].TotalVariation, ShouldAlmostEqual, 0.5, 0.0001)
+		So(metric0.Rows[0].JensenShannon, ShouldAlmostEqual, math.Sqrt(1-0.75*math.Log2(3)+0.5), 0.0001)
+		So(metric0.Rows[0].To, ShouldEqual, 0)
+		So(metric0.Rows[0].Change, ShouldAlmostEqual, -0.5, 0.0001)
+		So(metric0.Rows[1].TotalVariation, ShouldEqual, 0)
+
+		So(diff.RootTx[1].OnlyIn, ShouldEqual, "remote")
+		So(diff.PeriodNodes, ShouldHaveLength, 1)
+	})
+
+	Convey("Should not diverge from itself", t, func() {
+		diff := local.Diff(local)
+		So(diff.RootTx[0].TotalVariation, ShouldEqual, 0)
+		So(diff.RootTx[0].JensenShannon, ShouldEqual, 0)
+	})
+
+}
+
+func TestJSDivergence(t *testing.T) {
+	Convey("Should compute the Jensen-Shannon divergence of distributions", t, func() {
+		So(JSDivergence([]float64{0.25, 0.75}, []float64{0.25, 0.75}), ShouldEqual, 0)
+		So(JSDivergence([]float64{1, 0}, []float64{0, 1}), ShouldAlmostEqual, 1)
+		So(JSDivergence([]float64{0.5, 0.5}, []float64{1, 0}), ShouldAlmostEqual, 0.311278, 1e-6)
+		So(JSDivergence([]float64{0.5, 0.5}, []float64{1, 0}), ShouldEqual, JSDivergence([]float64{1, 0}, []float64{0.5, 0.5}))
+		// missing probabilities are 0
+		So(JSDivergence([]float64{1}, []float64{1, 0, 0}), ShouldEqual, 0)
+		So(JSDivergence([]float64{1}, []float64{0, 1}), ShouldAlmostEqual, 1)
+	})
+}
@@ -5,4 +5,7 @@ type TSState struct {
 	Metric     string
 	Statistics TSStats
 	State      State
+
+	// Value is the value discretized into State, the buffer's average
+	Value float64
 }
@@ -10,6 +10,35 @@ type TxMatrix struct {
 	Metric      string            `json:"metric"`
 	Transitions map[string]TXStep `json:"transitions"`
 	Stats       TSStats           `json:"stats"`
+
+	// StateStats holds per state the statistics of the values discretized into the state
+	StateStats []TSStats `json:"stateStats,omitempty"`
+
+	// DwellTimes holds per state the histogram of the dwell times: at index
+	// i, how often the metric stayed i+1 states in the state before changing
+	DwellTimes [][]int `json:"dwellTimes,omitempty"`
+}
+
+// Copy returns a deep copy of the TxMatrix
+func (txMatrix TxMatrix) Copy() TxMatrix {
+	copied := txMatrix
+	if txMatrix.Transitions != nil {
+		copied.Transitions = make(map[string]TXStep, len(txMatrix.Transitions))
+		for key, txStep := range txMatrix.Transitions {
+			copied.Transitions[key] = TXStep{
+				NextStateProbs: append([]int(nil), txStep.NextStateProbs...),
+				StepProb:       txStep.StepProb,
+			}
+		}
+	}
+	copied.StateStats = append([]TSStats(nil), txMatrix.StateStats...)
+	if txMatrix.DwellTimes != nil {
+		copied.DwellTimes = make([][]int, len(txMatrix.DwellTimes))
+		for i, histogram := range txMatrix.DwellTimes {
+			copied.DwellTimes[i] = append([]int(nil), histogram...)
+		}
+	}
+	return copied
 }
 
 // Diff compares two txMatrizes and returns the diff ratio between 0 (not equal) and 1 (fully equal)
@@ -56,6 +85,32 @@ func (txMatrix *TxMatrix) Merge(txMatrixRemote TxMatrix) {
 			}
 		}
 	}
+	// merge state stats alike, via average
+	for i, remoteStats := range txMatrixRemote.StateStats {
+		if i >= len(txMatrix.StateStats) {
+			txMatrix.StateStats = append(txMatrix.StateStats, remoteStats)
+			continue
+		}
+		txMatrix.StateStats[i] = mergeStatsAvg(txMatrix.StateStats[i], remoteStats)
+	}
+}
+
+// mergeStatsAvg averages two statistics, keeping the extreme min and max
+func mergeStatsAvg(x TSStats, y TSStats) TSStats {
+	if x.Count <= 0 {
+		return y
+	}
+	if y.Count <= 0 {
+		return x
+	}
+	return TSStats{
+		Min:       math.Min(x.Min, y.Min),
+		Max:       math.Max(x.Max, y.Max),
+		Stddev:    (x.Stddev + y.Stddev) / 2,
+		Avg:       (x.Avg + y.Avg) / 2,
+		Count:     int64(round(float64(x.Count+y.Count) / 2)),
+		StddevSum: (x.StddevSum + y.StddevSum) / 2,
+	}
 }
 
 // Likeliness computes the likeliness for transitioning from the from state to the to state
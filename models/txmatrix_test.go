@@ -145,3 +145,42 @@ func TestDiff(t *testing.T) {
 	})
 
 }
+
+func TestStationaryDistribution(t *testing.T) {
+
+	txs := `{
+		"transitions": {
+			"0": {
+				"nextProbs": [50, 50, 0],
+				"probability": 60
+			},
+			"1": {
+				"nextProbs": [100, 0, 0],
+				"probability": 40
+			}
+		}
+	}`
+	var tx TxMatrix
+	json.Unmarshal([]byte(txs), &tx)
+
+	Convey("Should compute probability matrix and stationary distribution", t, func() {
+		So(tx.StateDistribution(3), ShouldResemble, []float64{0.6, 0.4, 0})
+		matrix := tx.ProbabilityMatrix(3)
+		So(matrix[0], ShouldResemble, []float64{0.5, 0.5, 0})
+		So(matrix[1], ShouldResemble, []float64{1, 0, 0})
+		// state 2 was never left, fall back to the state distribution
+		So(matrix[2], ShouldResemble, []float64{0.6, 0.4, 0})
+
+		stationary := tx.StationaryDistribution(3)
+		So(stationary[0], ShouldAlmostEqual, 2.0/3, 0.0001)
+		So(stationary[1], ShouldAlmostEqual, 1.0/3, 0.0001)
+		So(stationary[2], ShouldAlmostEqual, 0, 0.0001)
+	})
+
+	Convey("Should return the dominant state", t, func() {
+		So(tx.DominantState(), ShouldEqual, 0)
+		empty := TxMatrix{}
+		So(empty.DominantState(), ShouldEqual, -1)
+	})
+
+}
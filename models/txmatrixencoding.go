@@ -0,0 +1,269 @@
+package models
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// txMatrixEncodingVersion is written in front of each encoded TxMatrix,
+// version 1 lacks the state stats, version 2 the dwell times
+const txMatrixEncodingVersion = 3
+
+// maxRowLength limits the row length accepted when decoding corrupt data
+const maxRowLength = 1 << 24
+
+// GobEncode encodes the TxMatrix compactly: transition keys as varint state
+// lists instead of "3-1-2" strings, rows as sparse varint (index, value) pairs
+func (txMatrix TxMatrix) GobEncode() ([]byte, error) {
+	w := &binaryWriter{}
+	w.uvarint(txMatrixEncodingVersion)
+	w.string(txMatrix.Metric)
+	w.stats(txMatrix.Stats)
+
+	// sort keys for a deterministic output
+	keys := make([]string, 0, len(txMatrix.Transitions))
+	for key := range txMatrix.Transitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w.uvarint(uint64(len(keys)))
+	for _, key := range keys {
+		if err := w.stateKey(key); err != nil {
+			return nil, err
+		}
+		txStep := txMatrix.Transitions[key]
+		w.varint(int64(txStep.StepProb))
+		w.sparseRow(txStep.NextStateProbs)
+	}
+
+	w.uvarint(uint64(len(txMatrix.StateStats)))
+	for _, stats := range txMatrix.StateStats {
+		w.stats(stats)
+	}
+
+	w.uvarint(uint64(len(txMatrix.DwellTimes)))
+	for _, histogram := range txMatrix.DwellTimes {
+		w.sparseRow(histogram)
+	}
+	return w.buf.Bytes(), nil
+}
+
+// GobDecode decodes a TxMatrix encoded with GobEncode
+func (txMatrix *TxMatrix) GobDecode(data []byte) error {
+	r := &binaryReader{buf: bytes.NewReader(data)}
+	version := r.uvarint()
+	if r.err == nil && (version < 1 || version > txMatrixEncodingVersion) {
+		return fmt.Errorf("unsupported tx matrix encoding version %d", version)
+	}
+	txMatrix.Metric = r.string()
+	txMatrix.Stats = r.stats()
+	count := r.uvarint()
+	txMatrix.Transitions = make(map[string]TXStep)
+	for i := uint64(0); i < count && r.err == nil; i++ {
+		key := r.stateKey()
+		stepProb := r.varint()
+		row := r.sparseRow()
+		txMatrix.Transitions[key] = TXStep{
+			NextStateProbs: row,
+			StepProb:       int(stepProb),
+		}
+	}
+
+	txMatrix.StateStats = nil
+	if version >= 2 {
+		count = r.uvarint()
+		if count > maxRowLength {
+			return fmt.Errorf("invalid amount of state stats %d", count)
+		}
+		for i := uint64(0); i < count && r.err == nil; i++ {
+			txMatrix.StateStats = append(txMatrix.StateStats, r.stats())
+		}
+	}
+
+	txMatrix.DwellTimes = nil
+	if version >= 3 {
+		count = r.uvarint()
+		if count > maxRowLength {
+			return fmt.Errorf("invalid amount of dwell times %d", count)
+		}
+		for i := uint64(0); i < count && r.err == nil; i++ {
+			txMatrix.DwellTimes = append(txMatrix.DwellTimes, r.sparseRow())
+		}
+	}
+	return r.err
+}
+
+// binaryWriter writes varint encoded values to a buffer
+type binaryWriter struct {
+	buf     bytes.Buffer
+	scratch [binary.MaxVarintLen64]byte
+}
+
+func (w *binaryWriter) uvarint(v uint64) {
+	n := binary.PutUvarint(w.scratch[:], v)
+	w.buf.Write(w.scratch[:n])
+}
+
+func (w *binaryWriter) varint(v int64) {
+	n := binary.PutVarint(w.scratch[:], v)
+	w.buf.Write(w.scratch[:n])
+}
+
+func (w *binaryWriter) float(v float64) {
+	binary.LittleEndian.PutUint64(w.scratch[:8], math.Float64bits(v))
+	w.buf.Write(w.scratch[:8])
+}
+
+func (w *binaryWriter) string(v string) {
+	w.uvarint(uint64(len(v)))
+	w.buf.WriteString(v)
+}
+
+func (w *binaryWriter) stats(stats TSStats) {
+	w.float(stats.Min)
+	w.float(stats.Max)
+	w.float(stats.Stddev)
+	w.float(stats.Avg)
+	w.varint(stats.Count)
+	w.float(stats.StddevSum)
+}
+
+// stateKey writes a state history key like "3-1-2" as list of states
+func (w *binaryWriter) stateKey(key string) error {
+	if key == "" {
+		w.uvarint(0)
+		return nil
+	}
+	parts := strings.Split(key, "-")
+	w.uvarint(uint64(len(parts)))
+	for _, part := range parts {
+		state, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return fmt.Errorf("cannot encode transition key %q: %s", key, err)
+		}
+		w.uvarint(state)
+	}
+	return nil
+}
+
+// sparseRow writes the row length and its non-zero entries as (index delta, value) pairs
+func (w *binaryWriter) sparseRow(row []int) {
+	w.uvarint(uint64(len(row)))
+	nonzero := 0
+	for _, v := range row {
+		if v != 0 {
+			nonzero++
+		}
+	}
+	w.uvarint(uint64(nonzero))
+	last := 0
+	for i, v := range row {
+		if v == 0 {
+			continue
+		}
+		w.uvarint(uint64(i - last))
+		w.varint(int64(v))
+		last = i
+	}
+}
+
+// binaryReader reads values written by binaryWriter, keeping the first error
+type binaryReader struct {
+	buf *bytes.Reader
+	err error
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(r.buf)
+	r.err = err
+	return v
+}
+
+func (r *binaryReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(r.buf)
+	r.err = err
+	return v
+}
+
+func (r *binaryReader) float() float64 {
+	if r.err != nil {
+		return 0
+	}
+	var scratch [8]byte
+	if _, err := io.ReadFull(r.buf, scratch[:]); err != nil {
+		r.err = err
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(scratch[:]))
+}
+
+func (r *binaryReader) string() string {
+	n := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+	if n > uint64(r.buf.Len()) {
+		r.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	v := make([]byte, n)
+	_, r.err = io.ReadFull(r.buf, v)
+	return string(v)
+}
+
+func (r *binaryReader) stats() TSStats {
+	return TSStats{
+		Min:       r.float(),
+		Max:       r.float(),
+		Stddev:    r.float(),
+		Avg:       r.float(),
+		Count:     r.varint(),
+		StddevSum: r.float(),
+	}
+}
+
+func (r *binaryReader) stateKey() string {
+	n := r.uvarint()
+	parts := make([]string, 0, n)
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		parts = append(parts, strconv.FormatUint(r.uvarint(), 10))
+	}
+	return strings.Join(parts, "-")
+}
+
+func (r *binaryReader) sparseRow() []int {
+	length := r.uvarint()
+	nonzero := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	if length > maxRowLength || nonzero > length {
+		r.err = fmt.Errorf("invalid sparse row (length %d, non-zero %d)", length, nonzero)
+		return nil
+	}
+	row := make([]int, length)
+	index := uint64(0)
+	for i := uint64(0); i < nonzero && r.err == nil; i++ {
+		index += r.uvarint()
+		value := r.varint()
+		if index >= length {
+			r.err = fmt.Errorf("sparse row index %d out of range %d", index, length)
+			return nil
+		}
+		row[index] = int(value)
+	}
+	return row
+}
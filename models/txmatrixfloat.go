@@ -0,0 +1,114 @@
+package models
+
+import (
+	"math"
+	"strconv"
+)
+
+// stationaryIterations limits the power iteration of StationaryDistribution
+const stationaryIterations = 10000
+
+// StateDistribution returns the observed distribution over the (first order)
+// states, computed from the step probabilities. If no state was observed, the
+// uniform distribution is returned.
+func (txMatrix *TxMatrix) StateDistribution(states int) []float64 {
+	distribution := make([]float64, states)
+	total := float64(0)
+	for key, txStep := range txMatrix.Transitions {
+		state, err := strconv.Atoi(key)
+		if err != nil || state < 0 || state >= states {
+			// skip histories and invalid states
+			continue
+		}
+		distribution[state] += float64(txStep.StepProb)
+		total += float64(txStep.StepProb)
+	}
+	for i := range distribution {
+		if total > 0 {
+			distribution[i] /= total
+		} else {
+			distribution[i] = 1 / float64(states)
+		}
+	}
+	return distribution
+}
+
+// DominantState returns the (first order) state with the highest step
+// probability, the lowest state on ties and -1 if no state was observed.
+func (txMatrix *TxMatrix) DominantState() int {
+	dominantState := -1
+	dominantProb := -1
+	for key, txStep := range txMatrix.Transitions {
+		state, err := strconv.Atoi(key)
+		if err != nil {
+			// skip histories
+			continue
+		}
+		if txStep.StepProb > dominantProb || (txStep.StepProb == dominantProb && state < dominantState) {
+			dominantState = state
+			dominantProb = txStep.StepProb
+		}
+	}
+	return dominantState
+}
+
+// ProbabilityMatrix returns the first order transition probabilities as
+// states x states matrix with rows summing up to 1. Rows of states which were
+// never left are replaced by the StateDistribution.
+func (txMatrix *TxMatrix) ProbabilityMatrix(states int) [][]float64 {
+	fallback := txMatrix.StateDistribution(states)
+	matrix := make([][]float64, states)
+	for i := range matrix {
+		matrix[i] = fallback
+		txStep, exists := txMatrix.Transitions[strconv.Itoa(i)]
+		if !exists {
+			continue
+		}
+		row := make([]float64, states)
+		total := float64(0)
+		for j, prob := range txStep.NextStateProbs {
+			if j >= states || prob <= 0 {
+				continue
+			}
+			row[j] = float64(prob)
+			total += float64(prob)
+		}
+		if total <= 0 {
+			continue
+		}
+		for j := range row {
+			row[j] /= total
+		}
+		matrix[i] = row
+	}
+	return matrix
+}
+
+// StationaryDistribution returns the long-run share of each state of the
+// first order Markov chain described by the TxMatrix
+func (txMatrix *TxMatrix) StationaryDistribution(states int) []float64 {
+	matrix := txMatrix.ProbabilityMatrix(states)
+	distribution := txMatrix.StateDistribution(states)
+	for iteration := 0; iteration < stationaryIterations; iteration++ {
+		next := make([]float64, states)
+		for i, p := range distribution {
+			if p == 0 {
+				continue
+			}
+			for j, q := range matrix[i] {
+				next[j] += p * q
+			}
+		}
+		// average with the previous step (lazy chain) to converge for periodic chains too
+		change := float64(0)
+		for j := range next {
+			next[j] = (next[j] + distribution[j]) / 2
+			change += math.Abs(next[j] - distribution[j])
+		}
+		distribution = next
+		if change < 1e-12 {
+			break
+		}
+	}
+	return distribution
+}
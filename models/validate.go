@@ -0,0 +1,351 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationError lists the inconsistencies found when validating a model
+type ValidationError struct {
+	Issues []string
+}
+
+func (validationErr *ValidationError) Error() string {
+	return "invalid: " + listFields(validationErr.Issues)
+}
+
+// newValidationError returns a *ValidationError if issues is not empty, nil otherwise
+func newValidationError(issues []string) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	return &ValidationError{
+		Issues: issues,
+	}
+}
+
+// Validate checks that the profile is internally consistent: transition rows
+// match the amount of states, the period tree matches the period size, and
+// the phase transitions match the amount of phases
+func (profile *TSProfile) Validate() error {
+	issues := make([]string, 0)
+	states := profile.Settings.States
+	history := profile.Settings.History
+	if states <= 0 {
+		issues = append(issues, fmt.Sprintf("settings.states must be positive, is %d", states))
+		return newValidationError(issues)
+	}
+
+	for i, txMatrix := range profile.RootTx {
+		issues = append(issues, validateTxMatrix(txMatrix, states, history, fmt.Sprintf("roottx[%d]", i))...)
+	}
+
+	for p, phase := range profile.Phases.Phases {
+		for i, txMatrix := range phase {
+			issues = append(issues, validateTxMatrix(txMatrix, states, history, fmt.Sprintf("phases.phases[%d][%d]", p, i))...)
+		}
+	}
+	if len(profile.Phases.Tx.Transitions) > 0 {
+		issues = append(issues, validateTxMatrix(profile.Phases.Tx, len(profile.Phases.Phases), 1, "phases.tx")...)
+	}
+	if len(profile.Phases.Meta) > 0 && len(profile.Phases.Meta) != len(profile.Phases.Phases) {
+		issues = append(issues, fmt.Sprintf("phases.meta has %d entries, expected one per phase (%d)", len(profile.Phases.Meta), len(profile.Phases.Phases)))
+	}
+
+	validPeriodSize := true
+	for i, size := range profile.Settings.PeriodSize {
+		if size <= 0 {
+			issues = append(issues, fmt.Sprintf("settings.periodsize[%d] must be positive, is %d", i, size))
+			validPeriodSize = false
+		}
+	}
+	if validPeriodSize {
+		issues = append(issues, validatePeriodTreeNode(&profile.PeriodTree.Root, profile.Settings.PeriodSize, 0, states, history, "periodTree.root")...)
+	}
+
+	if profile.JointTx != nil {
+		issues = append(issues, validateJointTx(profile.JointTx, profile.RootTx, states)...)
+	}
+
+	return newValidationError(issues)
+}
+
+// validateJointTx checks that the joint tx covers known metrics and its keys hold valid states for each of them
+func validateJointTx(jointTx *JointTx, rootTx []TxMatrix, states int) []string {
+	issues := make([]string, 0)
+	metrics := make(map[string]bool)
+	for _, txMatrix := range rootTx {
+		metrics[txMatrix.Metric] = true
+	}
+	for i, metric := range jointTx.Metrics {
+		if !metrics[metric] {
+			issues = append(issues, fmt.Sprintf("jointtx.metrics[%d] has unknown metric %s", i, metric))
+		}
+	}
+	validKey := func(key string) bool {
+		jointStates, err := ParseJointKey(key)
+		if err != nil || len(jointStates) != len(jointTx.Metrics) {
+			return false
+		}
+		for _, state := range jointStates {
+			if state < 0 || state >= int64(states) {
+				return false
+			}
+		}
+		return true
+	}
+	keys := make([]string, 0, len(jointTx.Transitions))
+	for key := range jointTx.Transitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !validKey(key) {
+			issues = append(issues, fmt.Sprintf("jointtx.transitions[%s] is no valid joint state of %d metrics", key, len(jointTx.Metrics)))
+			continue
+		}
+		nextKeys := make([]string, 0, len(jointTx.Transitions[key].NextStateProbs))
+		for nextKey := range jointTx.Transitions[key].NextStateProbs {
+			nextKeys = append(nextKeys, nextKey)
+		}
+		sort.Strings(nextKeys)
+		for _, nextKey := range nextKeys {
+			if !validKey(nextKey) {
+				issues = append(issues, fmt.Sprintf("jointtx.transitions[%s].nextProbs[%s] is no valid joint state of %d metrics", key, nextKey, len(jointTx.Metrics)))
+			}
+		}
+	}
+	return issues
+}
+
+// validateTxMatrix checks that all transitions have `states` next states and valid state histories
+func validateTxMatrix(txMatrix TxMatrix, states int, history int, path string) []string {
+	issues := make([]string, 0)
+	keys := make([]string, 0, len(txMatrix.Transitions))
+	for key := range txMatrix.Transitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		txStep := txMatrix.Transitions[key]
+		if len(txStep.NextStateProbs) != states {
+			issues = append(issues, fmt.Sprintf("%s.transitions[%s] has %d next states, expected %d", path, key, len(txStep.NextStateProbs), states))
+		}
+		parts := strings.Split(key, "-")
+		if history > 0 && len(parts) > history {
+			issues = append(issues, fmt.Sprintf("%s.transitions[%s] has a history of %d states, expected at most %d", path, key, len(parts), history))
+		}
+		for _, part := range parts {
+			state, err := strconv.Atoi(part)
+			if err != nil || state < 0 || state >= states {
+				issues = append(issues, fmt.Sprintf("%s.transitions[%s] has invalid state %q", path, key, part))
+				break
+			}
+		}
+	}
+	if len(txMatrix.StateStats) > states {
+		issues = append(issues, fmt.Sprintf("%s has %d state stats, expected at most %d", path, len(txMatrix.StateStats), states))
+	}
+	if len(txMatrix.DwellTimes) > states {
+		issues = append(issues, fmt.Sprintf("%s has %d dwell times, expected at most %d", path, len(txMatrix.DwellTimes), states))
+	}
+	return issues
+}
+
+// validatePeriodTreeNode checks that the node and its children are shaped as NewPeriodTreeNode(periodSize) would do
+func validatePeriodTreeNode(node *PeriodTreeNode, periodSize []int, level int, states int, history int, path string) []string {
+	issues := make([]string, 0)
+	expectedChilds := 0
+	if level < len(periodSize)-1 {
+		expectedChilds = periodSize[level]
+	}
+	if len(node.Children) != expectedChilds {
+		issues = append(issues, fmt.Sprintf("%s has %d children, expected %d", path, len(node.Children), expectedChilds))
+	}
+	if node.MaxChilds != len(node.Children) {
+		issues = append(issues, fmt.Sprintf("%s has maxChilds %d but %d children", path, node.MaxChilds, len(node.Children)))
+	}
+	for i, txMatrix := range node.TxMatrix {
+		issues = append(issues, validateTxMatrix(txMatrix, states, history, fmt.Sprintf("%s.txmatrix[%d]", path, i))...)
+	}
+	for i := range node.Children {
+		issues = append(issues, validatePeriodTreeNode(&node.Children[i], periodSize, level+1, states, history, fmt.Sprintf("%s.children[%d]", path, i))...)
+	}
+	return issues
+}
+
+// Validate checks that the history is non-empty and matches the given profile
+func (history *History) Validate(profile *TSProfile) error {
+	issues := make([]string, 0)
+	if len(history.HistoricStates) == 0 {
+		issues = append(issues, "historicStates is empty")
+	}
+
+	metrics := make(map[string]bool)
+	for _, txMatrix := range profile.RootTx {
+		metrics[txMatrix.Metric] = true
+	}
+	for i, historicState := range history.HistoricStates {
+		for metric, stateHistory := range historicState {
+			if len(metrics) > 0 && !metrics[metric] {
+				issues = append(issues, fmt.Sprintf("historicStates[%d] has unknown metric %s", i, metric))
+			}
+			for _, part := range strings.Split(stateHistory, "-") {
+				state, err := strconv.Atoi(part)
+				if err != nil || state < 0 || state >= profile.Settings.States {
+					issues = append(issues, fmt.Sprintf("historicStates[%d] has invalid state %q for metric %s", i, stateHistory, metric))
+					break
+				}
+			}
+		}
+	}
+
+	if len(profile.Phases.Phases) > 0 && (history.CurrentPhase < 0 || history.CurrentPhase >= len(profile.Phases.Phases)) {
+		issues = append(issues, fmt.Sprintf("currentPhase %d does not exist, profile has %d phases", history.CurrentPhase, len(profile.Phases.Phases)))
+	}
+
+	if len(history.PeriodPath) > len(profile.Settings.PeriodSize) {
+		issues = append(issues, fmt.Sprintf("periodPath has %d levels, profile has %d", len(history.PeriodPath), len(profile.Settings.PeriodSize)))
+	} else {
+		for i, position := range history.PeriodPath {
+			if position < 0 || position >= profile.Settings.PeriodSize[i] {
+				issues = append(issues, fmt.Sprintf("periodPath[%d] is %d, expected below %d", i, position, profile.Settings.PeriodSize[i]))
+			}
+		}
+	}
+
+	return newValidationError(issues)
+}
+
+// ValidateSemantics checks the probabilities of all transition matrices and
+// the counts of the period tree: each row of next state probabilities and the
+// step probabilities of each history length have to sum up to 100, where each
+// rounded entry may deviate by half a percentage point (one and a half in
+// period tree nodes, which are merged and rounded repeatedly), plus
+// `tolerance`. Inner period tree nodes count as much as their children,
+// leaves as much as the last period size.
+func (profile *TSProfile) ValidateSemantics(tolerance float64) error {
+	issues := make([]string, 0)
+
+	for i, txMatrix := range profile.RootTx {
+		issues = append(issues, validateTxMatrixProbabilities(txMatrix, 0.5, tolerance, fmt.Sprintf("roottx[%d]", i))...)
+	}
+	for p, phase := range profile.Phases.Phases {
+		for i, txMatrix := range phase {
+			issues = append(issues, validateTxMatrixProbabilities(txMatrix, 0.5, tolerance, fmt.Sprintf("phases.phases[%d][%d]", p, i))...)
+		}
+	}
+	issues = append(issues, validateTxMatrixProbabilities(profile.Phases.Tx, 0.5, tolerance, "phases.tx")...)
+	issues = append(issues, validatePeriodTreeNodeSemantics(&profile.PeriodTree.Root, profile.Settings.PeriodSize, tolerance, "periodTree.root")...)
+	if profile.JointTx != nil {
+		issues = append(issues, validateJointTxProbabilities(profile.JointTx, 0.5, tolerance)...)
+	}
+
+	return newValidationError(issues)
+}
+
+// validateJointTxProbabilities checks probability sums of the joint tx, allowing `rounding` percentage points per rounded entry
+func validateJointTxProbabilities(jointTx *JointTx, rounding float64, tolerance float64) []string {
+	issues := make([]string, 0)
+	keys := make([]string, 0, len(jointTx.Transitions))
+	for key := range jointTx.Transitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	stepProbSum := 0
+	for _, key := range keys {
+		jointStep := jointTx.Transitions[key]
+		sum := 0
+		for _, prob := range jointStep.NextStateProbs {
+			if prob < 0 || prob > 100 {
+				issues = append(issues, fmt.Sprintf("jointtx.transitions[%s] has probability %d outside [0,100]", key, prob))
+			}
+			sum += prob
+		}
+		if math.Abs(float64(sum-100)) > rounding*float64(len(jointStep.NextStateProbs))+tolerance {
+			issues = append(issues, fmt.Sprintf("jointtx.transitions[%s] next state probabilities sum up to %d", key, sum))
+		}
+		stepProbSum += jointStep.StepProb
+	}
+	if len(keys) > 0 && math.Abs(float64(stepProbSum-100)) > rounding*float64(len(keys))+tolerance {
+		issues = append(issues, fmt.Sprintf("jointtx step probabilities sum up to %d", stepProbSum))
+	}
+	return issues
+}
+
+// validateTxMatrixProbabilities checks probability sums, allowing `rounding` percentage points per rounded entry
+func validateTxMatrixProbabilities(txMatrix TxMatrix, rounding float64, tolerance float64, path string) []string {
+	issues := make([]string, 0)
+	keys := make([]string, 0, len(txMatrix.Transitions))
+	for key := range txMatrix.Transitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	stepProbSums := make(map[int]int)
+	stepProbCounts := make(map[int]int)
+	for _, key := range keys {
+		txStep := txMatrix.Transitions[key]
+		sum := 0
+		for _, prob := range txStep.NextStateProbs {
+			if prob < 0 || prob > 100 {
+				issues = append(issues, fmt.Sprintf("%s.transitions[%s] has probability %d outside [0,100]", path, key, prob))
+			}
+			sum += prob
+		}
+		if math.Abs(float64(sum-100)) > rounding*float64(len(txStep.NextStateProbs))+tolerance {
+			issues = append(issues, fmt.Sprintf("%s.transitions[%s] next state probabilities sum up to %d", path, key, sum))
+		}
+		historyLength := len(strings.Split(key, "-"))
+		stepProbSums[historyLength] += txStep.StepProb
+		stepProbCounts[historyLength]++
+	}
+
+	historyLengths := make([]int, 0, len(stepProbSums))
+	for historyLength := range stepProbSums {
+		historyLengths = append(historyLengths, historyLength)
+	}
+	sort.Ints(historyLengths)
+	for _, historyLength := range historyLengths {
+		sum := stepProbSums[historyLength]
+		deviation := float64(sum - 100)
+		if historyLength > 1 && deviation < 0 {
+			// histories longer than one state may be pruned
+			deviation = 0
+		}
+		if math.Abs(deviation) > rounding*float64(stepProbCounts[historyLength])+tolerance {
+			issues = append(issues, fmt.Sprintf("%s step probabilities of history length %d sum up to %d", path, historyLength, sum))
+		}
+	}
+	return issues
+}
+
+func validatePeriodTreeNodeSemantics(node *PeriodTreeNode, periodSize []int, tolerance float64, path string) []string {
+	issues := make([]string, 0)
+	for i, txMatrix := range node.TxMatrix {
+		issues = append(issues, validateTxMatrixProbabilities(txMatrix, 1.5, tolerance, fmt.Sprintf("%s.txmatrix[%d]", path, i))...)
+	}
+	if len(node.Children) == 0 {
+		expected := 0
+		if len(periodSize) > 0 {
+			expected = periodSize[len(periodSize)-1]
+		}
+		if node.MaxCounts != expected {
+			issues = append(issues, fmt.Sprintf("%s has maxCounts %d, expected %d", path, node.MaxCounts, expected))
+		}
+		return issues
+	}
+	childCounts := 0
+	for i := range node.Children {
+		childCounts += node.Children[i].MaxCounts
+		issues = append(issues, validatePeriodTreeNodeSemantics(&node.Children[i], periodSize, tolerance, fmt.Sprintf("%s.children[%d]", path, i))...)
+	}
+	if node.MaxCounts != childCounts {
+		issues = append(issues, fmt.Sprintf("%s has maxCounts %d, its children %d", path, node.MaxCounts, childCounts))
+	}
+	return issues
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidate(t *testing.T) {
+
+	newProfile := func() TSProfile {
+		return TSProfile{
+			RootTx: []TxMatrix{
+				{
+					Metric: "metric_0",
+					Transitions: map[string]TXStep{
+						"0":   {NextStateProbs: []int{50, 50}},
+						"1-0": {NextStateProbs: []int{0, 100}},
+					},
+				},
+			},
+			PeriodTree: NewPeriodTree([]int{2, 3}),
+			Phases: Phases{
+				Phases: [][]TxMatrix{{}, {}},
+				Tx: TxMatrix{
+					Transitions: map[string]TXStep{
+						"0": {NextStateProbs: []int{50, 50}},
+					},
+				},
+			},
+			Settings: Settings{
+				States:     2,
+				History:    2,
+				PeriodSize: []int{2, 3},
+			},
+		}
+	}
+
+	Convey("Should accept consistent profiles", t, func() {
+		profile := newProfile()
+		So(profile.Validate(), ShouldBeNil)
+	})
+
+	Convey("Should report inconsistent profiles", t, func() {
+		profile := newProfile()
+		profile.RootTx[0].Transitions["1"] = TXStep{NextStateProbs: []int{100}}
+		profile.RootTx[0].Transitions["2"] = TXStep{NextStateProbs: []int{0, 100}}
+		profile.Phases.Tx.Transitions["1"] = TXStep{NextStateProbs: []int{0, 0, 100}}
+		profile.Settings.PeriodSize = []int{2, 3, 4}
+		profile.RootTx[0].StateStats = make([]TSStats, 3)
+		profile.Phases.Meta = make([]PhaseMeta, 1)
+		err := profile.Validate()
+		So(err, ShouldHaveSameTypeAs, &ValidationError{})
+		issues := err.(*ValidationError).Issues
+		So(issues, ShouldContain, "roottx[0].transitions[1] has 1 next states, expected 2")
+		So(issues, ShouldContain, `roottx[0].transitions[2] has invalid state "2"`)
+		So(issues, ShouldContain, "phases.tx.transitions[1] has 3 next states, expected 2")
+		So(issues, ShouldContain, "roottx[0] has 3 state stats, expected at most 2")
+		So(issues, ShouldContain, "phases.meta has 1 entries, expected one per phase (2)")
+		So(issues, ShouldContain, "periodTree.root.children[0] has 0 children, expected 3")
+	})
+
+	Convey("Should validate the joint tx", t, func() {
+		profile := newProfile()
+		profile.JointTx = &JointTx{
+			Metrics: []string{"metric_0"},
+			Transitions: map[string]JointStep{
+				"0": {NextStateProbs: map[string]int{"1": 100}, StepProb: 100},
+			},
+		}
+		So(profile.Validate(), ShouldBeNil)
+		So(validateJointTxProbabilities(profile.JointTx, 0.5, 0), ShouldBeEmpty)
+
+		profile.JointTx.Metrics = append(profile.JointTx.Metrics, "metric_1")
+		profile.JointTx.Transitions["0,1"] = JointStep{NextStateProbs: map[string]int{"1,2": 100}}
+		err := profile.Validate()
+		So(err, ShouldHaveSameTypeAs, &ValidationError{})
+		issues := err.(*ValidationError).Issues
+		So(issues, ShouldContain, "jointtx.metrics[1] has unknown metric metric_1")
+		So(issues, ShouldContain, "jointtx.transitions[0] is no valid joint state of 2 metrics")
+		So(issues, ShouldContain, "jointtx.transitions[0,1].nextProbs[1,2] is no valid joint state of 2 metrics")
+	})
+
+	Convey("Should validate histories against profiles", t, func() {
+		profile := newProfile()
+		history := History{
+			CurrentPhase:   1,
+			HistoricStates: []map[string]string{{"metric_0": "1-0"}},
+			PeriodPath:     []int{1, 2},
+		}
+		So(history.Validate(&profile), ShouldBeNil)
+
+		So((&History{}).Validate(&profile), ShouldNotBeNil)
+		history.CurrentPhase = 2
+		history.PeriodPath = []int{2, 0}
+		err := history.Validate(&profile)
+		So(err, ShouldNotBeNil)
+		So(err.(*ValidationError).Issues, ShouldHaveLength, 2)
+	})
+
+}
+
+func TestValidateSemantics(t *testing.T) {
+
+	Convey("Should check probability sums and period tree counts", t, func() {
+		profile := TSProfile{
+			RootTx: []TxMatrix{
+				{
+					Transitions: map[string]TXStep{
+						"0": {NextStateProbs: []int{33, 33, 33}, StepProb: 50},
+						"1": {NextStateProbs: []int{0, 100, 0}, StepProb: 50},
+					},
+				},
+			},
+			PeriodTree: NewPeriodTree([]int{2, 3}),
+			Settings: Settings{
+				States:     3,
+				PeriodSize: []int{2, 3},
+			},
+		}
+		So(profile.ValidateSemantics(0), ShouldBeNil)
+
+		profile.RootTx[0].Transitions["2"] = TXStep{NextStateProbs: []int{0, 50, 0}, StepProb: 10}
+		profile.PeriodTree.Root.Children[1].MaxCounts = 4
+		err := profile.ValidateSemantics(0)
+		So(err, ShouldNotBeNil)
+		issues := err.(*ValidationError).Issues
+		So(issues, ShouldContain, "roottx[0].transitions[2] next state probabilities sum up to 50")
+		So(issues, ShouldContain, "roottx[0] step probabilities of history length 1 sum up to 110")
+		So(issues, ShouldContain, "periodTree.root.children[1] has maxCounts 4, expected 3")
+		So(issues, ShouldContain, "periodTree.root has maxCounts 6, its children 7")
+
+		So(profile.ValidateSemantics(60), ShouldNotBeNil)
+
+		// pruned histories longer than one state
+		delete(profile.RootTx[0].Transitions, "2")
+		profile.PeriodTree.Root.Children[1].MaxCounts = 3
+		profile.RootTx[0].Transitions["1-0"] = TXStep{NextStateProbs: []int{0, 100, 0}, StepProb: 20}
+		So(profile.ValidateSemantics(0), ShouldBeNil)
+		profile.RootTx[0].Transitions["0-1"] = TXStep{NextStateProbs: []int{0, 100, 0}, StepProb: 90}
+		So(profile.ValidateSemantics(0).(*ValidationError).Issues, ShouldContain, "roottx[0] step probabilities of history length 2 sum up to 110")
+	})
+
+}
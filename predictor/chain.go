@@ -0,0 +1,280 @@
+package predictor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cha87de/tsprofiler/models"
+)
+
+// chainDistribution holds the probabilities of a metric's state histories
+// (inner map) per phase (outer array, a single phase if not in phases mode)
+type chainDistribution []map[string]float64
+
+// stateChain propagates the distribution over the history-expanded state
+// space of a metric through the matrices of the prediction mode, step by step
+// like the simulation: first the phase or period moves on, then the state.
+type stateChain struct {
+	predictor *Predictor
+	metric    string
+	rootTx    models.TxMatrix
+	phases    int
+
+	// next state probabilities per matrix and state history
+	rows     map[string]map[string][]float64
+	initKeys map[string]string
+}
+
+// newStateChain returns a stateChain for `metric`, walking through the period
+// tree with an own copy of the predictor's period path
+func newStateChain(predictor *Predictor, metric string) (*stateChain, error) {
+	rootTx, err := findMetricInTxMatrices(predictor.profile.RootTx, metric)
+	if err != nil {
+		return nil, err
+	}
+	phases := 1
+	if predictor.mode == PredictionModePhases {
+		phases = len(predictor.profile.Phases.Phases)
+		if predictor.currentPhase < 0 || predictor.currentPhase >= phases {
+			return nil, fmt.Errorf("current phase %d unknown, profile has %d phases", predictor.currentPhase, phases)
+		}
+	}
+	return &stateChain{
+		predictor: predictor.clone(predictor.random),
+		metric:    metric,
+		rootTx:    rootTx,
+		phases:    phases,
+		rows:      make(map[string]map[string][]float64),
+		initKeys:  make(map[string]string),
+	}, nil
+}
+
+// start returns the distribution being in `stateHistory` and the current phase
+func (chain *stateChain) start(stateHistory string) chainDistribution {
+	distribution := chain.newDistribution()
+	phase := 0
+	if chain.predictor.mode == PredictionModePhases {
+		phase = chain.predictor.currentPhase
+	}
+	distribution[phase][stateHistory] = 1
+	return distribution
+}
+
+// step returns the distribution after the next step
+func (chain *stateChain) step(distribution chainDistribution) (chainDistribution, error) {
+	if chain.predictor.mode == PredictionModePeriods && len(chain.predictor.periodPath) > 0 {
+		chain.predictor.nextPeriod(0)
+	}
+
+	next := chain.newDistribution()
+	for phase, histories := range distribution {
+		mass := float64(0)
+		for _, prob := range histories {
+			mass += prob
+		}
+		if mass <= 0 {
+			continue
+		}
+		for nextPhase, phaseProb := range chain.phaseRow(phase) {
+			if phaseProb <= 0 {
+				continue
+			}
+			if nextPhase != phase {
+				// like in the simulation: a phase change resets the state
+				stateHistory, err := chain.initKey(nextPhase)
+				if err != nil {
+					return nil, err
+				}
+				if err := chain.transition(next[nextPhase], nextPhase, stateHistory, phaseProb*mass); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			for stateHistory, prob := range histories {
+				if prob <= 0 {
+					continue
+				}
+				if err := chain.transition(next[phase], phase, stateHistory, phaseProb*prob); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return next, nil
+}
+
+// transition adds the successors of `stateHistory` weighted with `prob` to `next`
+func (chain *stateChain) transition(next map[string]float64, phase int, stateHistory string, prob float64) error {
+	row, err := chain.row(phase, stateHistory)
+	if err != nil {
+		return err
+	}
+	for state, stateProb := range row {
+		if stateProb <= 0 {
+			continue
+		}
+		next[chain.appendState(stateHistory, state)] += prob * stateProb
+	}
+	return nil
+}
+
+// appendState returns the state history with the oldest state removed (if
+// max history reached) and `state` appended
+func (chain *stateChain) appendState(stateHistory string, state int) string {
+	stateHistoryArr := strings.Split(stateHistory, "-")
+	if len(stateHistoryArr) >= chain.predictor.profile.Settings.History {
+		stateHistoryArr = stateHistoryArr[len(stateHistoryArr)-chain.predictor.profile.Settings.History+1:]
+	}
+	return strings.Join(append(stateHistoryArr, strconv.Itoa(state)), "-")
+}
+
+// phaseRow returns the probabilities to change from `phase` to each phase
+func (chain *stateChain) phaseRow(phase int) []float64 {
+	stay := make([]float64, chain.phases)
+	stay[phase] = 1
+	if chain.predictor.mode != PredictionModePhases {
+		return stay
+	}
+	txstep, exists := chain.predictor.profile.Phases.Tx.Transitions[strconv.Itoa(phase)]
+	if !exists {
+		// like in the simulation: stay in phase if no phase change is known
+		return stay
+	}
+	row := normalize(txstep.NextStateProbs, chain.phases)
+	if sum(row) <= 0 {
+		return stay
+	}
+	return row
+}
+
+// txMatrix returns the metric's matrix of the prediction mode and `phase`,
+// and a key identifying the matrix
+func (chain *stateChain) txMatrix(phase int) (models.TxMatrix, string) {
+	var txmatrices []models.TxMatrix
+	var key string
+	switch chain.predictor.mode {
+	case PredictionModePhases:
+		txmatrices = chain.predictor.profile.Phases.Phases[phase]
+		key = fmt.Sprintf("phase %d", phase)
+	case PredictionModePeriods:
+		txmatrices = chain.predictor.getCurrentPeriodTxMatrix()
+		key = fmt.Sprintf("period %v", chain.predictor.periodPath[:chain.predictor.periodPathDepth])
+	default:
+		return chain.rootTx, "root"
+	}
+	txmatrix, err := findMetricInTxMatrices(txmatrices, chain.metric)
+	if err != nil || len(txmatrix.Transitions) == 0 {
+		// fall back to the root tx matrix
+		return chain.rootTx, "root"
+	}
+	return txmatrix, key
+}
+
+// row returns the next state probabilities of `stateHistory` in `phase`
+func (chain *stateChain) row(phase int, stateHistory string) ([]float64, error) {
+	txmatrix, key := chain.txMatrix(phase)
+	rows, exists := chain.rows[key]
+	if !exists {
+		rows = make(map[string][]float64)
+		chain.rows[key] = rows
+	}
+	if row, exists := rows[stateHistory]; exists {
+		return row, nil
+	}
+
+	states := chain.predictor.profile.Settings.States
+	txstep, err := findStateHistoryInTxMatrix(txmatrix, stateHistory)
+	if err != nil {
+		// like in the simulation: take next step with highest stepProb
+		txstep, err = findStateByStateProbInTxmatrix(txmatrix)
+		if err != nil {
+			return nil, err
+		}
+	}
+	row := normalize(txstep.NextStateProbs, states)
+	if sum(row) <= 0 {
+		// no next state known, stay in state
+		stateHistoryArr := strings.Split(stateHistory, "-")
+		state, err := strconv.Atoi(stateHistoryArr[len(stateHistoryArr)-1])
+		if err != nil || state < 0 || state >= states {
+			return nil, fmt.Errorf("invalid state history %s", stateHistory)
+		}
+		row[state] = 1
+	}
+	rows[stateHistory] = row
+	return row, nil
+}
+
+// initKey returns the state history the simulation starts with when entering `phase`
+func (chain *stateChain) initKey(phase int) (string, error) {
+	txmatrix, key := chain.txMatrix(phase)
+	if stateHistory, exists := chain.initKeys[key]; exists {
+		return stateHistory, nil
+	}
+	stateHistory := dominantTransition(txmatrix)
+	if stateHistory == "" {
+		return "", fmt.Errorf("failed to initialize state for metric %s", chain.metric)
+	}
+	chain.initKeys[key] = stateHistory
+	return stateHistory, nil
+}
+
+func (chain *stateChain) newDistribution() chainDistribution {
+	distribution := make(chainDistribution, chain.phases)
+	for i := range distribution {
+		distribution[i] = make(map[string]float64)
+	}
+	return distribution
+}
+
+// states returns the distribution over the current (last) states
+func (distribution chainDistribution) states(states int) []float64 {
+	output := make([]float64, states)
+	for _, histories := range distribution {
+		for stateHistory, prob := range histories {
+			stateHistoryArr := strings.Split(stateHistory, "-")
+			state, err := strconv.Atoi(stateHistoryArr[len(stateHistoryArr)-1])
+			if err != nil || state < 0 || state >= states {
+				continue
+			}
+			output[state] += prob
+		}
+	}
+	return output
+}
+
+// phaseStates returns per phase the distribution over the current (last) states
+func (distribution chainDistribution) phaseStates(states int) [][]float64 {
+	output := make([][]float64, len(distribution))
+	for phase, histories := range distribution {
+		output[phase] = chainDistribution{histories}.states(states)
+	}
+	return output
+}
+
+// normalize returns the probabilities [0,100] as `length` probabilities summing up to 1
+func normalize(probs []int, length int) []float64 {
+	output := make([]float64, length)
+	total := float64(0)
+	for i, prob := range probs {
+		if i < length && prob > 0 {
+			output[i] = float64(prob)
+			total += float64(prob)
+		}
+	}
+	if total > 0 {
+		for i := range output {
+			output[i] /= total
+		}
+	}
+	return output
+}
+
+func sum(values []float64) float64 {
+	total := float64(0)
+	for _, value := range values {
+		total += value
+	}
+	return total
+}
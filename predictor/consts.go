@@ -12,4 +12,15 @@ const (
 
 	// PredictionModePeriods defines the mode "Periods", which uses the TSProfile's periods
 	PredictionModePeriods PredictionMode = 2
+
+	// PredictionModeJoint defines the mode "Joint", which simulates with the
+	// TSProfile's joint tx of all metrics, falling back to the root transition
+	// matrix for joint states never observed (and for likeliness and forecast)
+	PredictionModeJoint PredictionMode = 3
+
+	// PredictionModeSemiMarkov defines the mode "SemiMarkov", which simulates
+	// with the TSProfile's root transition matrix as semi-Markov process, the
+	// durations of the stays in a state sampled from the recorded dwell times
+	// (the likeliness and forecast use the root transition matrix)
+	PredictionModeSemiMarkov PredictionMode = 4
 )
@@ -0,0 +1,141 @@
+package predictor
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// EnsembleStep holds the aggregated simulated values of a metric for a single
+// step. Runs is the amount of runs which simulated the metric in this step,
+// mean and percentiles are 0 if none did.
+type EnsembleStep struct {
+	Runs        int                  `json:"runs"`
+	Mean        float64              `json:"mean"`
+	Percentiles []EnsemblePercentile `json:"percentiles"`
+}
+
+// EnsemblePercentile holds the value of the `Percentile` [0,1]
+type EnsemblePercentile struct {
+	Percentile float64 `json:"percentile"`
+	Value      float64 `json:"value"`
+}
+
+// Ensemble runs `runs` independent simulations of `steps` steps in parallel,
+// each starting from the predictor's current state, and returns per metric
+// and step the mean and the given `percentiles` [0,1] of the simulated
+// values. Run i uses a random source seeded with `seed`+i, hence results are
+// reproducible for the same seed. The predictor's state is not changed.
+func (predictor *Predictor) Ensemble(steps int, runs int, percentiles []float64, seed int64) (map[string][]EnsembleStep, error) {
+	if runs <= 0 {
+		return nil, fmt.Errorf("invalid amount of runs %d", runs)
+	}
+	for _, percentile := range percentiles {
+		if percentile < 0 || percentile > 1 {
+			return nil, fmt.Errorf("invalid percentile %v, must be in [0,1]", percentile)
+		}
+	}
+
+	// values per metric, step and run
+	values := make(map[string][][]float64)
+	for metric := range predictor.currentState {
+		values[metric] = make([][]float64, steps)
+		for step := range values[metric] {
+			values[metric][step] = make([]float64, runs)
+			for run := range values[metric][step] {
+				// runs may skip a metric, e.g. after a phase change
+				values[metric][step][run] = math.NaN()
+			}
+		}
+	}
+
+	runQueue := make(chan int)
+	errs := make([]error, runs)
+	wg := &sync.WaitGroup{}
+	for worker := 0; worker < runtime.NumCPU(); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for run := range runQueue {
+				simulation, err := predictor.clone(rand.New(rand.NewSource(seed + int64(run)))).Simulate(steps)
+				if err != nil {
+					errs[run] = err
+					continue
+				}
+				for step, tsstates := range simulation {
+					for _, tsstate := range tsstates {
+						// each run writes its own column only
+						if metricValues, exists := values[tsstate.Metric]; exists {
+							metricValues[step][run] = tsstate.Value
+						}
+					}
+				}
+			}
+		}()
+	}
+	for run := 0; run < runs; run++ {
+		runQueue <- run
+	}
+	close(runQueue)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sortedPercentiles := append([]float64{}, percentiles...)
+	sort.Float64s(sortedPercentiles)
+	output := make(map[string][]EnsembleStep)
+	for metric, metricValues := range values {
+		output[metric] = make([]EnsembleStep, steps)
+		for step, runValues := range metricValues {
+			stepValues := make([]float64, 0, len(runValues))
+			for _, value := range runValues {
+				if !math.IsNaN(value) {
+					stepValues = append(stepValues, value)
+				}
+			}
+			sort.Float64s(stepValues)
+			ensembleStep := EnsembleStep{
+				Runs:        len(stepValues),
+				Percentiles: make([]EnsemblePercentile, len(sortedPercentiles)),
+			}
+			if len(stepValues) > 0 {
+				ensembleStep.Mean = stat.Mean(stepValues, nil)
+			}
+			for i, percentile := range sortedPercentiles {
+				ensembleStep.Percentiles[i].Percentile = percentile
+				if len(stepValues) > 0 {
+					ensembleStep.Percentiles[i].Value = stat.Quantile(percentile, stat.LinInterp, stepValues, nil)
+				}
+			}
+			output[metric][step] = ensembleStep
+		}
+	}
+	return output, nil
+}
+
+// clone returns an independent copy of the predictor using `random` as random source
+func (predictor *Predictor) clone(random *rand.Rand) *Predictor {
+	clone := *predictor
+	clone.currentState = make(map[string]string)
+	for metric, stateHistory := range predictor.currentState {
+		clone.currentState[metric] = stateHistory
+	}
+	clone.periodPath = append([]int{}, predictor.periodPath...)
+	clone.periodSizeCounter = append([]int{}, predictor.periodSizeCounter...)
+	if predictor.dwellRemaining != nil {
+		clone.dwellRemaining = make(map[string]int)
+		for metric, remaining := range predictor.dwellRemaining {
+			clone.dwellRemaining[metric] = remaining
+		}
+	}
+	clone.random = random
+	return &clone
+}
@@ -0,0 +1,51 @@
+package predictor
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEnsemble(t *testing.T) {
+	Convey("Should aggregate simulations reproducibly", t, func() {
+		predictor := NewPredictor(testProfile())
+		predictor.SetState(map[string]string{"a": "0", "b": "0"})
+		ensemble, err := predictor.Ensemble(2, 1000, []float64{0.1, 0.9}, 1)
+		So(err, ShouldBeNil)
+		again, err := predictor.Ensemble(2, 1000, []float64{0.1, 0.9}, 1)
+		So(err, ShouldBeNil)
+		So(ensemble, ShouldResemble, again)
+
+		So(ensemble["a"], ShouldHaveLength, 2)
+		So(ensemble["a"][0].Runs, ShouldEqual, 1000)
+		So(ensemble["a"][0].Percentiles[0].Percentile, ShouldEqual, 0.1)
+		// a: values 20 and 80, state 1 reached with 0.5, then with 0.75
+		So(ensemble["a"][0].Percentiles[0].Value, ShouldEqual, 20)
+		So(ensemble["a"][0].Percentiles[1].Value, ShouldEqual, 80)
+		So(ensemble["a"][0].Mean, ShouldAlmostEqual, 50, 5)
+		So(ensemble["a"][1].Mean, ShouldAlmostEqual, 65, 5)
+		// the predictor's state is not changed
+		So(predictor.currentState, ShouldResemble, map[string]string{"a": "0", "b": "0"})
+
+		_, err = predictor.Ensemble(1, 0, nil, 1)
+		So(err, ShouldNotBeNil)
+		_, err = predictor.Ensemble(1, 1, []float64{2}, 1)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Should agree with the forecast", t, func() {
+		for _, mode := range []PredictionMode{PredictionModeRootTx, PredictionModePhases} {
+			predictor := NewPredictor(testProfile())
+			predictor.SetMode(mode)
+			predictor.SetState(map[string]string{"a": "0", "b": "0"})
+			forecast, err := predictor.Forecast(2, nil)
+			So(err, ShouldBeNil)
+			ensemble, err := predictor.Ensemble(2, 2000, []float64{0.5}, 1)
+			So(err, ShouldBeNil)
+			for step := 0; step < 2; step++ {
+				So(ensemble["a"][step].Mean, ShouldAlmostEqual, forecast["a"][step].Expected, 2)
+				So(ensemble["b"][step].Mean, ShouldAlmostEqual, forecast["b"][step].Expected, 2)
+			}
+		}
+	})
+}
@@ -0,0 +1,211 @@
+package predictor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/cha87de/tsprofiler/models"
+	"github.com/cha87de/tsprofiler/utils"
+)
+
+// ForecastStep holds the forecast of a metric for a single step
+type ForecastStep struct {
+	// Expected is the expected value
+	Expected float64 `json:"expected"`
+
+	// Median is the median value
+	Median float64 `json:"median"`
+
+	// Intervals holds the central prediction interval for each requested coverage
+	Intervals []ForecastInterval `json:"intervals"`
+
+	// States holds the probability [0,1] of each state
+	States []float64 `json:"states"`
+}
+
+// ForecastInterval is the central prediction interval covering `Coverage` [0,1] of the probability mass
+type ForecastInterval struct {
+	Coverage float64 `json:"coverage"`
+	Lower    float64 `json:"lower"`
+	Upper    float64 `json:"upper"`
+}
+
+// Forecast propagates the state distribution of each metric `steps` steps
+// through the matrices of the prediction mode (see StateProbabilities),
+// without simulating or changing the predictor's state. It returns for each
+// metric and step the expected value, the median and the central prediction
+// intervals for the given `coverages` (e.g. 0.8, 0.95) in original units. Like
+// in the simulation, the values of a state are taken from the stats of the
+// matrix it is reached in (the phase's resp. period's matrix, weighted by the
+// probability of the phase resp. period): normally distributed with the mean
+// and stddev of the state stats, limited to their min and max. Without state
+// stats, values are uniformly distributed within the range the discretizer
+// maps to the state.
+func (predictor *Predictor) Forecast(steps int, coverages []float64) (map[string][]ForecastStep, error) {
+	for _, coverage := range coverages {
+		if coverage <= 0 || coverage >= 1 {
+			return nil, fmt.Errorf("invalid coverage %v, must be in (0,1)", coverage)
+		}
+	}
+	states := predictor.profile.Settings.States
+
+	output := make(map[string][]ForecastStep)
+	for metric, stateHistory := range predictor.currentState {
+		chain, err := newStateChain(predictor, metric)
+		if err != nil {
+			return nil, err
+		}
+		distribution := chain.start(stateHistory)
+		output[metric] = make([]ForecastStep, steps)
+		for step := 0; step < steps; step++ {
+			distribution, err = chain.step(distribution)
+			if err != nil {
+				return nil, err
+			}
+			ranges := make([]valueRange, 0)
+			for phase, phaseStates := range distribution.phaseStates(states) {
+				txmatrix, _ := chain.txMatrix(phase)
+				ranges = append(ranges, stateRanges(phaseStates, txmatrix)...)
+			}
+			output[metric][step] = forecastStep(distribution.states(states), ranges, coverages)
+		}
+	}
+	return output, nil
+}
+
+// valueRange holds the probability of a state reached in a matrix and the
+// distribution of its values within [lower,upper]: normal with mean and
+// stddev, limited to the range, or uniform if stddev is NaN
+type valueRange struct {
+	prob   float64
+	lower  float64
+	upper  float64
+	mean   float64
+	stddev float64
+}
+
+// cdf returns the probability of values <= x, without the range's probability
+func (r valueRange) cdf(x float64) float64 {
+	if x >= r.upper {
+		return 1
+	}
+	if x < r.lower {
+		return 0
+	}
+	if math.IsNaN(r.stddev) {
+		return (x - r.lower) / (r.upper - r.lower)
+	}
+	if r.stddev <= 0 {
+		if x >= math.Min(math.Max(r.mean, r.lower), r.upper) {
+			return 1
+		}
+		return 0
+	}
+	return normalCDF((x - r.mean) / r.stddev)
+}
+
+// expected returns the expected value, without the range's probability
+func (r valueRange) expected() float64 {
+	if math.IsNaN(r.stddev) {
+		return (r.lower + r.upper) / 2
+	}
+	if r.stddev <= 0 {
+		return math.Min(math.Max(r.mean, r.lower), r.upper)
+	}
+	// normal distribution with the tails cut off at lower and upper
+	a := (r.lower - r.mean) / r.stddev
+	b := (r.upper - r.mean) / r.stddev
+	cdfA, cdfB := normalCDF(a), normalCDF(b)
+	return r.lower*cdfA + r.upper*(1-cdfB) + r.mean*(cdfB-cdfA) + r.stddev*(normalPDF(a)-normalPDF(b))
+}
+
+// stateRanges returns the value ranges of the states with the probabilities
+// of `distribution`, according to the stats of `txmatrix`
+func stateRanges(distribution []float64, txmatrix models.TxMatrix) []valueRange {
+	states := len(distribution)
+	ranges := make([]valueRange, 0, states)
+	for state, prob := range distribution {
+		if prob <= 0 {
+			continue
+		}
+		if state < len(txmatrix.StateStats) && txmatrix.StateStats[state].Count > 0 {
+			stateStats := txmatrix.StateStats[state]
+			ranges = append(ranges, valueRange{prob: prob, lower: stateStats.Min, upper: stateStats.Max, mean: stateStats.Avg, stddev: stateStats.Stddev})
+			continue
+		}
+		lower, upper := utils.ClosestStateBounds(state, states, txmatrix.Stats.Min, txmatrix.Stats.Max)
+		ranges = append(ranges, valueRange{prob: prob, lower: lower, upper: upper, stddev: math.NaN()})
+	}
+	return ranges
+}
+
+// normalCDF is the cumulative distribution function of the standard normal distribution
+func normalCDF(x float64) float64 {
+	return (1 + math.Erf(x/math.Sqrt2)) / 2
+}
+
+// normalPDF is the density of the standard normal distribution
+func normalPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
+
+// forecastIterations is the amount of bisections to find a quantile
+const forecastIterations = 64
+
+// forecastStep computes expected value, median and intervals of the mixture of
+// the value ranges' distributions, `distribution` being the probabilities of
+// the states
+func forecastStep(distribution []float64, ranges []valueRange, coverages []float64) ForecastStep {
+	expected := float64(0)
+	mass := float64(0)
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, r := range ranges {
+		expected += r.prob * r.expected()
+		mass += r.prob
+		min = math.Min(min, r.lower)
+		max = math.Max(max, r.upper)
+	}
+
+	// quantile of the mixture, by bisection on its cumulative distribution function
+	cdf := func(x float64) float64 {
+		cumulative := float64(0)
+		for _, r := range ranges {
+			cumulative += r.prob * r.cdf(x)
+		}
+		return cumulative / mass
+	}
+	quantile := func(q float64) float64 {
+		if mass <= 0 {
+			return 0
+		}
+		lower, upper := min, max
+		for i := 0; i < forecastIterations && lower < upper; i++ {
+			x := (lower + upper) / 2
+			if cdf(x) >= q {
+				upper = x
+			} else {
+				lower = x
+			}
+		}
+		return upper
+	}
+
+	sortedCoverages := append([]float64{}, coverages...)
+	sort.Float64s(sortedCoverages)
+	intervals := make([]ForecastInterval, len(sortedCoverages))
+	for i, coverage := range sortedCoverages {
+		intervals[i] = ForecastInterval{
+			Coverage: coverage,
+			Lower:    quantile((1 - coverage) / 2),
+			Upper:    quantile((1 + coverage) / 2),
+		}
+	}
+
+	return ForecastStep{
+		Expected:  expected,
+		Median:    quantile(0.5),
+		Intervals: intervals,
+		States:    distribution,
+	}
+}
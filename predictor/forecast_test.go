@@ -0,0 +1,95 @@
+package predictor
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cha87de/tsprofiler/utils"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestForecast(t *testing.T) {
+	Convey("Should forecast the values of the state distribution", t, func() {
+		predictor := NewPredictor(testProfile())
+		predictor.SetState(map[string]string{"a": "0", "b": "0"})
+		forecast, err := predictor.Forecast(2, []float64{0.8})
+		So(err, ShouldBeNil)
+		So(forecast["a"], ShouldHaveLength, 2)
+
+		// a: values 20 and 80 with 0.5 each, then 0.25 and 0.75
+		So(forecast["a"][0].Expected, ShouldAlmostEqual, 50)
+		So(forecast["a"][0].Median, ShouldAlmostEqual, 20, 1e-6)
+		So(forecast["a"][0].Intervals[0].Lower, ShouldAlmostEqual, 20, 1e-6)
+		So(forecast["a"][0].Intervals[0].Upper, ShouldAlmostEqual, 80, 1e-6)
+		So(forecast["a"][1].Expected, ShouldAlmostEqual, 65)
+		So(forecast["a"][1].Median, ShouldAlmostEqual, 80, 1e-6)
+
+		// b without state stats: uniform within the state's range
+		lower, upper := utils.ClosestStateBounds(1, 2, 0, 100)
+		So(forecast["b"][0].Expected, ShouldAlmostEqual, (lower+upper)/2)
+		So(forecast["b"][0].Median, ShouldAlmostEqual, (lower+upper)/2, 1e-6)
+		So(forecast["b"][0].Intervals[0].Lower, ShouldAlmostEqual, lower+0.1*(upper-lower), 1e-6)
+
+		_, err = predictor.Forecast(1, []float64{1})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Should forecast the values with the stats of the phase the state is reached in", t, func() {
+		predictor := NewPredictor(testProfile())
+		predictor.SetMode(PredictionModePhases)
+		predictor.SetPhase(0)
+		predictor.SetState(map[string]string{"a": "0", "b": "0"})
+		forecast, err := predictor.Forecast(1, []float64{0.8})
+		So(err, ShouldBeNil)
+		// staying in phase 0 (0.5): 20 and 80, changing to phase 1 (0.5): 10 and 40
+		So(forecast["a"][0].States[0], ShouldAlmostEqual, 0.5)
+		So(forecast["a"][0].Expected, ShouldAlmostEqual, 0.25*(20+80+10+40))
+		So(forecast["a"][0].Intervals[0].Lower, ShouldAlmostEqual, 10, 1e-6)
+		So(forecast["a"][0].Median, ShouldAlmostEqual, 20, 1e-6)
+	})
+
+	Convey("Should forecast normally distributed values limited to the state's range", t, func() {
+		r := valueRange{prob: 1, lower: 0, upper: 10, mean: 5, stddev: 1}
+		So(r.cdf(5), ShouldAlmostEqual, 0.5)
+		So(r.cdf(-1), ShouldEqual, 0)
+		So(r.cdf(10), ShouldEqual, 1)
+		So(r.expected(), ShouldAlmostEqual, 5)
+		// the tail below 0 is cut off and moved to the lower bound
+		So(valueRange{prob: 1, lower: 0, upper: 10, mean: 0, stddev: 1}.expected(), ShouldAlmostEqual, normalPDF(0), 1e-9)
+	})
+
+	Convey("Should find the quantiles of the values censored at the bounds", t, func() {
+		// z of the 0.9 quantile of the standard normal distribution
+		z := 1.2815515655446004
+
+		// all mass in the lowest state, half of it cut off at its lower bound
+		lowest := forecastStep([]float64{1, 0}, []valueRange{{prob: 1, lower: 0, upper: 10, mean: 0, stddev: 5}}, []float64{0.8})
+		So(lowest.Median, ShouldAlmostEqual, 0, 1e-9)
+		So(lowest.Intervals[0].Lower, ShouldAlmostEqual, 0, 1e-9)
+		So(lowest.Intervals[0].Upper, ShouldAlmostEqual, 5*z, 1e-6)
+
+		// all mass in the highest state, half of it cut off at its upper bound
+		highest := forecastStep([]float64{0, 1}, []valueRange{{prob: 1, lower: 90, upper: 100, mean: 100, stddev: 5}}, []float64{0.8})
+		So(highest.Median, ShouldAlmostEqual, 100, 1e-9)
+		So(highest.Intervals[0].Lower, ShouldAlmostEqual, 100-5*z, 1e-6)
+		So(highest.Intervals[0].Upper, ShouldAlmostEqual, 100, 1e-9)
+
+		// all mass in a single value
+		point := forecastStep([]float64{1}, []valueRange{{prob: 1, lower: 20, upper: 20, mean: 20, stddev: 0}}, []float64{0.5, 0.95})
+		So(point.Expected, ShouldEqual, 20)
+		So(point.Median, ShouldEqual, 20)
+		So(point.Intervals, ShouldResemble, []ForecastInterval{{Coverage: 0.5, Lower: 20, Upper: 20}, {Coverage: 0.95, Lower: 20, Upper: 20}})
+
+		// the lowest state's single value, then uniformly distributed values
+		mixture := forecastStep([]float64{0.3, 0.7}, []valueRange{
+			{prob: 0.3, lower: 0, upper: 0, mean: 0, stddev: 0},
+			{prob: 0.7, lower: 10, upper: 20, stddev: math.NaN()},
+		}, []float64{0.8})
+		So(mixture.Intervals[0].Lower, ShouldAlmostEqual, 0, 1e-9)
+		So(mixture.Median, ShouldAlmostEqual, 10+10*0.2/0.7, 1e-6)
+		So(mixture.Intervals[0].Upper, ShouldAlmostEqual, 10+10*0.6/0.7, 1e-6)
+
+		// no probability mass
+		So(forecastStep([]float64{0, 0}, nil, []float64{0.8}).Median, ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,72 @@
+package predictor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cha87de/tsprofiler/models"
+)
+
+// nextJointState simulates the next states of all metrics at once using the
+// joint tx. It returns nil if the current joint state was never observed.
+func (predictor *Predictor) nextJointState(txmatrices []models.TxMatrix) (map[string]nextState, error) {
+	jointTx := predictor.profile.JointTx
+	if jointTx == nil {
+		return nil, fmt.Errorf("profile contains no joint tx, enable Settings.JointStates")
+	}
+
+	// current joint state from the last state of each metric
+	jointStates := make([]int64, len(jointTx.Metrics))
+	for i, metric := range jointTx.Metrics {
+		stateHistory, exists := predictor.currentState[metric]
+		if !exists {
+			return nil, nil
+		}
+		stateHistoryArr := strings.Split(stateHistory, "-")
+		state, err := strconv.ParseInt(stateHistoryArr[len(stateHistoryArr)-1], 10, 64)
+		if err != nil {
+			return nil, nil
+		}
+		jointStates[i] = state
+	}
+	jointStep, exists := jointTx.Transitions[models.JointKey(jointStates)]
+	if !exists || len(jointStep.NextStateProbs) == 0 {
+		return nil, nil
+	}
+
+	// weighted random variable on the sorted next joint states
+	nextKeys := make([]string, 0, len(jointStep.NextStateProbs))
+	for key := range jointStep.NextStateProbs {
+		nextKeys = append(nextKeys, key)
+	}
+	sort.Strings(nextKeys)
+	nextProbs := make([]int, len(nextKeys))
+	for i, key := range nextKeys {
+		nextProbs[i] = jointStep.NextStateProbs[key]
+	}
+	next, err := computeNextState(predictor.random, nextProbs)
+	if err != nil {
+		return nil, nil
+	}
+	nextJointStates, err := models.ParseJointKey(nextKeys[next])
+	if err != nil || len(nextJointStates) != len(jointTx.Metrics) {
+		return nil, fmt.Errorf("invalid joint state %s", nextKeys[next])
+	}
+
+	states := make(map[string]nextState)
+	for i, metric := range jointTx.Metrics {
+		txmatrix, err := findMetricInTxMatrices(txmatrices, metric)
+		if err != nil {
+			return nil, err
+		}
+		states[metric] = nextState{
+			state:      int(nextJointStates[i]),
+			states:     predictor.profile.Settings.States,
+			stats:      txmatrix.Stats,
+			stateStats: txmatrix.StateStats,
+		}
+	}
+	return states, nil
+}
@@ -5,52 +5,48 @@ import (
 	"math"
 )
 
-// Likeliness ...
+// Likeliness returns for each metric the probabilities [0,100] of the states
+// `steps` steps ahead of `currentState` (state histories per metric), see
+// StateProbabilities
 func (predictor *Predictor) Likeliness(currentState map[string]string, steps int) (map[string][]int, error) {
+	probabilities, err := predictor.StateProbabilities(currentState, steps)
+	if err != nil {
+		return nil, err
+	}
 	output := make(map[string][]int)
-
-	txMatrices := predictor.getTxMatrices()
-
-	// for each metric ...
-	for _, txMatrix := range txMatrices {
-		metric := txMatrix.Metric
-		transitions := txMatrix.Transitions
-		if _, exists := output[metric]; !exists {
-			output[metric] = make([]int, predictor.profile.Settings.States)
+	for metric, stateProbs := range probabilities {
+		output[metric] = make([]int, len(stateProbs))
+		for state, prob := range stateProbs {
+			output[metric][state] = int(math.Round(prob * 100))
 		}
+	}
+	return output, nil
+}
 
-		// select current state in transitions
-		//currentState := predictor.currentState[metric]
-		txStep := transitions[currentState[metric]]
-
-		//output[metric] = txStep.NextStateProbs
-
-		if steps > 1 {
-			// go to next step
-			for nextState, nextStateProb := range txStep.NextStateProbs {
-				if nextStateProb <= 0 {
-					// ignore if unlikely
-					continue
-				}
-				// go towards this next step
-				nextStateHist := make(map[string]string)
-				nextStateHist[metric] = fmt.Sprintf("%d", nextState)
-
-				nextStepProbs, _ := predictor.Likeliness(nextStateHist, steps-1)
-
-				for x := range output[metric] {
-					nextStepProb := float64(nextStepProbs[metric][x]) / float64(100)
-					thisStepProb := float64(nextStateProb) / float64(100)
-					prob := nextStepProb * thisStepProb
-
-					output[metric][x] += int(math.Round(prob * float64(100)))
-				}
+// StateProbabilities returns for each metric the probabilities [0,1] of the
+// states `steps` steps ahead of `currentState` (state histories per metric).
+// The distribution over the state histories (up to Settings.History states)
+// is multiplied step by step with the transition probabilities of the
+// prediction mode, changing phases according to the phase transitions resp.
+// moving on in the period tree per step. The predictor's state is not changed.
+func (predictor *Predictor) StateProbabilities(currentState map[string]string, steps int) (map[string][]float64, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("invalid amount of steps %d", steps)
+	}
+	output := make(map[string][]float64)
+	for _, metric := range sortedMetrics(currentState) {
+		chain, err := newStateChain(predictor, metric)
+		if err != nil {
+			return nil, err
+		}
+		distribution := chain.start(currentState[metric])
+		for step := 0; step < steps; step++ {
+			distribution, err = chain.step(distribution)
+			if err != nil {
+				return nil, err
 			}
-		} else {
-			// no more steps, return nextStateProbs
-			output[metric] = txStep.NextStateProbs
 		}
+		output[metric] = distribution.states(predictor.profile.Settings.States)
 	}
-
 	return output, nil
 }
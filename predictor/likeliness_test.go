@@ -0,0 +1,182 @@
+package predictor
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// historyProfile returns a profile of the metric "h" with two states and
+// History 2, its history 1-1 pruned to 1
+func historyProfile() models.TSProfile {
+	h := models.TxMatrix{
+		Metric: "h",
+		Transitions: map[string]models.TXStep{
+			"0":   {NextStateProbs: []int{60, 40}, StepProb: 50},
+			"1":   {NextStateProbs: []int{10, 90}, StepProb: 50},
+			"0-0": {NextStateProbs: []int{20, 80}, StepProb: 25},
+			"0-1": {NextStateProbs: []int{70, 30}, StepProb: 25},
+			"1-0": {NextStateProbs: []int{50, 50}, StepProb: 25},
+		},
+		Stats: models.TSStats{Min: 0, Max: 100, Count: 100},
+	}
+	return models.TSProfile{
+		RootTx:     []models.TxMatrix{h},
+		PeriodTree: models.NewPeriodTree(nil),
+		Settings: models.Settings{
+			States:  2,
+			History: 2,
+		},
+	}
+}
+
+// periodProfile returns a profile of the metric "p" with two states in a
+// period tree of size 2,2, state 0 stays in node 0 and changes in node 1
+func periodProfile() models.TSProfile {
+	txmatrix := func(probs0 []int, probs1 []int) []models.TxMatrix {
+		return []models.TxMatrix{{
+			Metric: "p",
+			Transitions: map[string]models.TXStep{
+				"0": {NextStateProbs: probs0, StepProb: 50},
+				"1": {NextStateProbs: probs1, StepProb: 50},
+			},
+			Stats: models.TSStats{Min: 0, Max: 100, Count: 100},
+		}}
+	}
+	profile := models.TSProfile{
+		RootTx:     txmatrix([]int{50, 50}, []int{50, 50}),
+		PeriodTree: models.NewPeriodTree([]int{2, 2}),
+		Settings: models.Settings{
+			States:     2,
+			History:    1,
+			PeriodSize: []int{2, 2},
+		},
+	}
+	profile.PeriodTree.GetNode([]int{0}).TxMatrix = txmatrix([]int{90, 10}, []int{30, 70})
+	profile.PeriodTree.GetNode([]int{1}).TxMatrix = txmatrix([]int{20, 80}, []int{60, 40})
+	return profile
+}
+
+// multiply returns the matrix product a*b
+func multiply(a [][]float64, b [][]float64) [][]float64 {
+	product := make([][]float64, len(a))
+	for i := range a {
+		product[i] = make([]float64, len(b[0]))
+		for j := range b[0] {
+			for k := range b {
+				product[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return product
+}
+
+// power returns the matrix power m^k
+func power(m [][]float64, k int) [][]float64 {
+	product := make([][]float64, len(m))
+	for i := range m {
+		product[i] = make([]float64, len(m))
+		product[i][i] = 1
+	}
+	for ; k > 0; k-- {
+		product = multiply(product, m)
+	}
+	return product
+}
+
+func TestStateProbabilities(t *testing.T) {
+	Convey("Should propagate the state distribution through the root tx", t, func() {
+		predictor := NewPredictor(testProfile())
+		probabilities, err := predictor.StateProbabilities(map[string]string{"a": "0", "b": "0"}, 2)
+		So(err, ShouldBeNil)
+		So(probabilities["a"][0], ShouldAlmostEqual, 0.25)
+		So(probabilities["a"][1], ShouldAlmostEqual, 0.75)
+		So(probabilities["b"], ShouldResemble, []float64{1, 0})
+
+		_, err = predictor.StateProbabilities(map[string]string{"a": "0"}, 0)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Should follow the phase changes in phases mode", t, func() {
+		predictor := NewPredictor(testProfile())
+		predictor.SetMode(PredictionModePhases)
+		predictor.SetPhase(1)
+		// phase 1 stays, "a" moves on to either state from state 1
+		probabilities, err := predictor.StateProbabilities(map[string]string{"a": "1"}, 1)
+		So(err, ShouldBeNil)
+		So(probabilities["a"][0], ShouldAlmostEqual, 0.5)
+		So(probabilities["a"][1], ShouldAlmostEqual, 0.5)
+	})
+
+	Convey("Should propagate the distribution over the state histories of History 2", t, func() {
+		// the histories 0-0, 0-1, 1-0 and 1-1 moving on to y-z with P(z|x-y)
+		next := [][]float64{{0.2, 0.8}, {0.7, 0.3}, {0.5, 0.5}, {0.1, 0.9}}
+		m := make([][]float64, 4)
+		for xy := range m {
+			m[xy] = make([]float64, 4)
+			y := xy % 2
+			for z := 0; z < 2; z++ {
+				m[xy][y*2+z] = next[xy][z]
+			}
+		}
+		predictor := NewPredictor(historyProfile())
+		for _, steps := range []int{1, 2, 3, 4, 5, 6, 300} {
+			probabilities, err := predictor.StateProbabilities(map[string]string{"h": "0-1"}, steps)
+			So(err, ShouldBeNil)
+			// start in 0-1, the current state is the last of the history
+			histories := power(m, steps)[1]
+			So(probabilities["h"][0], ShouldAlmostEqual, histories[0]+histories[2], 1e-9)
+			So(probabilities["h"][1], ShouldAlmostEqual, histories[1]+histories[3], 1e-9)
+		}
+	})
+
+	Convey("Should move on in the period tree per step in periods mode", t, func() {
+		// the matrices of the period nodes 0 and 1
+		nodes := [][][]float64{{{0.9, 0.1}, {0.3, 0.7}}, {{0.2, 0.8}, {0.6, 0.4}}}
+		// the first level moves on every 2 steps, after the first step
+		schedule := []int{0, 1, 1, 0, 0, 1, 1, 0}
+		predictor := NewPredictor(periodProfile())
+		predictor.SetMode(PredictionModePeriods)
+		predictor.SetPeriodPath([]int{0, 0}, 1)
+		product := [][]float64{{1, 0}, {0, 1}}
+		for step, node := range schedule {
+			product = multiply(product, nodes[node])
+			probabilities, err := predictor.StateProbabilities(map[string]string{"p": "0"}, step+1)
+			So(err, ShouldBeNil)
+			So(probabilities["p"][0], ShouldAlmostEqual, product[0][0], 1e-9)
+			So(probabilities["p"][1], ShouldAlmostEqual, product[0][1], 1e-9)
+		}
+		// the predictor's period path is not changed
+		So(predictor.periodPath, ShouldResemble, []int{0, 0})
+		So(predictor.periodSizeCounter, ShouldResemble, []int{0, 0})
+	})
+
+	Convey("Should keep the distribution stochastic over hundreds of steps", t, func() {
+		cases := []struct {
+			profile models.TSProfile
+			mode    PredictionMode
+			state   map[string]string
+		}{
+			{testProfile(), PredictionModeRootTx, map[string]string{"a": "0", "b": "0"}},
+			{testProfile(), PredictionModePhases, map[string]string{"a": "0", "b": "1"}},
+			{historyProfile(), PredictionModeRootTx, map[string]string{"h": "0-0"}},
+			{periodProfile(), PredictionModePeriods, map[string]string{"p": "1"}},
+		}
+		for _, c := range cases {
+			predictor := NewPredictor(c.profile)
+			predictor.SetMode(c.mode)
+			predictor.SetPeriodPath([]int{0, 0}, 1)
+			probabilities, err := predictor.StateProbabilities(c.state, 500)
+			So(err, ShouldBeNil)
+			for _, metricProbabilities := range probabilities {
+				So(sum(metricProbabilities), ShouldAlmostEqual, 1, 1e-9)
+				for _, prob := range metricProbabilities {
+					So(math.IsNaN(prob), ShouldBeFalse)
+					So(prob, ShouldBeBetweenOrEqual, 0, 1)
+				}
+			}
+		}
+	})
+}
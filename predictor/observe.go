@@ -0,0 +1,136 @@
+package predictor
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ObservedLikeliness returns for each metric of `states` the probability [0,1]
+// of the observed state one step ahead of the predictor's current state, see
+// StateProbabilities. The predictor's state is not changed.
+func (predictor *Predictor) ObservedLikeliness(states map[string]int) (map[string]float64, error) {
+	currentState := make(map[string]string)
+	for metric := range states {
+		if stateHistory, exists := predictor.currentState[metric]; exists {
+			currentState[metric] = stateHistory
+		}
+	}
+	probabilities, err := predictor.StateProbabilities(currentState, 1)
+	if err != nil {
+		return nil, err
+	}
+	output := make(map[string]float64)
+	for metric, stateProbs := range probabilities {
+		state := states[metric]
+		if state < 0 || state >= len(stateProbs) {
+			return nil, fmt.Errorf("invalid state %d of metric %s", state, metric)
+		}
+		output[metric] = stateProbs[state]
+	}
+	return output, nil
+}
+
+// Observe moves the predictor on by an observed step: like in the simulation,
+// the period path moves on resp. the phase changes, to the phase most likely
+// given the phase transitions and the observed `states`. The observed states
+// are appended to the state histories.
+func (predictor *Predictor) Observe(states map[string]int) error {
+	if predictor.mode == PredictionModePeriods && len(predictor.periodPath) > 0 {
+		predictor.nextPeriod(0)
+	} else if predictor.mode == PredictionModePhases {
+		phase, err := predictor.observedPhase(states)
+		if err != nil {
+			return err
+		}
+		predictor.currentPhase = phase
+	}
+	stateHistory := make(map[string]string)
+	for metric, state := range states {
+		stateHistory[metric] = strconv.Itoa(state)
+	}
+	predictor.appendState(stateHistory)
+	// the time already spent in the observed states is unknown
+	predictor.dwellRemaining = nil
+	return nil
+}
+
+// observedPhase returns the phase maximizing the probability to change into
+// it times the probabilities of the observed `states` in it. If no phase
+// reachable by the phase transitions explains the states, it returns the phase
+// explaining them best, like the profiler's phase detection matches all phases,
+// resp. keeps the current phase if none explains them.
+func (predictor *Predictor) observedPhase(states map[string]int) (int, error) {
+	chains := make(map[string]*stateChain)
+	for metric := range states {
+		chain, err := newStateChain(predictor, metric)
+		if err != nil {
+			return 0, err
+		}
+		chains[metric] = chain
+	}
+	if len(chains) == 0 {
+		return predictor.currentPhase, nil
+	}
+
+	// statesProb returns the probability of the observed states in phase
+	statesProb := func(phase int) (float64, error) {
+		prob := float64(1)
+		for metric, chain := range chains {
+			stateHistory := predictor.currentState[metric]
+			if phase != predictor.currentPhase {
+				// like in the simulation: a phase change resets the state
+				var err error
+				stateHistory, err = chain.initKey(phase)
+				if err != nil {
+					return 0, err
+				}
+			}
+			row, err := chain.row(phase, stateHistory)
+			if err != nil {
+				return 0, err
+			}
+			state := states[metric]
+			if state < 0 || state >= len(row) {
+				return 0, fmt.Errorf("invalid state %d of metric %s", state, metric)
+			}
+			prob *= row[state]
+		}
+		return prob, nil
+	}
+
+	bestPhase := predictor.currentPhase
+	bestProb := float64(-1)
+	var phaseRow []float64
+	for _, chain := range chains {
+		phaseRow = chain.phaseRow(predictor.currentPhase)
+		break
+	}
+	for phase, phaseProb := range phaseRow {
+		if phaseProb <= 0 {
+			continue
+		}
+		prob, err := statesProb(phase)
+		if err != nil {
+			return 0, err
+		}
+		if prob*phaseProb > bestProb {
+			bestPhase = phase
+			bestProb = prob * phaseProb
+		}
+	}
+	if bestProb > 0 {
+		return bestPhase, nil
+	}
+	bestPhase, bestProb = predictor.currentPhase, 0
+	for phase := range predictor.profile.Phases.Phases {
+		prob, err := statesProb(phase)
+		if err != nil {
+			return 0, err
+		}
+		if prob > bestProb {
+			bestPhase = phase
+			bestProb = prob
+		}
+	}
+	return bestPhase, nil
+}
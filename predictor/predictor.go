@@ -2,7 +2,9 @@ package predictor
 
 import (
 	"fmt"
+	"math/rand"
 	"strings"
+	"time"
 
 	"github.com/cha87de/tsprofiler/models"
 )
@@ -13,6 +15,7 @@ func NewPredictor(profile models.TSProfile) *Predictor {
 		profile:           profile,
 		currentPhase:      0,
 		periodSizeCounter: make([]int, len(profile.Settings.PeriodSize)),
+		random:            rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 	predictor.initializeState()
 	return &predictor
@@ -29,19 +32,25 @@ type Predictor struct {
 	periodPathDepth   int
 	periodSizeCounter []int
 
+	// remaining states to stay in the current state per metric (semi-Markov mode)
+	dwellRemaining map[string]int
+
 	mode PredictionMode
+
+	random *rand.Rand
 }
 
 type nextState struct {
-	state  int
-	states int
-	stats  models.TSStats
+	state      int
+	states     int
+	stats      models.TSStats
+	stateStats []models.TSStats
 }
 
 func (predictor *Predictor) getTxMatrices() []models.TxMatrix {
 	var txmatrices []models.TxMatrix
 	// define which matrices to be used (default: root matrix)
-	if predictor.mode == PredictionModeRootTx {
+	if predictor.mode == PredictionModeRootTx || predictor.mode == PredictionModeJoint || predictor.mode == PredictionModeSemiMarkov {
 		txmatrices = predictor.profile.PeriodTree.Root.TxMatrix
 		txmatrices = predictor.profile.RootTx
 	} else if predictor.mode == PredictionModePhases {
@@ -66,8 +75,20 @@ func (predictor *Predictor) nextState() (map[string]nextState, error) {
 	}
 	var txmatrices = predictor.getTxMatrices()
 
-	// for each metric
-	for metric, stateHistory := range predictor.currentState {
+	if predictor.mode == PredictionModeJoint {
+		states, err := predictor.nextJointState(txmatrices)
+		if err != nil {
+			return nil, err
+		}
+		if states != nil {
+			return states, nil
+		}
+		// joint state never observed, fall back to root tx
+	}
+
+	// for each metric, in a fixed order to draw random numbers reproducibly
+	for _, metric := range sortedMetrics(predictor.currentState) {
+		stateHistory := predictor.currentState[metric]
 		// find matrix for metric
 		txmatrix, err := findMetricInTxMatrices(txmatrices, metric)
 		if err != nil {
@@ -88,16 +109,22 @@ func (predictor *Predictor) nextState() (map[string]nextState, error) {
 		}
 
 		// weighted random variable to define next state on txsteps
-		next, err := computeNextState(txstep.NextStateProbs)
+		var next int
+		if predictor.mode == PredictionModeSemiMarkov {
+			next, err = predictor.nextSemiMarkovState(metric, txmatrix, stateHistory, txstep)
+		} else {
+			next, err = computeNextState(predictor.random, txstep.NextStateProbs)
+		}
 		if err != nil {
 			fmt.Printf("%s\n", err)
 			continue
 		}
 
 		states[metric] = nextState{
-			state:  next,
-			states: predictor.profile.Settings.States,
-			stats:  txmatrix.Stats,
+			state:      next,
+			states:     predictor.profile.Settings.States,
+			stats:      txmatrix.Stats,
+			stateStats: txmatrix.StateStats,
 		}
 	}
 
@@ -112,7 +139,7 @@ func (predictor *Predictor) nextPhase() {
 		fmt.Printf("phase change error: %s\n", err)
 		return
 	}
-	next, err := computeNextState(txstep.NextStateProbs)
+	next, err := computeNextState(predictor.random, txstep.NextStateProbs)
 	if err != nil {
 		fmt.Printf("phase change error: %s\n", err)
 		return
@@ -169,6 +196,7 @@ func (predictor *Predictor) nextPeriod(level int) bool {
 // SetState defines the given currentState for the next simulation
 func (predictor *Predictor) SetState(currentState map[string]string) {
 	predictor.currentState = currentState
+	predictor.dwellRemaining = nil
 }
 
 // SetPhase defines the given phase for the next simulation
@@ -182,12 +210,19 @@ func (predictor *Predictor) SetPeriodPath(periodPath []int, periodPathDepth int)
 	predictor.periodPathDepth = periodPathDepth
 }
 
+// SetRandom defines the source of randomness for the next simulation, e.g.
+// rand.New(rand.NewSource(seed)) for reproducible simulations
+func (predictor *Predictor) SetRandom(random *rand.Rand) {
+	predictor.random = random
+}
+
 // SetMode defines the given PredictionMode for the next simulation
 func (predictor *Predictor) SetMode(mode PredictionMode) {
 	predictor.mode = mode
 }
 
-// Simulate computes `steps` states using randomness and TSProfile's probabilities
+// Simulate computes `steps` states using randomness and TSProfile's
+// probabilities, with the simulated values sampled from the state stats
 func (predictor *Predictor) Simulate(steps int) ([][]models.TSState, error) {
 	simulation := make([][]models.TSState, steps)
 	for i := 0; i < steps; i++ {
@@ -198,16 +233,21 @@ func (predictor *Predictor) Simulate(steps int) ([][]models.TSState, error) {
 		j := 0
 		simulation[i] = make([]models.TSState, len(next))
 		nextStateHistory := make(map[string]string)
-		for metric, state := range next {
+		for _, metric := range sortedMetrics(predictor.currentState) {
+			state, exists := next[metric]
+			if !exists {
+				continue
+			}
 			// compute value from state
-			simValue := computeValueFromState(state.state, state.states, state.stats.Min, state.stats.Max, state.stats.Stddev)
+			simValue := computeValueFromState(predictor.random, state.state, state.states, state.stats, state.stateStats)
 
-			// pack value to array
+			// pack state and value to array
 			simulation[i][j] = models.TSState{
 				Metric: metric,
 				State: models.State{
-					Value: simValue,
+					Value: int64(state.state),
 				},
+				Value: simValue,
 			}
 			j++
 
@@ -225,14 +265,7 @@ func (predictor *Predictor) initializeState() {
 	for _, tx := range txmatrices {
 		if _, exists := currentState[tx.Metric]; !exists {
 			// find state with highest probability
-			state := ""
-			stepProb := 0
-			for s, txstep := range tx.Transitions {
-				if txstep.StepProb > stepProb {
-					state = s
-					stepProb = txstep.StepProb
-				}
-			}
+			state := dominantTransition(tx)
 			if state == "" {
 				fmt.Printf("failed to initialize state for metric %s\n", tx.Metric)
 				continue
@@ -241,6 +274,7 @@ func (predictor *Predictor) initializeState() {
 		}
 	}
 	predictor.currentState = currentState
+	predictor.dwellRemaining = nil
 }
 
 func (predictor *Predictor) appendState(state map[string]string) {
@@ -0,0 +1,258 @@
+package predictor
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// testProfile returns a profile with two states and the metrics "a" (state 0
+// moves on to either state, state 1 stays; values 20 and 80) and "b"
+// (alternating states, no state stats). In phase 1, "a" moves on to either
+// state from both states and its values are 10 and 40.
+func testProfile() models.TSProfile {
+	stats := models.TSStats{Min: 0, Max: 100, Count: 100}
+	a := models.TxMatrix{
+		Metric: "a",
+		Transitions: map[string]models.TXStep{
+			"0": {NextStateProbs: []int{50, 50}, StepProb: 50},
+			"1": {NextStateProbs: []int{0, 100}, StepProb: 50},
+		},
+		Stats: stats,
+		StateStats: []models.TSStats{
+			{Min: 20, Max: 20, Avg: 20, Count: 50},
+			{Min: 80, Max: 80, Avg: 80, Count: 50},
+		},
+	}
+	b := models.TxMatrix{
+		Metric: "b",
+		Transitions: map[string]models.TXStep{
+			"0": {NextStateProbs: []int{0, 100}, StepProb: 50},
+			"1": {NextStateProbs: []int{100, 0}, StepProb: 50},
+		},
+		Stats: stats,
+	}
+	aPhase := models.TxMatrix{
+		Metric: "a",
+		Transitions: map[string]models.TXStep{
+			"0": {NextStateProbs: []int{50, 50}, StepProb: 50},
+			"1": {NextStateProbs: []int{50, 50}, StepProb: 50},
+		},
+		Stats: stats,
+		StateStats: []models.TSStats{
+			{Min: 10, Max: 10, Avg: 10, Count: 50},
+			{Min: 40, Max: 40, Avg: 40, Count: 50},
+		},
+	}
+	return models.TSProfile{
+		RootTx: []models.TxMatrix{a, b},
+		Phases: models.Phases{
+			Phases: [][]models.TxMatrix{{a, b}, {aPhase, b}},
+			Tx: models.TxMatrix{
+				Metric: "phasetx",
+				Transitions: map[string]models.TXStep{
+					"0": {NextStateProbs: []int{50, 50}, StepProb: 50},
+					"1": {NextStateProbs: []int{0, 100}, StepProb: 50},
+				},
+			},
+		},
+		PeriodTree: models.NewPeriodTree(nil),
+		Settings: models.Settings{
+			States:  2,
+			History: 1,
+		},
+	}
+}
+
+// simulatedStates returns per step the simulated state of `metric`
+func simulatedStates(simulation [][]models.TSState, metric string) []int64 {
+	states := make([]int64, 0, len(simulation))
+	for _, tsstates := range simulation {
+		for _, tsstate := range tsstates {
+			if tsstate.Metric == metric {
+				states = append(states, tsstate.State.Value)
+			}
+		}
+	}
+	return states
+}
+
+func TestSimulate(t *testing.T) {
+	Convey("Should simulate reproducibly with the same seed", t, func() {
+		simulate := func() [][]models.TSState {
+			predictor := NewPredictor(testProfile())
+			predictor.SetState(map[string]string{"a": "0", "b": "0"})
+			predictor.SetRandom(rand.New(rand.NewSource(7)))
+			simulation, err := predictor.Simulate(20)
+			So(err, ShouldBeNil)
+			return simulation
+		}
+		simulation := simulate()
+		So(simulation, ShouldResemble, simulate())
+		So(simulatedStates(simulation, "b")[:4], ShouldResemble, []int64{1, 0, 1, 0})
+	})
+
+	Convey("Should simulate all metrics from the joint tx in joint mode", t, func() {
+		profile := testProfile()
+		profile.JointTx = &models.JointTx{
+			Metrics: []string{"a", "b"},
+			Transitions: map[string]models.JointStep{
+				// unlike in the root tx, b stays in state 0 while a moves to 1
+				"0,0": {NextStateProbs: map[string]int{"1,0": 100}, StepProb: 100},
+			},
+		}
+		predictor := NewPredictor(profile)
+		predictor.SetMode(PredictionModeJoint)
+		predictor.SetState(map[string]string{"a": "0", "b": "0"})
+		predictor.SetRandom(rand.New(rand.NewSource(1)))
+		simulation, err := predictor.Simulate(2)
+		So(err, ShouldBeNil)
+		So(simulatedStates(simulation, "a")[0], ShouldEqual, 1)
+		So(simulatedStates(simulation, "b")[0], ShouldEqual, 0)
+		// joint state 1,0 never observed: b falls back to the root tx
+		So(simulatedStates(simulation, "b")[1], ShouldEqual, 1)
+	})
+
+	Convey("Should keep the metrics' transitions and their correlation in joint mode", t, func() {
+		// b always equals a, the root tx are the marginals of the joint tx
+		profile := testProfile()
+		marginal := map[string]models.TXStep{
+			"0": {NextStateProbs: []int{70, 30}, StepProb: 50},
+			"1": {NextStateProbs: []int{40, 60}, StepProb: 50},
+		}
+		profile.RootTx[0].Transitions = marginal
+		profile.RootTx[1].Transitions = marginal
+		profile.JointTx = &models.JointTx{
+			Metrics: []string{"a", "b"},
+			Transitions: map[string]models.JointStep{
+				"0,0": {NextStateProbs: map[string]int{"0,0": 70, "1,1": 30}, StepProb: 50},
+				"1,1": {NextStateProbs: map[string]int{"0,0": 40, "1,1": 60}, StepProb: 50},
+			},
+		}
+		simulate := func(mode PredictionMode) ([]int64, []int64) {
+			predictor := NewPredictor(profile)
+			predictor.SetMode(mode)
+			predictor.SetState(map[string]string{"a": "0", "b": "0"})
+			predictor.SetRandom(rand.New(rand.NewSource(3)))
+			simulation, err := predictor.Simulate(4000)
+			So(err, ShouldBeNil)
+			return simulatedStates(simulation, "a"), simulatedStates(simulation, "b")
+		}
+
+		a, b := simulate(PredictionModeJoint)
+		So(a, ShouldResemble, b)
+		// transitions of a
+		counts := [2][2]float64{}
+		for i := 1; i < len(a); i++ {
+			counts[a[i-1]][a[i]]++
+		}
+		So(counts[0][0]/(counts[0][0]+counts[0][1]), ShouldAlmostEqual, 0.7, 0.03)
+		So(counts[1][1]/(counts[1][0]+counts[1][1]), ShouldAlmostEqual, 0.6, 0.03)
+
+		// independently simulated in root mode
+		a, b = simulate(PredictionModeRootTx)
+		So(a, ShouldNotResemble, b)
+	})
+
+	Convey("Should stay in states for the recorded dwell times in semi-Markov mode", t, func() {
+		profile := testProfile()
+		profile.RootTx = []models.TxMatrix{{
+			Metric: "c",
+			Transitions: map[string]models.TXStep{
+				"0": {NextStateProbs: []int{90, 10}, StepProb: 50},
+				"1": {NextStateProbs: []int{10, 90}, StepProb: 50},
+			},
+			Stats: models.TSStats{Min: 0, Max: 100, Count: 100},
+			// state 0 always for 3 states, state 1 for a single state
+			DwellTimes: [][]int{{0, 0, 4}, {4}},
+		}}
+		predictor := NewPredictor(profile)
+		predictor.SetMode(PredictionModeSemiMarkov)
+		predictor.SetState(map[string]string{"c": "0"})
+		simulation, err := predictor.Simulate(8)
+		So(err, ShouldBeNil)
+		// the current state counts as the first state of the initial stay
+		So(simulatedStates(simulation, "c"), ShouldResemble, []int64{0, 0, 1, 0, 0, 0, 1, 0})
+	})
+
+	Convey("Should sample the dwell times from the recorded histogram in semi-Markov mode", t, func() {
+		profile := testProfile()
+		profile.RootTx = []models.TxMatrix{{
+			Metric: "c",
+			Transitions: map[string]models.TXStep{
+				"0": {NextStateProbs: []int{50, 50}, StepProb: 50},
+				"1": {NextStateProbs: []int{50, 50}, StepProb: 50},
+			},
+			Stats: models.TSStats{Min: 0, Max: 100, Count: 100},
+			// state 0 for a single state with 0.75, for 3 states with 0.25
+			DwellTimes: [][]int{{3, 0, 1}, {4}},
+		}}
+		predictor := NewPredictor(profile)
+		predictor.SetMode(PredictionModeSemiMarkov)
+		predictor.SetState(map[string]string{"c": "1"})
+		predictor.SetRandom(rand.New(rand.NewSource(5)))
+		simulation, err := predictor.Simulate(8000)
+		So(err, ShouldBeNil)
+
+		// lengths of the complete stays in state 0
+		stays := make(map[int]float64)
+		total := 0.0
+		length := 0
+		for _, state := range simulatedStates(simulation, "c") {
+			if state == 0 {
+				length++
+				continue
+			}
+			if length > 0 {
+				stays[length]++
+				total++
+			}
+			length = 0
+		}
+		So(total, ShouldBeGreaterThan, 1000)
+		So(stays[1]/total, ShouldAlmostEqual, 0.75, 0.03)
+		So(stays[2], ShouldEqual, 0)
+		So(stays[3]/total, ShouldAlmostEqual, 0.25, 0.03)
+		So(stays, ShouldHaveLength, 2)
+	})
+}
+
+func TestObserve(t *testing.T) {
+	// in phase 1, "a" is always in state 1 and phase 0 cannot be reached
+	profile := testProfile()
+	profile.Phases.Phases[1][0].Transitions = map[string]models.TXStep{
+		"0": {NextStateProbs: []int{0, 100}, StepProb: 50},
+		"1": {NextStateProbs: []int{0, 100}, StepProb: 50},
+	}
+
+	Convey("Should stay in the phase explaining the observed states", t, func() {
+		predictor := NewPredictor(profile)
+		predictor.SetMode(PredictionModePhases)
+		predictor.SetPhase(1)
+		predictor.SetState(map[string]string{"a": "1", "b": "0"})
+		So(predictor.Observe(map[string]int{"a": 1, "b": 1}), ShouldBeNil)
+		So(predictor.currentPhase, ShouldEqual, 1)
+		So(predictor.currentState, ShouldResemble, map[string]string{"a": "1", "b": "1"})
+	})
+
+	Convey("Should change to an unreachable phase if no reachable phase explains the observed states", t, func() {
+		predictor := NewPredictor(profile)
+		predictor.SetMode(PredictionModePhases)
+		predictor.SetPhase(1)
+		predictor.SetState(map[string]string{"a": "1", "b": "0"})
+		So(predictor.Observe(map[string]int{"a": 0, "b": 1}), ShouldBeNil)
+		So(predictor.currentPhase, ShouldEqual, 0)
+	})
+
+	Convey("Should keep the phase if no phase explains the observed states", t, func() {
+		predictor := NewPredictor(profile)
+		predictor.SetMode(PredictionModePhases)
+		predictor.SetPhase(1)
+		predictor.SetState(map[string]string{"a": "1", "b": "0"})
+		// b never stays in state 0
+		So(predictor.Observe(map[string]int{"a": 0, "b": 0}), ShouldBeNil)
+		So(predictor.currentPhase, ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,78 @@
+package predictor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cha87de/tsprofiler/models"
+)
+
+// nextSemiMarkovState returns the next state of the metric simulated as
+// semi-Markov process: the metric stays in its state for a dwell time sampled
+// from the state's dwell time histogram, then leaves it to another state
+// according to `txstep`. States without dwell times change like in the
+// Markov chain.
+func (predictor *Predictor) nextSemiMarkovState(metric string, txmatrix models.TxMatrix, stateHistory string, txstep models.TXStep) (int, error) {
+	stateHistoryArr := strings.Split(stateHistory, "-")
+	current, err := strconv.Atoi(stateHistoryArr[len(stateHistoryArr)-1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid state history %s", stateHistory)
+	}
+	if predictor.dwellRemaining == nil {
+		predictor.dwellRemaining = make(map[string]int)
+	}
+
+	remaining, exists := predictor.dwellRemaining[metric]
+	if !exists {
+		// the current state was just entered
+		remaining = predictor.sampleDwellTime(txmatrix, current) - 1
+	}
+	if remaining > 0 {
+		// stay in the current state
+		predictor.dwellRemaining[metric] = remaining - 1
+		return current, nil
+	}
+
+	nextStateProbs := txstep.NextStateProbs
+	if dwellTimes(txmatrix, current) != nil && current < len(nextStateProbs) {
+		// the dwell time is over, leave the current state
+		nextStateProbs = append([]int{}, nextStateProbs...)
+		nextStateProbs[current] = 0
+	}
+	next, err := computeNextState(predictor.random, nextStateProbs)
+	if err != nil {
+		// no other state known, stay in the current state
+		next = current
+	}
+	predictor.dwellRemaining[metric] = predictor.sampleDwellTime(txmatrix, next) - 1
+	return next, nil
+}
+
+// sampleDwellTime returns a random dwell time of `state` from its dwell time
+// histogram, 1 if the histogram is unknown
+func (predictor *Predictor) sampleDwellTime(txmatrix models.TxMatrix, state int) int {
+	histogram := dwellTimes(txmatrix, state)
+	if histogram == nil {
+		return 1
+	}
+	index, err := computeNextState(predictor.random, histogram)
+	if err != nil {
+		return 1
+	}
+	return index + 1
+}
+
+// dwellTimes returns the dwell time histogram of `state`, nil if no stay in
+// the state was recorded
+func dwellTimes(txmatrix models.TxMatrix, state int) []int {
+	if state < 0 || state >= len(txmatrix.DwellTimes) {
+		return nil
+	}
+	for _, n := range txmatrix.DwellTimes[state] {
+		if n > 0 {
+			return txmatrix.DwellTimes[state]
+		}
+	}
+	return nil
+}
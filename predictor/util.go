@@ -4,10 +4,11 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"sort"
 	"strings"
 
 	"github.com/cha87de/tsprofiler/models"
-	"github.com/jmcvetta/randutil"
+	"github.com/cha87de/tsprofiler/utils"
 )
 
 func findMetricInTxMatrices(txmatrices []models.TxMatrix, metric string) (models.TxMatrix, error) {
@@ -46,14 +47,7 @@ func findStateHistoryInTxMatrix(txmatrix models.TxMatrix, stateHistory string) (
 
 func findStateByStateProbInTxmatrix(txmatrix models.TxMatrix) (models.TXStep, error) {
 	// find state with highest probability
-	state := ""
-	stepProb := 0
-	for s, txstep := range txmatrix.Transitions {
-		if txstep.StepProb > stepProb {
-			state = s
-			stepProb = txstep.StepProb
-		}
-	}
+	state := dominantTransition(txmatrix)
 	if state == "" {
 		err := fmt.Errorf("failed to initialize state for metric %s", txmatrix.Metric)
 		return models.TXStep{}, err
@@ -66,30 +60,65 @@ func findStateByStateProbInTxmatrix(txmatrix models.TxMatrix) (models.TXStep, er
 	return step, nil
 }
 
-func computeNextState(nextStateProbs []int) (int, error) {
-	choices := make([]randutil.Choice, len(nextStateProbs))
-	for i, n := range nextStateProbs {
-		choices[i] = randutil.Choice{
-			Weight: n,
-			Item:   i,
+// dominantTransition returns the transition key with the highest step
+// probability, the lowest key on ties and "" if there is none
+func dominantTransition(txmatrix models.TxMatrix) string {
+	state := ""
+	stepProb := 0
+	for s, txstep := range txmatrix.Transitions {
+		if txstep.StepProb > stepProb || (txstep.StepProb == stepProb && state != "" && s < state) {
+			state = s
+			stepProb = txstep.StepProb
 		}
 	}
+	return state
+}
 
-	result, err := randutil.WeightedChoice(choices)
-	if err != nil {
-		return 0, err
+// sortedMetrics returns the metrics of the state histories in sorted order
+func sortedMetrics(stateHistories map[string]string) []string {
+	metrics := make([]string, 0, len(stateHistories))
+	for metric := range stateHistories {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+	return metrics
+}
+
+// computeNextState chooses the next state randomly, weighted by nextStateProbs
+func computeNextState(random *rand.Rand, nextStateProbs []int) (int, error) {
+	total := 0
+	for _, n := range nextStateProbs {
+		if n > 0 {
+			total += n
+		}
+	}
+	if total <= 0 {
+		return 0, fmt.Errorf("no next state with positive probability")
 	}
 
-	return result.Item.(int), nil
+	r := random.Intn(total)
+	for i, n := range nextStateProbs {
+		if n <= 0 {
+			continue
+		}
+		if r < n {
+			return i, nil
+		}
+		r -= n
+	}
+	return len(nextStateProbs) - 1, nil
 }
 
-func computeValueFromState(state int, states int, min float64, max float64, stddev float64) int64 {
-	stateSize := math.Round(float64(max-min) / float64(states))
-	if stateSize <= 0 {
-		//fmt.Printf("stateSize 0?!")
-		return int64(0)
+// computeValueFromState samples a value of the state from a normal
+// distribution with the state's mean and stddev, limited to the state's min
+// and max. Without state stats, the value is uniformly distributed within the
+// range the discretizer maps to the state.
+func computeValueFromState(random *rand.Rand, state int, states int, stats models.TSStats, stateStats []models.TSStats) float64 {
+	if state < len(stateStats) && stateStats[state].Count > 0 {
+		s := stateStats[state]
+		value := s.Avg + random.NormFloat64()*s.Stddev
+		return math.Min(math.Max(value, s.Min), s.Max)
 	}
-	noise := float64(rand.Intn(int(stateSize))) * (stddev / max)
-	value := min + float64(state)*stateSize + noise
-	return int64(math.Round(value))
+	lower, upper := utils.ClosestStateBounds(state, states, stats.Min, stats.Max)
+	return lower + random.Float64()*(upper-lower)
 }
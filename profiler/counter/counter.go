@@ -3,6 +3,9 @@ package counter
 import (
 	"fmt"
 	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/cha87de/tsprofiler/api"
@@ -19,12 +22,83 @@ func NewCounter(history int, states int, buffersize int, profiler api.TSProfiler
 		currentState:        make(map[string][]models.State),
 		stateChangeCounters: make(map[string]map[string][]int64),
 		stats:               make(map[string]models.TSStats),
+		stateStats:          make(map[string][]models.TSStats),
+		contextTrees:        make(map[string]*utils.ContextTree),
 		access:              &sync.Mutex{},
 
 		history:    history,
 		states:     states,
 		buffersize: buffersize,
+
+		contextMinCount:      defaultContextMinCount,
+		contextMinDivergence: defaultContextMinDivergence,
+	}
+}
+
+// defaults of the context pruning of the history transitions
+const (
+	defaultContextMinCount      = 2
+	defaultContextMinDivergence = 0.01
+)
+
+// SetContextPruning sets how the transitions of histories longer than one state
+// are pruned: a history is kept if counted at least `minCount` times and its
+// next states diverge at least `minDivergence` (Jensen-Shannon divergence)
+// from the next states of the history without its oldest state. Zero values
+// select the defaults 2 and 0.01, a negative `minDivergence` keeps histories
+// regardless of their divergence. A negative `minCount` disables the context
+// tree: all histories are kept with their observed frequencies, unsmoothed.
+func (counter *Counter) SetContextPruning(minCount int64, minDivergence float32) {
+	counter.access.Lock()
+	defer counter.access.Unlock()
+	if minCount == 0 {
+		minCount = defaultContextMinCount
+	}
+	if minDivergence == 0 {
+		minDivergence = defaultContextMinDivergence
 	}
+	counter.contextMinCount = minCount
+	counter.contextMinDivergence = float64(minDivergence)
+	counter.contextTrees = make(map[string]*utils.ContextTree)
+}
+
+// usesContextTree returns whether the transitions of the histories are
+// modelled by the pruned and smoothed context tree
+func (counter *Counter) usesContextTree() bool {
+	return counter.history > 1 && counter.contextMinCount >= 0
+}
+
+// contextTree returns the context tree of metric's transitions, built once
+// and updated by each count
+func (counter *Counter) contextTree(metric string) *utils.ContextTree {
+	tree, exists := counter.contextTrees[metric]
+	if !exists {
+		tree = utils.NewContextTree(counter.stateChangeCounters[metric], counter.contextMinCount, counter.contextMinDivergence)
+		counter.contextTrees[metric] = tree
+	}
+	return tree
+}
+
+// maxDwellTime limits the dwell time histograms, longer stays are counted as
+// staying maxDwellTime states
+const maxDwellTime = 1024
+
+// SetDwellTimes enables or disables recording per state the histogram of the
+// dwell times, i.e. the amount of states the metric stayed in a state
+func (counter *Counter) SetDwellTimes(dwell bool) {
+	counter.access.Lock()
+	defer counter.access.Unlock()
+	counter.dwell = dwell
+	counter.dwellTimes = make(map[string][][]int64)
+	counter.dwellLength = make(map[string]int64)
+}
+
+// SetJoint enables or disables counting the transitions of the joint states of all metrics
+func (counter *Counter) SetJoint(joint bool) {
+	counter.access.Lock()
+	defer counter.access.Unlock()
+	counter.joint = joint
+	counter.jointCounters = make(map[string]map[string]int64)
 }
 
 // Counter takes a discretized TSState and counts the transition matrix
@@ -36,16 +110,38 @@ type Counter struct {
 	currentState        map[string][]models.State
 	stateChangeCounters map[string]map[string][]int64
 	stats               map[string]models.TSStats
+	stateStats          map[string][]models.TSStats
+	contextTrees        map[string]*utils.ContextTree
 	access              *sync.Mutex
 
+	// joint state (all metrics) counting
+	joint         bool
+	jointMetrics  []string
+	jointState    string
+	jointCounters map[string]map[string]int64
+
+	// dwell times counting: histograms per metric and state, and the length
+	// of the current stay per metric
+	dwell       bool
+	dwellTimes  map[string][][]int64
+	dwellLength map[string]int64
+
 	// configs
 	history    int
 	states     int
 	buffersize int
+
+	// pruning of the history transitions
+	contextMinCount      int64
+	contextMinDivergence float64
 }
 
-// Likeliness returns the probability [0,1] for the state change from historic previous to next TSState
+// Likeliness returns the probability [0,1] for the state change from historic
+// previous to next TSState. Like the tx matrices of GetTx, histories longer than
+// one state follow the context tree, i.e. never seen histories are cut.
 func (counter *Counter) Likeliness(next []models.TSState) float32 {
+	counter.access.Lock()
+	defer counter.access.Unlock()
 	var count float32
 	var likeliness float32
 
@@ -56,6 +152,16 @@ func (counter *Counter) Likeliness(next []models.TSState) float32 {
 			continue
 		}
 		history := utils.HistoryStateAsString(previousMetric)
+		if counter.usesContextTree() {
+			probs := counter.contextTree(tsstate.Metric).Probabilities(history)
+			if int64(len(probs)) <= tsstate.State.Value {
+				// history or next state never seen before!
+				continue
+			}
+			likeliness += float32(probs[tsstate.State.Value])
+			count++
+			continue
+		}
 		stateCounts := counter.stateChangeCounters[tsstate.Metric][history]
 
 		var stateCountsTotal int64
@@ -78,6 +184,83 @@ func (counter *Counter) Likeliness(next []models.TSState) float32 {
 	return total
 }
 
+// MetricLikeliness returns per metric the probability [0,1] of the state change
+// from the historic previous to the next TSState. Unlike Likeliness, never seen
+// next states are 0, never seen histories are cut (oldest first) and metrics
+// without any known history are missing. Like in GetTx, histories longer than
+// one state follow the context tree.
+func (counter *Counter) MetricLikeliness(next []models.TSState) map[string]float32 {
+	counter.access.Lock()
+	defer counter.access.Unlock()
+
+	likeliness := make(map[string]float32)
+	for _, tsstate := range next {
+		previous := counter.currentState[tsstate.Metric]
+		if counter.usesContextTree() {
+			probs := counter.contextTree(tsstate.Metric).Probabilities(utils.HistoryStateAsString(previous))
+			if probs == nil {
+				continue
+			}
+			likeliness[tsstate.Metric] = 0
+			if tsstate.State.Value >= 0 && tsstate.State.Value < int64(len(probs)) {
+				likeliness[tsstate.Metric] = float32(probs[tsstate.State.Value])
+			}
+			continue
+		}
+		for ; len(previous) > 0; previous = previous[1:] {
+			stateCounts := counter.stateChangeCounters[tsstate.Metric][utils.HistoryStateAsString(previous)]
+			var stateCountsTotal int64
+			for _, n := range stateCounts {
+				stateCountsTotal += n
+			}
+			if stateCountsTotal == 0 {
+				// history never seen before, cut oldest state
+				continue
+			}
+			var stateCountsNext int64
+			if tsstate.State.Value >= 0 && tsstate.State.Value < int64(len(stateCounts)) {
+				stateCountsNext = stateCounts[tsstate.State.Value]
+			}
+			likeliness[tsstate.Metric] = float32(stateCountsNext) / float32(stateCountsTotal)
+			break
+		}
+	}
+	return likeliness
+}
+
+// TxLikeliness returns per metric the probability [0,1] of the state change
+// from the counter's historic previous to the next TSState according to
+// txMatrices, e.g. the merged matrices of a period tree node. Metrics without
+// known history in txMatrices are missing.
+func (counter *Counter) TxLikeliness(txMatrices []models.TxMatrix, next []models.TSState) map[string]float32 {
+	counter.access.Lock()
+	defer counter.access.Unlock()
+
+	likeliness := make(map[string]float32)
+	for _, tsstate := range next {
+		for _, txMatrix := range txMatrices {
+			if txMatrix.Metric != tsstate.Metric {
+				continue
+			}
+			previous := counter.currentState[tsstate.Metric]
+			for ; len(previous) > 0; previous = previous[1:] {
+				txStep, exists := txMatrix.Transitions[utils.HistoryStateAsString(previous)]
+				if !exists {
+					// history never seen before, cut oldest state
+					continue
+				}
+				var prob int
+				if tsstate.State.Value >= 0 && tsstate.State.Value < int64(len(txStep.NextStateProbs)) {
+					prob = txStep.NextStateProbs[tsstate.State.Value]
+				}
+				likeliness[tsstate.Metric] = float32(prob) / 100
+				break
+			}
+		}
+	}
+	return likeliness
+}
+
 // Totalcounts returns the summed up total amount of counter values
 func (counter *Counter) Totalcounts() int64 {
 	var total int64
@@ -99,6 +282,54 @@ func (counter *Counter) Count(tsstates []models.TSState) {
 		// for each metric, add the given TSState
 		counter.count(tsstate)
 	}
+	if counter.joint {
+		counter.countJoint(tsstates)
+	}
+}
+
+// countJoint counts the transition from the previous to the joint state of all metrics
+func (counter *Counter) countJoint(tsstates []models.TSState) {
+	counter.access.Lock()
+	defer counter.access.Unlock()
+
+	states := make(map[string]int64)
+	for _, tsstate := range tsstates {
+		if tsstate.Metric == "" {
+			// no valid state discretized
+			counter.jointState = ""
+			return
+		}
+		states[tsstate.Metric] = tsstate.State.Value
+	}
+	if counter.jointMetrics == nil {
+		for metric := range states {
+			counter.jointMetrics = append(counter.jointMetrics, metric)
+		}
+		sort.Strings(counter.jointMetrics)
+	}
+	if len(states) != len(counter.jointMetrics) {
+		// count only the metrics seen first
+		counter.jointState = ""
+		return
+	}
+	jointStates := make([]int64, len(counter.jointMetrics))
+	for i, metric := range counter.jointMetrics {
+		state, exists := states[metric]
+		if !exists {
+			counter.jointState = ""
+			return
+		}
+		jointStates[i] = state
+	}
+
+	jointState := models.JointKey(jointStates)
+	if counter.jointState != "" {
+		if _, exists := counter.jointCounters[counter.jointState]; !exists {
+			counter.jointCounters[counter.jointState] = make(map[string]int64)
+		}
+		counter.jointCounters[counter.jointState][jointState]++
+	}
+	counter.jointState = jointState
 }
 
 // count takes a tsstate from a single metric, while Count takes an array
@@ -141,6 +372,19 @@ func (counter *Counter) count(tsstate models.TSState) {
 	if changeDimension {
 		//fmt.Printf("before: %+v\n", counter.stateChangeCounters[metric])
 		counter.stateChangeCounters[metric] = utils.ChangeDimension(counter.stateChangeCounters[metric], counter.stats[metric], stats, counter.states)
+		delete(counter.contextTrees, metric)
+		counter.stateStats[metric] = utils.ChangeStateStatsDimension(counter.stateStats[metric], counter.stats[metric], stats, counter.states)
+		if counter.dwell {
+			counter.dwellTimes[metric] = utils.ChangeDwellDimension(counter.dwellTimes[metric], counter.stats[metric], stats, counter.states)
+		}
+		if counter.joint {
+			for i, jointMetric := range counter.jointMetrics {
+				if jointMetric == metric {
+					counter.jointCounters = utils.ChangeJointDimension(counter.jointCounters, i, counter.stats[metric], stats, counter.states)
+					counter.jointState = ""
+				}
+			}
+		}
 		//fmt.Printf("after: %+v\n", counter.stateChangeCounters[metric])
 	}
 
@@ -155,12 +399,21 @@ func (counter *Counter) count(tsstate models.TSState) {
 	globalStats.Stddev = math.Sqrt(globalStats.StddevSum / float64(globalStats.Count))
 	counter.stats[metric] = globalStats
 
+	// update the stats of the values discretized into the state
+	for int64(len(counter.stateStats[metric])) <= tsstate.State.Value {
+		counter.stateStats[metric] = append(counter.stateStats[metric], models.TSStats{})
+	}
+	counter.stateStats[metric][tsstate.State.Value] = utils.MergeStats(counter.stateStats[metric][tsstate.State.Value], utils.ValueStats(tsstate.Value))
+
 	// handle state transitioning
 	_, ok := counter.currentState[metric]
 	if !ok {
 		counter.currentState[metric] = make([]models.State, counter.history)
 	}
 	previousState := counter.currentState[metric]
+	if counter.dwell && len(previousState) > 0 {
+		counter.countDwell(metric, previousState[len(previousState)-1].Value, tsstate.State.Value)
+	}
 	for len(previousState) > 0 {
 		// first, find the previous state path
 		previousStateIdent := ""
@@ -181,6 +434,9 @@ func (counter *Counter) count(tsstate models.TSState) {
 			counter.stateChangeCounters[metric][previousStateIdent] = make([]int64, counter.states)
 		}
 		counter.stateChangeCounters[metric][previousStateIdent][tsstate.State.Value]++
+		if tree, exists := counter.contextTrees[metric]; exists {
+			tree.Update(previousStateIdent, counter.stateChangeCounters[metric][previousStateIdent], tsstate.State.Value)
+		}
 		previousState = previousState[1:] // remove the handled previous state
 	}
 
@@ -192,30 +448,124 @@ func (counter *Counter) count(tsstate models.TSState) {
 
 }
 
+// countDwell extends the current stay of metric in state `previous`, or
+// records its dwell time if the metric changed to state `next`
+func (counter *Counter) countDwell(metric string, previous int64, next int64) {
+	length := counter.dwellLength[metric]
+	if length > 0 && previous != next {
+		for int64(len(counter.dwellTimes[metric])) <= previous {
+			counter.dwellTimes[metric] = append(counter.dwellTimes[metric], nil)
+		}
+		if length > maxDwellTime {
+			length = maxDwellTime
+		}
+		histogram := counter.dwellTimes[metric][previous]
+		for int64(len(histogram)) < length {
+			histogram = append(histogram, 0)
+		}
+		histogram[length-1]++
+		counter.dwellTimes[metric][previous] = histogram
+		length = 0
+	}
+	counter.dwellLength[metric] = length + 1
+}
+
 // GetTx returns the probability matrix for each metric
 func (counter *Counter) GetTx() []models.TxMatrix {
 	counter.access.Lock()
 	defer counter.access.Unlock()
 	var metrics []models.TxMatrix
-	for metric, stateChangeCounter := range counter.stateChangeCounters {
+	// sorted by metric, the period tree merges matrices by index
+	for _, metric := range counter.sortedMetrics() {
+		stateChangeCounter := counter.stateChangeCounters[metric]
 		stats := counter.stats[metric]
 		maxCount := float64(stats.Count) / float64(counter.buffersize) // count only discrete states (stats.Count counts TSInput measurements)
-		transitions := utils.ComputeProbabilities(stateChangeCounter, maxCount)
+		var transitions map[string]models.TXStep
+		if counter.usesContextTree() {
+			// variable order: pruned and smoothed context tree of the histories
+			transitions = counter.contextTree(metric).Transitions(maxCount)
+		} else {
+			transitions = utils.ComputeProbabilities(stateChangeCounter, maxCount)
+		}
 		// fmt.Printf("counter %+v, probs: %+v\n", metricProfiler.counts.stateChangeCounter, txmatrix)
+		var stateStats []models.TSStats
+		if len(counter.stateStats[metric]) > 0 {
+			stateStats = append(stateStats, counter.stateStats[metric]...)
+		}
+		var dwellTimes [][]int
+		for _, histogram := range counter.dwellTimes[metric] {
+			row := make([]int, len(histogram))
+			for i, n := range histogram {
+				row[i] = int(n)
+			}
+			dwellTimes = append(dwellTimes, row)
+		}
 		metrics = append(metrics, models.TxMatrix{
 			Metric:      metric,
 			Transitions: transitions,
 			Stats:       stats,
+			StateStats:  stateStats,
+			DwellTimes:  dwellTimes,
 		})
 	}
 	return metrics
 }
 
+func (counter *Counter) sortedMetrics() []string {
+	metrics := make([]string, 0, len(counter.stateChangeCounters))
+	for metric := range counter.stateChangeCounters {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+	return metrics
+}
+
+// GetJointTx returns the joint states' transition probabilities, nil if joint counting is disabled
+func (counter *Counter) GetJointTx() *models.JointTx {
+	counter.access.Lock()
+	defer counter.access.Unlock()
+	if !counter.joint {
+		return nil
+	}
+	total := int64(0)
+	for _, nextCounts := range counter.jointCounters {
+		for _, n := range nextCounts {
+			total += n
+		}
+	}
+	jointTx := models.JointTx{
+		Metrics:     counter.jointMetrics,
+		Transitions: make(map[string]models.JointStep),
+	}
+	for jointState, nextCounts := range counter.jointCounters {
+		sum := int64(0)
+		for _, n := range nextCounts {
+			sum += n
+		}
+		if sum == 0 {
+			continue
+		}
+		nextStateProbs := make(map[string]int)
+		for nextState, n := range nextCounts {
+			nextStateProbs[nextState] = int(utils.Round(float64(n) / float64(sum) * 100))
+		}
+		jointTx.Transitions[jointState] = models.JointStep{
+			NextStateProbs: nextStateProbs,
+			StepProb:       int(utils.Round(float64(sum) / float64(total) * 100)),
+		}
+	}
+	return &jointTx
+}
+
 // GetStats returns the counter's current statistics as TSStats per metric
 func (counter *Counter) GetStats() map[string]models.TSStats {
 	counter.access.Lock()
 	defer counter.access.Unlock()
-	return counter.stats
+	stats := make(map[string]models.TSStats, len(counter.stats))
+	for metric, metricStats := range counter.stats {
+		stats[metric] = metricStats
+	}
+	return stats
 }
 
 // Reset clears the counters, state, and stats
@@ -225,6 +575,12 @@ func (counter *Counter) Reset() {
 	counter.currentState = make(map[string][]models.State)
 	counter.stateChangeCounters = make(map[string]map[string][]int64)
 	counter.stats = make(map[string]models.TSStats)
+	counter.stateStats = make(map[string][]models.TSStats)
+	counter.contextTrees = make(map[string]*utils.ContextTree)
+	counter.jointState = ""
+	counter.jointCounters = make(map[string]map[string]int64)
+	counter.dwellTimes = make(map[string][][]int64)
+	counter.dwellLength = make(map[string]int64)
 }
 
 // ResetCounters clears the counters only
@@ -232,6 +588,9 @@ func (counter *Counter) ResetCounters() {
 	counter.access.Lock()
 	defer counter.access.Unlock()
 	counter.stateChangeCounters = make(map[string]map[string][]int64)
+	counter.contextTrees = make(map[string]*utils.ContextTree)
+	counter.jointCounters = make(map[string]map[string]int64)
+	counter.dwellTimes = make(map[string][][]int64)
 }
 
 // ResetStats clears the stats only
@@ -239,4 +598,214 @@ func (counter *Counter) ResetStats() {
 	counter.access.Lock()
 	defer counter.access.Unlock()
 	counter.stats = make(map[string]models.TSStats)
+	counter.stateStats = make(map[string][]models.TSStats)
+}
+
+// Steps returns the amount of counted discretized buffers
+func (counter *Counter) Steps() int64 {
+	counter.access.Lock()
+	defer counter.access.Unlock()
+	var steps int64
+	for _, stats := range counter.stats {
+		if metricSteps := stats.Count / int64(counter.buffersize); metricSteps > steps {
+			steps = metricSteps
+		}
+	}
+	return steps
+}
+
+// Divergence returns how different [0,1] the transitions of the counter and
+// other are, as Jensen-Shannon divergence of the state change counters on the
+// common min and max, averaged over the metrics. Metrics counted by one
+// counter only diverge by 1.
+func (counter *Counter) Divergence(other *Counter) float64 {
+	counter.access.Lock()
+	defer counter.access.Unlock()
+	other.access.Lock()
+	defer other.access.Unlock()
+
+	metrics := make(map[string]bool)
+	for metric := range counter.stats {
+		metrics[metric] = true
+	}
+	for metric := range other.stats {
+		metrics[metric] = true
+	}
+	if len(metrics) == 0 {
+		return 0
+	}
+	var divergence float64
+	for metric := range metrics {
+		stats, exists := counter.stats[metric]
+		otherStats, otherExists := other.stats[metric]
+		if !exists || !otherExists {
+			divergence++
+			continue
+		}
+		common := utils.MergeStats(stats, otherStats)
+		counts := counter.stateChangeCounters[metric]
+		if common.Min < stats.Min || common.Max > stats.Max {
+			counts = utils.ChangeDimension(counts, stats, common, counter.states)
+		}
+		otherCounts := other.stateChangeCounters[metric]
+		if common.Min < otherStats.Min || common.Max > otherStats.Max {
+			otherCounts = utils.ChangeDimension(otherCounts, otherStats, common, counter.states)
+		}
+		divergence += utils.MatrixDivergence(counts, otherCounts)
+	}
+	return divergence / float64(len(metrics))
+}
+
+// Merge adds the state change counters and stats of other to the counter,
+// both moved to the common min and max of each metric. The joint counters
+// are not merged.
+func (counter *Counter) Merge(other *Counter) {
+	counter.access.Lock()
+	defer counter.access.Unlock()
+	other.access.Lock()
+	defer other.access.Unlock()
+	counter.contextTrees = make(map[string]*utils.ContextTree)
+
+	for metric, otherStats := range other.stats {
+		otherCounts := other.stateChangeCounters[metric]
+		otherStateStats := other.stateStats[metric]
+		otherDwellTimes := other.dwellTimes[metric]
+		stats, exists := counter.stats[metric]
+		if !exists {
+			counts := make(map[string][]int64)
+			for key, otherRow := range otherCounts {
+				counts[key] = append([]int64{}, otherRow...)
+			}
+			counter.stats[metric] = otherStats
+			counter.stateChangeCounters[metric] = counts
+			counter.stateStats[metric] = append([]models.TSStats{}, otherStateStats...)
+			if counter.dwell {
+				dwellTimes := make([][]int64, len(otherDwellTimes))
+				for state, histogram := range otherDwellTimes {
+					dwellTimes[state] = append([]int64{}, histogram...)
+				}
+				counter.dwellTimes[metric] = dwellTimes
+			}
+			if _, exists := counter.currentState[metric]; !exists {
+				counter.currentState[metric] = append([]models.State{}, other.currentState[metric]...)
+			}
+			continue
+		}
+
+		// move both to the common min and max
+		common := utils.MergeStats(stats, otherStats)
+		counts := counter.stateChangeCounters[metric]
+		stateStats := counter.stateStats[metric]
+		dwellTimes := counter.dwellTimes[metric]
+		if common.Min < stats.Min || common.Max > stats.Max {
+			counts = utils.ChangeDimension(counts, stats, common, counter.states)
+			stateStats = utils.ChangeStateStatsDimension(stateStats, stats, common, counter.states)
+			dwellTimes = utils.ChangeDwellDimension(dwellTimes, stats, common, counter.states)
+		}
+		if common.Min < otherStats.Min || common.Max > otherStats.Max {
+			otherCounts = utils.ChangeDimension(otherCounts, otherStats, common, counter.states)
+			otherStateStats = utils.ChangeStateStatsDimension(otherStateStats, otherStats, common, counter.states)
+			otherDwellTimes = utils.ChangeDwellDimension(otherDwellTimes, otherStats, common, counter.states)
+		}
+		if counts == nil {
+			counts = make(map[string][]int64)
+		}
+		for key, otherRow := range otherCounts {
+			row, exists := counts[key]
+			if !exists {
+				row = make([]int64, counter.states)
+			}
+			for state, n := range otherRow {
+				if state < len(row) {
+					row[state] += n
+				}
+			}
+			counts[key] = row
+		}
+		for len(stateStats) < len(otherStateStats) {
+			stateStats = append(stateStats, models.TSStats{})
+		}
+		for state, otherStateStat := range otherStateStats {
+			stateStats[state] = utils.MergeStats(stateStats[state], otherStateStat)
+		}
+		for len(dwellTimes) < len(otherDwellTimes) {
+			dwellTimes = append(dwellTimes, nil)
+		}
+		for state, histogram := range otherDwellTimes {
+			dwellTimes[state] = utils.MergeHistograms(dwellTimes[state], histogram)
+		}
+		counter.stateChangeCounters[metric] = counts
+		counter.stateStats[metric] = stateStats
+		if counter.dwell {
+			counter.dwellTimes[metric] = dwellTimes
+		}
+		counter.stats[metric] = common
+	}
+}
+
+// RemapStates maps the states of `metric` to mapping[state] and sums up the
+// counts of states mapped to the same state, for counters of ids (e.g. the
+// phase ids) with the stats' range [0, states]. A nil mapping keeps the
+// states, e.g. to resize the counter to a new amount of states.
+func (counter *Counter) RemapStates(metric string, mapping []int, states int) {
+	counter.access.Lock()
+	defer counter.access.Unlock()
+	counter.contextTrees = make(map[string]*utils.ContextTree)
+
+	remap := func(state int64) int64 {
+		if state < 0 || state >= int64(len(mapping)) {
+			return state
+		}
+		return int64(mapping[state])
+	}
+	counts := make(map[string][]int64)
+	for key, row := range counter.stateChangeCounters[metric] {
+		keyParts := strings.Split(key, "-")
+		for i, keyPart := range keyParts {
+			state, err := strconv.ParseInt(keyPart, 10, 64)
+			if err != nil {
+				continue
+			}
+			keyParts[i] = strconv.FormatInt(remap(state), 10)
+		}
+		newKey := strings.Join(keyParts, "-")
+		newRow, exists := counts[newKey]
+		if !exists {
+			newRow = make([]int64, states)
+		}
+		for state, n := range row {
+			if newState := remap(int64(state)); newState >= 0 && newState < int64(states) {
+				newRow[newState] += n
+			}
+		}
+		counts[newKey] = newRow
+	}
+	counter.stateChangeCounters[metric] = counts
+
+	stateStats := make([]models.TSStats, states)
+	for state, stats := range counter.stateStats[metric] {
+		if newState := remap(int64(state)); newState >= 0 && newState < int64(states) {
+			stateStats[newState] = utils.MergeStats(stateStats[newState], stats)
+		}
+	}
+	counter.stateStats[metric] = stateStats
+
+	if counter.dwell {
+		dwellTimes := make([][]int64, states)
+		for state, histogram := range counter.dwellTimes[metric] {
+			if newState := remap(int64(state)); newState >= 0 && newState < int64(states) {
+				dwellTimes[newState] = utils.MergeHistograms(dwellTimes[newState], histogram)
+			}
+		}
+		counter.dwellTimes[metric] = dwellTimes
+	}
+
+	for i, state := range counter.currentState[metric] {
+		counter.currentState[metric][i].Value = remap(state.Value)
+	}
+	if stats, exists := counter.stats[metric]; exists {
+		stats.Max = float64(states)
+		counter.stats[metric] = stats
+	}
+	counter.states = states
 }
@@ -0,0 +1,63 @@
+package counter
+
+import (
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// tsstate returns the discretized state of "metric" of 2 states
+func tsstate(state int64) []models.TSState {
+	return []models.TSState{{
+		Metric:     "metric",
+		State:      models.State{Value: state},
+		Value:      float64(state),
+		Statistics: models.TSStats{Min: 0, Max: 2, Count: 1},
+	}}
+}
+
+// countStates returns a counter of history 2 which counted `states`
+func countStates(states []int64, minCount int64, minDivergence float32) Counter {
+	counter := NewCounter(2, 2, 1, nil)
+	counter.SetContextPruning(minCount, minDivergence)
+	for _, state := range states {
+		counter.Count(tsstate(state))
+	}
+	return counter
+}
+
+func TestContextPruning(t *testing.T) {
+	// after 0-0 always 1, after 1-0 always 0, 1-1 counted once at the end
+	states := []int64{1, 0, 0, 1, 0, 0, 1, 0, 0, 1, 1, 1}
+
+	Convey("Should prune the histories by default", t, func() {
+		counter := countStates(states, 0, 0)
+		transitions := counter.GetTx()[0].Transitions
+		So(transitions, ShouldContainKey, "0-0")
+		So(transitions, ShouldContainKey, "1-0")
+		So(transitions, ShouldNotContainKey, "1-1")
+	})
+
+	Convey("Should keep all histories unsmoothed with a negative min count", t, func() {
+		counter := countStates(states, -1, 0)
+		transitions := counter.GetTx()[0].Transitions
+		So(transitions, ShouldHaveLength, 6)
+		So(transitions["0-0"].NextStateProbs, ShouldResemble, []int{0, 100})
+		So(transitions["0-1"].NextStateProbs, ShouldResemble, []int{75, 25})
+		So(transitions["1-1"].NextStateProbs, ShouldResemble, []int{0, 100})
+	})
+
+	Convey("Should compute the likeliness on the same model as the tx matrices", t, func() {
+		for minCount, history := range map[int64]string{0: "1", -1: "1-1"} {
+			// current history 1-1: pruned by default, i.e. cut to 1
+			counter := countStates(states, minCount, 0)
+			txStep := counter.GetTx()[0].Transitions[history]
+			for next := int64(0); next < 2; next++ {
+				likeliness := float64(counter.Likeliness(tsstate(next)))
+				So(likeliness, ShouldAlmostEqual, float64(txStep.NextStateProbs[next])/100, 0.005)
+				So(counter.MetricLikeliness(tsstate(next))["metric"], ShouldAlmostEqual, likeliness, 1e-6)
+			}
+		}
+	})
+}
@@ -60,6 +60,7 @@ func (discretizer *Discretizer) Discretize(buffers []models.TSBuffer) []models.T
 			Metric:     buffer.Metric,
 			State:      state,
 			Statistics: stats,
+			Value:      stats.Avg,
 		}
 	}
 	return states
@@ -40,6 +40,16 @@ func NewPeriod(history int, states int, buffersize int, periodSize []int, profil
 	return period
 }
 
+// SetContextPruning sets the pruning of the history transitions of the period
+// counters, see counter.SetContextPruning
+func (period *Period) SetContextPruning(minCount int64, minDivergence float32) {
+	period.access.Lock()
+	defer period.access.Unlock()
+	for i := range period.periodCounters {
+		period.periodCounters[i].SetContextPruning(minCount, minDivergence)
+	}
+}
+
 // Period holds counters etc to compute probabilities for given period size
 type Period struct {
 	// upper level profiler
@@ -51,6 +61,7 @@ type Period struct {
 
 	txTree         models.PeriodTree
 	txTreePosition []int
+	boundaryLevel  int
 
 	access *sync.Mutex
 
@@ -62,22 +73,34 @@ type Period struct {
 }
 
 // Count takes a discretized Buffer represented as TSStates for each
-// metric and increases the counter
-func (period *Period) Count(tsstates []models.TSState) {
+// metric and increases the counter. It returns the period boundary passed,
+// nil if the period path was kept.
+func (period *Period) Count(tsstates []models.TSState) *models.PeriodBoundary {
 	period.access.Lock()
 	defer period.access.Unlock()
 
 	// period tree counting
-	period.countPeriodTree(tsstates)
+	return period.countPeriodTree(tsstates)
 }
 
-func (period *Period) countPeriodTree(tsstates []models.TSState) {
+func (period *Period) countPeriodTree(tsstates []models.TSState) *models.PeriodBoundary {
 	if len(period.periodSize) > 0 {
 		// only count period when configured
 		//fmt.Printf("txTreePos: %+v\n", period.txTreePosition)
-		period.countPeriodTreeNode(tsstates, 0)
+		period.boundaryLevel = -1
+		if period.countPeriodTreeNode(tsstates, 0) {
+			// the whole period rotated
+			period.boundaryLevel = 0
+		}
 		//period.countPeriodTreeNode(tsstates)
+		if period.boundaryLevel >= 0 {
+			return &models.PeriodBoundary{
+				Level: period.boundaryLevel,
+				Path:  append([]int{}, period.txTreePosition...),
+			}
+		}
 	}
+	return nil
 }
 
 func (period *Period) countPeriodTreeNode(tsstates []models.TSState, level int) bool {
@@ -96,6 +119,7 @@ func (period *Period) countPeriodTreeNode(tsstates []models.TSState, level int)
 		if stepForward {
 			// child level moved on
 			period.txTreePosition[level]++
+			period.boundaryLevel = level
 			//period.periodSizeCounter[level]++
 
 			if period.txTreePosition[level] >= period.periodSize[level] {
@@ -343,12 +367,33 @@ func (period *Period) countPeriodTreeNodeOld(tsstates []models.TSState, level in
 }
 */
 
-// GetTx returns for each period the counters' TSProfileMetric matrix
+// GetTx returns for each period the counters' TSProfileMetric matrix, as a
+// copy of the counted tree
 func (period *Period) GetTx() models.PeriodTree {
-	return period.txTree
+	period.access.Lock()
+	defer period.access.Unlock()
+	return period.txTree.Copy()
 }
 
-// GetCurrentPeriodPath returns the current tree positions
+// GetCurrentPeriodPath returns a copy of the current tree positions
 func (period *Period) GetCurrentPeriodPath() []int {
-	return period.txTreePosition
+	period.access.Lock()
+	defer period.access.Unlock()
+	return append([]int{}, period.txTreePosition...)
+}
+
+// MetricLikeliness returns per metric the probability [0,1] of the state
+// change to tsstates according to the deepest counted node of the current
+// period path, i.e. the node tsstates will be counted in
+func (period *Period) MetricLikeliness(tsstates []models.TSState) map[string]float32 {
+	period.access.Lock()
+	defer period.access.Unlock()
+
+	// leaf level nodes are not counted
+	level := len(period.periodSize) - 2
+	if level < 0 {
+		return make(map[string]float32)
+	}
+	node := period.txTree.GetNode(period.txTreePosition[:level+1])
+	return period.periodCounters[level].TxLikeliness(node.TxMatrix, tsstates)
 }
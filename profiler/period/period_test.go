@@ -0,0 +1,82 @@
+package period
+
+import (
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// tsstate returns the discretized state of "metric" of 2 states
+func tsstate(state int64) []models.TSState {
+	return []models.TSState{{
+		Metric:     "metric",
+		State:      models.State{Value: state},
+		Value:      float64(state),
+		Statistics: models.TSStats{Min: 0, Max: 1, Count: 1},
+	}}
+}
+
+func TestPeriodBoundary(t *testing.T) {
+	Convey("Should emit the boundaries at the level which moved on", t, func() {
+		period := NewPeriod(1, 2, 1, []int{2, 3, 4}, nil)
+		boundaries := make(map[int]models.PeriodBoundary)
+		for step := 1; step <= 2*24; step++ {
+			if boundary := period.Count(tsstate(int64(step % 2))); boundary != nil {
+				boundaries[step] = *boundary
+			}
+		}
+		// the leaves hold 4 steps, the second level moves on every 4 steps
+		// and rolls over every 12 steps, the whole period wraps after 24 steps
+		So(boundaries, ShouldHaveLength, 2*24/4)
+		So(boundaries[4], ShouldResemble, models.PeriodBoundary{Level: 1, Path: []int{0, 1, 0}})
+		So(boundaries[8], ShouldResemble, models.PeriodBoundary{Level: 1, Path: []int{0, 2, 0}})
+		So(boundaries[12], ShouldResemble, models.PeriodBoundary{Level: 0, Path: []int{1, 0, 0}})
+		So(boundaries[16], ShouldResemble, models.PeriodBoundary{Level: 1, Path: []int{1, 1, 0}})
+		So(boundaries[24], ShouldResemble, models.PeriodBoundary{Level: 0, Path: []int{0, 0, 0}})
+		So(boundaries[36], ShouldResemble, models.PeriodBoundary{Level: 0, Path: []int{1, 0, 0}})
+		So(boundaries[48], ShouldResemble, models.PeriodBoundary{Level: 0, Path: []int{0, 0, 0}})
+		So(period.GetCurrentPeriodPath(), ShouldResemble, []int{0, 0, 0})
+	})
+
+	Convey("Should not emit boundaries without period size", t, func() {
+		period := NewPeriod(1, 2, 1, []int{}, nil)
+		for step := 0; step < 10; step++ {
+			So(period.Count(tsstate(0)), ShouldBeNil)
+		}
+	})
+}
+
+func TestMetricLikeliness(t *testing.T) {
+	Convey("Should compute the likeliness in the node of the current period path", t, func() {
+		period := NewPeriod(1, 2, 1, []int{2, 2, 4}, nil)
+		for step := 0; step < 12; step++ {
+			period.Count(tsstate(0))
+		}
+		So(period.GetCurrentPeriodPath(), ShouldResemble, []int{1, 1, 0})
+		// only the node [1 1] stays in state 0
+		txMatrix := func(probs []int) []models.TxMatrix {
+			return []models.TxMatrix{{
+				Metric: "metric",
+				Transitions: map[string]models.TXStep{
+					"0": {NextStateProbs: probs, StepProb: 100},
+				},
+			}}
+		}
+		for _, path := range [][]int{{0}, {1}, {0, 1}, {1, 0}} {
+			period.txTree.GetNode(path).TxMatrix = txMatrix([]int{0, 100})
+		}
+		period.txTree.GetNode([]int{1, 1}).TxMatrix = txMatrix([]int{100, 0})
+
+		likeliness := period.MetricLikeliness(tsstate(0))
+		So(likeliness["metric"], ShouldAlmostEqual, 1)
+		likeliness = period.MetricLikeliness(tsstate(1))
+		So(likeliness["metric"], ShouldAlmostEqual, 0)
+	})
+
+	Convey("Should not compute the likeliness without counted period levels", t, func() {
+		period := NewPeriod(1, 2, 1, []int{4}, nil)
+		period.Count(tsstate(0))
+		So(period.MetricLikeliness(tsstate(0)), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,267 @@
+package phase
+
+import (
+	"math"
+)
+
+// Detector detects phase changes from the likeliness of the incoming states
+// under the current phase
+type Detector interface {
+	// Detect takes the likeliness [0,1] of the incoming states under the
+	// current phase and returns whether the phase changed, together with the
+	// likeliness of the current phase the decision was based on
+	Detect(likeliness float32) (bool, float32)
+
+	// Matches returns whether an existing phase, explaining the recent states
+	// with the given likeliness [0,1], is a candidate to change to. It is
+	// called after a detected change, before Reset.
+	Matches(likeliness float32) bool
+
+	// Reset is called after the current phase was changed
+	Reset()
+}
+
+// NewLikelinessDetector returns a Detector which detects a phase change when
+// the average likeliness over the last `history` states falls below
+// `threshold`, weighted linearly towards the most recent states if `fadeout`
+func NewLikelinessDetector(threshold float32, history int64, fadeout bool) Detector {
+	return &likelinessDetector{
+		threshold:         threshold,
+		history:           history,
+		fadeout:           fadeout,
+		historyLikeliness: make([]float32, 0),
+	}
+}
+
+type likelinessDetector struct {
+	threshold         float32
+	history           int64
+	fadeout           bool
+	historyLikeliness []float32
+}
+
+func (detector *likelinessDetector) Detect(currentLikeliness float32) (bool, float32) {
+	// update likeliness history
+	detector.historyLikeliness = append(detector.historyLikeliness, currentLikeliness)
+	if int64(len(detector.historyLikeliness)) > detector.history {
+		// remove first (oldest) item
+		detector.historyLikeliness = detector.historyLikeliness[1:]
+	}
+
+	// calculate historyLikeliness
+	historyLikelinessSum := float32(0)
+	countSum := 0
+	for i, likeliness := range detector.historyLikeliness {
+		if detector.fadeout {
+			historyLikelinessSum += likeliness * float32(i+1)
+			countSum += (i + 1)
+		} else {
+			historyLikelinessSum += likeliness
+		}
+	}
+	historyLikeliness := float32(0)
+	if detector.fadeout {
+		historyLikeliness = historyLikelinessSum / float32(countSum)
+	} else {
+		historyLikeliness = historyLikelinessSum / float32(len(detector.historyLikeliness))
+	}
+	return historyLikeliness < detector.threshold, historyLikeliness
+}
+
+func (detector *likelinessDetector) Matches(likeliness float32) bool {
+	return likeliness > detector.threshold
+}
+
+func (detector *likelinessDetector) Reset() {
+	// the likeliness history is kept over phase changes
+}
+
+const (
+	defaultCusumDrift     = 0.05
+	defaultCusumThreshold = 2
+	defaultHazard         = 0.01
+)
+
+// NewCusumDetector returns a Detector which detects a phase change when the
+// cumulative sum of the likeliness' shortfalls below its mean in the current
+// phase, each reduced by `drift`, exceeds `threshold` (a one-sided CUSUM).
+// Zero values select the defaults drift 0.05 and threshold 2.
+func NewCusumDetector(drift float32, threshold float32) Detector {
+	if drift == 0 {
+		drift = defaultCusumDrift
+	}
+	if threshold == 0 {
+		threshold = defaultCusumThreshold
+	}
+	return &cusumDetector{
+		drift:     float64(drift),
+		threshold: float64(threshold),
+	}
+}
+
+type cusumDetector struct {
+	drift     float64
+	threshold float64
+
+	// state of the current phase
+	mean  float64
+	count int64
+	sum   float64
+}
+
+func (detector *cusumDetector) Detect(likeliness float32) (bool, float32) {
+	x := float64(likeliness)
+	if detector.count > 0 {
+		detector.sum = math.Max(0, detector.sum+detector.mean-x-detector.drift)
+	}
+	if detector.sum > detector.threshold {
+		return true, likeliness
+	}
+	// the mean of the likeliness in the current phase, excluding shortfalls
+	detector.count++
+	detector.mean += (x - detector.mean) / float64(detector.count)
+	return false, likeliness
+}
+
+// Matches accepts phases explaining the recent states at least as well as the
+// current phase explained its states before the change, less the drift
+func (detector *cusumDetector) Matches(likeliness float32) bool {
+	if detector.count == 0 {
+		return true
+	}
+	return float64(likeliness) >= detector.mean-detector.drift
+}
+
+func (detector *cusumDetector) Reset() {
+	detector.mean = 0
+	detector.count = 0
+	detector.sum = 0
+}
+
+// priors of the normal-inverse-gamma distributed likeliness in a phase
+const (
+	bayesianPriorMean  = 0.5
+	bayesianPriorKappa = 1
+	bayesianPriorAlpha = 1
+	bayesianPriorBeta  = 0.01
+
+	// run lengths less probable are dropped
+	bayesianMinRunProbability = 1e-8
+)
+
+// NewBayesianDetector returns a Detector which detects a phase change by
+// Bayesian online change point detection (Adams and MacKay 2007) on the
+// likeliness, modeled as normal distributed with unknown mean and variance
+// within a phase. `hazard` is the prior probability of a phase change per
+// state. A phase change is detected when the most probable run length, i.e.
+// the amount of states since the last change, decreases. A zero hazard
+// selects the default 0.01.
+func NewBayesianDetector(hazard float32) Detector {
+	if hazard == 0 {
+		hazard = defaultHazard
+	}
+	detector := &bayesianDetector{
+		hazard: float64(hazard),
+	}
+	detector.Reset()
+	return detector
+}
+
+type bayesianDetector struct {
+	hazard float64
+
+	// per run length the probability and the posterior parameters
+	runProbs []float64
+	mean     []float64
+	kappa    []float64
+	alpha    []float64
+	beta     []float64
+	mapRun   int
+
+	// mean and stddev of the likeliness in the most probable run before the
+	// last state, i.e. in the current phase before a change
+	matchMean   float64
+	matchStddev float64
+}
+
+func (detector *bayesianDetector) Detect(likeliness float32) (bool, float32) {
+	x := float64(likeliness)
+	detector.matchMean = detector.mean[detector.mapRun]
+	detector.matchStddev = math.Sqrt(detector.beta[detector.mapRun] / detector.alpha[detector.mapRun])
+
+	// grow the runs resp. start a new run (run length 0)
+	runProbs := make([]float64, 1, len(detector.runProbs)+1)
+	evidence := float64(0)
+	for run, runProb := range detector.runProbs {
+		prob := runProb * studentT(x, detector.mean[run], detector.kappa[run], detector.alpha[run], detector.beta[run])
+		runProbs[0] += prob * detector.hazard
+		runProbs = append(runProbs, prob*(1-detector.hazard))
+		evidence += prob
+	}
+	if evidence <= 0 || math.IsNaN(evidence) {
+		// likeliness impossible in all runs
+		detector.Reset()
+		return true, likeliness
+	}
+
+	// update the posterior parameters with x
+	mean := []float64{bayesianPriorMean}
+	kappa := []float64{bayesianPriorKappa}
+	alpha := []float64{bayesianPriorAlpha}
+	beta := []float64{bayesianPriorBeta}
+	for run := range detector.runProbs {
+		kappaRun := detector.kappa[run]
+		mean = append(mean, (kappaRun*detector.mean[run]+x)/(kappaRun+1))
+		kappa = append(kappa, kappaRun+1)
+		alpha = append(alpha, detector.alpha[run]+0.5)
+		beta = append(beta, detector.beta[run]+kappaRun*(x-detector.mean[run])*(x-detector.mean[run])/(2*(kappaRun+1)))
+	}
+
+	// normalize, drop improbable long runs and find the most probable run length
+	mapRun := 0
+	length := 0
+	for run := range runProbs {
+		runProbs[run] /= evidence
+		if runProbs[run] >= bayesianMinRunProbability {
+			length = run + 1
+		}
+		if runProbs[run] > runProbs[mapRun] {
+			mapRun = run
+		}
+	}
+	detector.runProbs = runProbs[:length]
+	detector.mean = mean[:length]
+	detector.kappa = kappa[:length]
+	detector.alpha = alpha[:length]
+	detector.beta = beta[:length]
+
+	changed := mapRun < detector.mapRun
+	detector.mapRun = mapRun
+	return changed, likeliness
+}
+
+// Matches accepts phases explaining the recent states within one stddev of the
+// likeliness in the current phase before the change
+func (detector *bayesianDetector) Matches(likeliness float32) bool {
+	return float64(likeliness) >= detector.matchMean-detector.matchStddev
+}
+
+func (detector *bayesianDetector) Reset() {
+	detector.runProbs = []float64{1}
+	detector.mean = []float64{bayesianPriorMean}
+	detector.kappa = []float64{bayesianPriorKappa}
+	detector.alpha = []float64{bayesianPriorAlpha}
+	detector.beta = []float64{bayesianPriorBeta}
+	detector.mapRun = 0
+}
+
+// studentT returns the posterior predictive density of x, a Student's t
+// distribution, for the normal-inverse-gamma parameters
+func studentT(x float64, mean float64, kappa float64, alpha float64, beta float64) float64 {
+	nu := 2 * alpha
+	scale2 := beta * (kappa + 1) / (alpha * kappa)
+	lgammaHigh, _ := math.Lgamma((nu + 1) / 2)
+	lgammaLow, _ := math.Lgamma(nu / 2)
+	z := (x - mean) * (x - mean) / (nu * scale2)
+	return math.Exp(lgammaHigh - lgammaLow - 0.5*math.Log(nu*math.Pi*scale2) - (nu+1)/2*math.Log1p(z))
+}
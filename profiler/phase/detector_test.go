@@ -0,0 +1,89 @@
+package phase
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// detectChanges feeds the likeliness to the detector and returns the indexes
+// of the detected changes, resetting the detector after each like Phase.Count
+func detectChanges(detector Detector, likeliness []float32) []int {
+	changes := make([]int, 0)
+	for i, l := range likeliness {
+		if changed, _ := detector.Detect(l); changed {
+			changes = append(changes, i)
+			detector.Reset()
+		}
+	}
+	return changes
+}
+
+// stableThenDrop returns `stable` alternating likeliness around 0.9 followed
+// by `dropped` times 0.2
+func stableThenDrop(stable int, dropped int) []float32 {
+	likeliness := make([]float32, 0, stable+dropped)
+	for i := 0; i < stable; i++ {
+		likeliness = append(likeliness, 0.88+float32(i%2)*0.04)
+	}
+	for i := 0; i < dropped; i++ {
+		likeliness = append(likeliness, 0.2)
+	}
+	return likeliness
+}
+
+func TestCusumDetector(t *testing.T) {
+	Convey("Should tolerate fluctuations within the drift", t, func() {
+		So(detectChanges(NewCusumDetector(0, 0), stableThenDrop(100, 0)), ShouldBeEmpty)
+	})
+
+	Convey("Should detect a drop once the cumulative shortfall exceeds the threshold", t, func() {
+		detector := NewCusumDetector(0, 0)
+		So(detectChanges(detector, stableThenDrop(20, 3)), ShouldBeEmpty)
+		changed, likeliness := detector.Detect(0.2)
+		So(changed, ShouldBeTrue)
+		So(likeliness, ShouldEqual, 0.2)
+
+		// matches phases explaining the states like the current one did before
+		So(detector.Matches(0.8), ShouldBeTrue)
+		So(detector.Matches(0.5), ShouldBeFalse)
+
+		detector.Reset()
+		So(detector.Matches(0.1), ShouldBeTrue)
+		changed, _ = detector.Detect(0.2)
+		So(changed, ShouldBeFalse)
+	})
+
+	Convey("Should detect a drop sooner with a lower threshold", t, func() {
+		So(detectChanges(NewCusumDetector(0, 1), stableThenDrop(20, 4)), ShouldResemble, []int{21})
+	})
+}
+
+func TestBayesianDetector(t *testing.T) {
+	Convey("Should not detect changes in a stable likeliness", t, func() {
+		So(detectChanges(NewBayesianDetector(0), stableThenDrop(100, 0)), ShouldBeEmpty)
+	})
+
+	Convey("Should detect a drop of the likeliness", t, func() {
+		detector := NewBayesianDetector(0)
+		So(detectChanges(detector, stableThenDrop(50, 0)), ShouldBeEmpty)
+		changed := false
+		drops := 0
+		for !changed && drops < 5 {
+			changed, _ = detector.Detect(0.2)
+			drops++
+		}
+		So(changed, ShouldBeTrue)
+
+		// matches phases explaining the states like the current one did before
+		So(detector.Matches(0.9), ShouldBeTrue)
+		So(detector.Matches(0.2), ShouldBeFalse)
+	})
+
+	Convey("Should start a new run after a reset", t, func() {
+		detector := NewBayesianDetector(0)
+		detectChanges(detector, stableThenDrop(50, 10))
+		detector.Reset()
+		So(detectChanges(detector, stableThenDrop(0, 50)), ShouldBeEmpty)
+	})
+}
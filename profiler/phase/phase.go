@@ -7,28 +7,33 @@ import (
 	"github.com/cha87de/tsprofiler/api"
 	"github.com/cha87de/tsprofiler/models"
 	"github.com/cha87de/tsprofiler/profiler/counter"
+	"github.com/cha87de/tsprofiler/utils"
 )
 
-// NewPhase instantiates and returns a new Phase with the provided parameters
+// NewPhase instantiates and returns a new Phase with the provided parameters.
+// After a phase change, existing phases are matched against the last
+// `phaseHistory` states, at least two.
 func NewPhase(history int, states int, buffersize int, phaseLikeliness float32, phaseHistory int64, phaseHistoryFadeout bool, profiler api.TSProfiler) Phase {
 	phase := Phase{
 		profiler: profiler,
 
-		phaseCounters:                  make([]counter.Counter, 1),
-		phasePointer:                   0,
-		phaseTxCounter:                 counter.NewCounter(1, 1, 1, profiler),
-		phaseTSStatesHistory:           make([][]models.TSState, 0),
-		phaseTSStatesHistoryLikeliness: make([]float32, 0),
-		phaseTSStatesHistoryFadeout:    phaseHistoryFadeout,
+		phaseCounters:        make([]counter.Counter, 1),
+		phaseMeta:            make([]models.PhaseMeta, 1),
+		phasePointer:         0,
+		phaseTxCounter:       counter.NewCounter(1, 1, 1, profiler),
+		phaseTSStatesHistory: make([][]models.TSState, 0),
+		detector:             NewLikelinessDetector(phaseLikeliness, phaseHistory, phaseHistoryFadeout),
 
 		access: &sync.Mutex{},
 
 		// config
-		history:                  history,
-		states:                   states,
-		buffersize:               buffersize,
-		phaseThresholdLikeliness: phaseLikeliness,
-		phaseThresholdHistory:    phaseHistory,
+		history:      history,
+		states:       states,
+		buffersize:   buffersize,
+		matchHistory: phaseHistory,
+	}
+	if phase.matchHistory < minMatchHistory {
+		phase.matchHistory = minMatchHistory
 	}
 	// create the first phase counter
 	phase.phaseCounters[0] = counter.NewCounter(phase.history, phase.states, phase.buffersize, phase.profiler)
@@ -36,66 +41,111 @@ func NewPhase(history int, states int, buffersize int, phaseLikeliness float32,
 	return phase
 }
 
+// minMatchHistory is the min. amount of recent states existing phases are
+// matched against after a phase change, i.e. at least one transition
+const minMatchHistory = 2
+
+// SetConsolidation configures the consolidation of the detected phases, when a
+// new phase was created and every `interval` counted buffers (0 to disable):
+// phases counted less than `minCount` buffers are pruned, phases diverging less
+// than `mergeDivergence` are merged, and the most similar phases are merged
+// while there are more than `maxPhases` phases (0 to disable each)
+func (phase *Phase) SetConsolidation(maxPhases int, mergeDivergence float32, minCount int64, interval int64) {
+	phase.access.Lock()
+	defer phase.access.Unlock()
+	phase.maxPhases = maxPhases
+	phase.mergeDivergence = float64(mergeDivergence)
+	phase.minCount = minCount
+	phase.consolidationInterval = interval
+}
+
+// SetDwellTimes enables or disables recording the dwell time histograms of
+// the states in each phase and of the phases in the phase tx
+func (phase *Phase) SetDwellTimes(dwellTimes bool) {
+	phase.access.Lock()
+	defer phase.access.Unlock()
+	phase.dwellTimes = dwellTimes
+	for i := range phase.phaseCounters {
+		phase.phaseCounters[i].SetDwellTimes(dwellTimes)
+	}
+	phase.phaseTxCounter.SetDwellTimes(dwellTimes)
+}
+
+// SetContextPruning sets the pruning of the history transitions of the phase
+// counters, see counter.SetContextPruning
+func (phase *Phase) SetContextPruning(minCount int64, minDivergence float32) {
+	phase.access.Lock()
+	defer phase.access.Unlock()
+	phase.contextMinCount = minCount
+	phase.contextMinDivergence = minDivergence
+	for i := range phase.phaseCounters {
+		phase.phaseCounters[i].SetContextPruning(minCount, minDivergence)
+	}
+}
+
+// SetDetector replaces the detector of phase changes, by default a likeliness
+// detector with the phase likeliness and history of NewPhase
+func (phase *Phase) SetDetector(detector Detector) {
+	phase.access.Lock()
+	defer phase.access.Unlock()
+	phase.detector = detector
+}
+
 // Phase handles the phase detection and state counting of the profiler
 type Phase struct {
 	// upper level profiler
 	profiler api.TSProfiler
 
 	// state
-	phaseCounters                  []counter.Counter
-	phasePointer                   int
-	phaseTxCounter                 counter.Counter
-	phaseTSStatesHistory           [][]models.TSState
-	phaseTSStatesHistoryLikeliness []float32
-	phaseTSStatesHistoryFadeout    bool
+	phaseCounters        []counter.Counter
+	phaseMeta            []models.PhaseMeta
+	phasePointer         int
+	phaseTxCounter       counter.Counter
+	phaseTSStatesHistory [][]models.TSState
+	detector             Detector
 
 	access *sync.Mutex
 
 	// configs
-	history                  int
-	states                   int
-	buffersize               int
-	phaseThresholdLikeliness float32
-	phaseThresholdHistory    int64
+	history      int
+	states       int
+	buffersize   int
+	matchHistory int64
+
+	// consolidation configs
+	maxPhases             int
+	mergeDivergence       float64
+	minCount              int64
+	consolidationInterval int64
+	steps                 int64
+
+	dwellTimes bool
+
+	contextMinCount      int64
+	contextMinDivergence float32
 }
 
 // Count takes a discretized Buffer represented as TSStates for each metric,
-// adjusts the current phase and increases its counter
-func (phase *Phase) Count(tsstates []models.TSState) {
+// adjusts the current phase and increases its counter. It returns the phase
+// change, nil if the phase was kept.
+func (phase *Phase) Count(tsstates []models.TSState) *models.PhaseChanged {
 	phase.access.Lock()
 	defer phase.access.Unlock()
 
-	// update likeliness history
+	// detect phase change from the likeliness of the current phase
 	currentLikeliness := phase.phaseCounters[phase.phasePointer].Likeliness(tsstates)
 	if math.IsNaN(float64(currentLikeliness)) {
 		currentLikeliness = 1
 	}
-	phase.phaseTSStatesHistoryLikeliness = append(phase.phaseTSStatesHistoryLikeliness, currentLikeliness)
-	if int64(len(phase.phaseTSStatesHistoryLikeliness)) > phase.phaseThresholdHistory {
-		// remove first (oldest) item
-		phase.phaseTSStatesHistoryLikeliness = phase.phaseTSStatesHistoryLikeliness[1:]
-	}
+	changed, historyLikeliness := phase.detector.Detect(currentLikeliness)
 
-	// calculate historyLikeliness
-	historyLikelinessSum := float32(0)
-	countSum := 0
-	for i, likeliness := range phase.phaseTSStatesHistoryLikeliness {
-		if phase.phaseTSStatesHistoryFadeout {
-			historyLikelinessSum += likeliness * float32(i+1)
-			countSum += (i + 1)
-		} else {
-			historyLikelinessSum += likeliness
+	var phaseChanged *models.PhaseChanged
+	if changed {
+		phaseChanged = &models.PhaseChanged{
+			From:       phase.phasePointer,
+			Likeliness: historyLikeliness,
 		}
-	}
-	historyLikeliness := float32(0)
-	if phase.phaseTSStatesHistoryFadeout {
-		historyLikeliness = historyLikelinessSum / float32(countSum)
-	} else {
-		historyLikeliness = historyLikelinessSum / float32(len(phase.phaseTSStatesHistoryLikeliness))
-	}
-	//historyLikeliness := currentLikeliness
 
-	if historyLikeliness < phase.phaseThresholdLikeliness {
 		// if likeliness is below threshold, look for better matching phase!
 
 		//fmt.Printf("likeliness: %.2f, counts: %d\n", likeliness, counts)
@@ -108,6 +158,10 @@ func (phase *Phase) Count(tsstates []models.TSState) {
 				// skip current phase
 				continue
 			}
+			if len(phase.phaseTSStatesHistory) < minMatchHistory {
+				// no transition to match yet
+				break
+			}
 			txMatrices := phaseCounter.GetTx()
 			history := phase.phaseTSStatesHistory[:len(phase.phaseTSStatesHistory)-1]
 
@@ -131,7 +185,7 @@ func (phase *Phase) Count(tsstates []models.TSState) {
 			phaseLikeliness = lSum / float32(len(history))
 
 			//fmt.Printf("phase %d likeliness: %.2f\n", i, phaseLikeliness)
-			if historyLikeliness < phaseLikeliness && phaseLikeliness > phase.phaseThresholdLikeliness {
+			if historyLikeliness < phaseLikeliness && phase.detector.Matches(phaseLikeliness) {
 				newPhasePointer = i
 				historyLikeliness = phaseLikeliness
 			}
@@ -142,15 +196,30 @@ func (phase *Phase) Count(tsstates []models.TSState) {
 			phase.phasePointer = newPhasePointer
 		} else {
 			// create a new phase
-			phaseid := len(phase.phaseCounters) - 1
+			phaseid := len(phase.phaseCounters)
 			//fmt.Printf("create new phase %d\n", phaseid)
-			phase.phaseCounters = append(phase.phaseCounters, counter.NewCounter(phase.history, phase.states, phase.buffersize, phase.profiler))
+			phaseCounter := counter.NewCounter(phase.history, phase.states, phase.buffersize, phase.profiler)
+			phaseCounter.SetDwellTimes(phase.dwellTimes)
+			phaseCounter.SetContextPruning(phase.contextMinCount, phase.contextMinDivergence)
+			phase.phaseCounters = append(phase.phaseCounters, phaseCounter)
+			phase.phaseMeta = append(phase.phaseMeta, models.PhaseMeta{})
 			phase.phasePointer = phaseid // point to the newly added
+			phaseChanged.IsNew = true
+
+			// add the new phase id to the phase tx, keeping the ids of the
+			// others (growing its stats' max would rescale them)
+			phase.phaseTxCounter.RemapStates("phasetx", nil, len(phase.phaseCounters))
+		}
+		phaseChanged.To = phase.phasePointer
+		phase.detector.Reset()
+		if !phaseChanged.IsNew && phaseChanged.To == phaseChanged.From {
+			phaseChanged = nil
 		}
 	}
 
 	// increase counter on current phase
 	phase.phaseCounters[phase.phasePointer].Count(tsstates)
+	phase.updateMeta(tsstates, phase.steps == 0 || phaseChanged != nil)
 
 	// increase phase to phase counter
 	phaseTsstates := make([]models.TSState, 1)
@@ -168,19 +237,152 @@ func (phase *Phase) Count(tsstates []models.TSState) {
 			StddevSum: 0,
 		},
 	}
-	phase.phaseTxCounter.Update(len(phase.phaseCounters))
 	phase.phaseTxCounter.Count(phaseTsstates)
 
 	// update history
 	phase.phaseTSStatesHistory = append(phase.phaseTSStatesHistory, tsstates)
-	if int64(len(phase.phaseTSStatesHistory)) > phase.phaseThresholdHistory {
+	if int64(len(phase.phaseTSStatesHistory)) > phase.matchHistory {
 		// remove first (oldest) item
 		phase.phaseTSStatesHistory = phase.phaseTSStatesHistory[1:]
 	}
+
+	phase.steps++
+	if (phaseChanged != nil && phaseChanged.IsNew) ||
+		(phase.consolidationInterval > 0 && phase.steps%phase.consolidationInterval == 0) {
+		phase.consolidate()
+	}
+	return phaseChanged
+}
+
+// updateMeta updates the metadata of the current phase with tsstates counted
+// at the current step, `entered` if the phase just became the current phase
+func (phase *Phase) updateMeta(tsstates []models.TSState, entered bool) {
+	meta := &phase.phaseMeta[phase.phasePointer]
+	if meta.States == 0 {
+		meta.FirstSeen = phase.steps
+	}
+	meta.LastSeen = phase.steps
+	if entered {
+		meta.Visits++
+	}
+	meta.States++
+	if meta.Stats == nil {
+		meta.Stats = make(map[string]models.TSStats)
+	}
+	for _, tsstate := range tsstates {
+		if tsstate.Metric == "" {
+			// no valid state discretized
+			continue
+		}
+		meta.Stats[tsstate.Metric] = utils.MergeStats(meta.Stats[tsstate.Metric], utils.ValueStats(tsstate.Value))
+	}
+}
+
+// consolidate prunes rarely counted phases, merges similar phases and
+// enforces the max. amount of phases
+func (phase *Phase) consolidate() {
+	for phase.minCount > 0 && len(phase.phaseCounters) > 1 {
+		// prune by merging into the most similar phase, never the current phase
+		pruned := -1
+		for i := range phase.phaseCounters {
+			if i != phase.phasePointer && phase.phaseCounters[i].Steps() < phase.minCount {
+				pruned = i
+				break
+			}
+		}
+		if pruned == -1 {
+			break
+		}
+		closest, closestDivergence := -1, math.Inf(1)
+		for i := range phase.phaseCounters {
+			if i == pruned {
+				continue
+			}
+			if divergence := phase.phaseCounters[i].Divergence(&phase.phaseCounters[pruned]); divergence < closestDivergence {
+				closest, closestDivergence = i, divergence
+			}
+		}
+		phase.mergePhases(closest, pruned)
+	}
+	for len(phase.phaseCounters) > 1 {
+		// merge the most similar phases
+		first, second, divergence := phase.closestPhases()
+		if divergence >= phase.mergeDivergence && (phase.maxPhases <= 0 || len(phase.phaseCounters) <= phase.maxPhases) {
+			break
+		}
+		phase.mergePhases(first, second)
+	}
+}
+
+// closestPhases returns the pair of phases with the lowest divergence
+func (phase *Phase) closestPhases() (int, int, float64) {
+	first, second, closestDivergence := 0, 1, math.Inf(1)
+	for i := range phase.phaseCounters {
+		for j := i + 1; j < len(phase.phaseCounters); j++ {
+			if divergence := phase.phaseCounters[i].Divergence(&phase.phaseCounters[j]); divergence < closestDivergence {
+				first, second, closestDivergence = i, j, divergence
+			}
+		}
+	}
+	return first, second, closestDivergence
+}
+
+// mergePhases merges phase `from` into phase `into`, removes it and remaps the
+// phase ids of the phase tx and the current phase
+func (phase *Phase) mergePhases(into int, from int) {
+	phase.phaseCounters[into].Merge(&phase.phaseCounters[from])
+	phase.phaseMeta[into] = mergeMeta(phase.phaseMeta[into], phase.phaseMeta[from])
+	mapping := make([]int, len(phase.phaseCounters))
+	for i := range mapping {
+		mapping[i] = i
+		if i == from {
+			mapping[i] = into
+		}
+		if mapping[i] > from {
+			// ids after the removed phase move up
+			mapping[i]--
+		}
+	}
+	phase.phaseCounters = append(phase.phaseCounters[:from], phase.phaseCounters[from+1:]...)
+	phase.phaseMeta = append(phase.phaseMeta[:from], phase.phaseMeta[from+1:]...)
+	phase.phaseTxCounter.RemapStates("phasetx", mapping, len(phase.phaseCounters))
+	phase.phasePointer = mapping[phase.phasePointer]
+}
+
+// mergeMeta returns the metadata of the phase merged from the phases of x and y
+func mergeMeta(x models.PhaseMeta, y models.PhaseMeta) models.PhaseMeta {
+	if x.States == 0 {
+		return y
+	}
+	if y.States == 0 {
+		return x
+	}
+	merged := models.PhaseMeta{
+		FirstSeen: x.FirstSeen,
+		LastSeen:  x.LastSeen,
+		Visits:    x.Visits + y.Visits,
+		States:    x.States + y.States,
+		Stats:     make(map[string]models.TSStats),
+	}
+	if y.FirstSeen < merged.FirstSeen {
+		merged.FirstSeen = y.FirstSeen
+	}
+	if y.LastSeen > merged.LastSeen {
+		merged.LastSeen = y.LastSeen
+	}
+	for metric, stats := range x.Stats {
+		merged.Stats[metric] = stats
+	}
+	for metric, stats := range y.Stats {
+		merged.Stats[metric] = utils.MergeStats(merged.Stats[metric], stats)
+	}
+	return merged
 }
 
 // GetPhasesTx returns
 func (phase *Phase) GetPhasesTx() models.Phases {
+	phase.access.Lock()
+	defer phase.access.Unlock()
 	txs := make([][]models.TxMatrix, len(phase.phaseCounters))
 	for i, counter := range phase.phaseCounters {
 		phaseTx := counter.GetTx()
@@ -190,16 +392,47 @@ func (phase *Phase) GetPhasesTx() models.Phases {
 	var txMetric models.TxMatrix
 	if len(tx) > 0 {
 		txMetric = tx[0]
+		// phase ids carry no values
+		txMetric.StateStats = nil
 		/*} else {
 		fmt.Printf("tx metric 0 ?! wtf")*/
 	}
+	// rows counted before further phases were created are too short, pad them
+	for key, txStep := range txMetric.Transitions {
+		for len(txStep.NextStateProbs) < len(txs) {
+			txStep.NextStateProbs = append(txStep.NextStateProbs, 0)
+		}
+		txMetric.Transitions[key] = txStep
+	}
+	meta := make([]models.PhaseMeta, len(phase.phaseMeta))
+	for i, phaseMeta := range phase.phaseMeta {
+		meta[i] = phaseMeta
+		meta[i].Stats = make(map[string]models.TSStats, len(phaseMeta.Stats))
+		for metric, stats := range phaseMeta.Stats {
+			meta[i].Stats[metric] = stats
+		}
+		if phaseMeta.Visits > 0 {
+			meta[i].MeanDuration = float64(phaseMeta.States) / float64(phaseMeta.Visits)
+		}
+	}
 	return models.Phases{
 		Phases: txs,      // the list of detected phases
 		Tx:     txMetric, // phase tx has only one metric by design
+		Meta:   meta,
 	}
 }
 
 // GetPhase returns the current phase pointer
 func (phase *Phase) GetPhase() int {
+	phase.access.Lock()
+	defer phase.access.Unlock()
 	return phase.phasePointer
 }
+
+// MetricLikeliness returns per metric the probability [0,1] of the state
+// change to tsstates according to the current phase
+func (phase *Phase) MetricLikeliness(tsstates []models.TSState) map[string]float32 {
+	phase.access.Lock()
+	defer phase.access.Unlock()
+	return phase.phaseCounters[phase.phasePointer].MetricLikeliness(tsstates)
+}
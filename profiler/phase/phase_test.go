@@ -0,0 +1,160 @@
+package phase
+
+import (
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// manualDetector changes the phase when told to, always to a new phase
+type manualDetector struct {
+	change bool
+}
+
+func (detector *manualDetector) Detect(likeliness float32) (bool, float32) {
+	changed := detector.change
+	detector.change = false
+	return changed, likeliness
+}
+
+func (detector *manualDetector) Matches(likeliness float32) bool {
+	return false
+}
+
+func (detector *manualDetector) Reset() {}
+
+// newTestPhase returns a phase of one metric with four states whose phase
+// changes are triggered by countPhase
+func newTestPhase() (*Phase, *manualDetector) {
+	phase := NewPhase(1, 4, 1, 0.5, 2, false, nil)
+	detector := &manualDetector{}
+	phase.SetDetector(detector)
+	return &phase, detector
+}
+
+// countPhase counts `steps` states cycling through `states` into a new phase
+func countPhase(phase *Phase, detector *manualDetector, steps int, states ...int64) {
+	detector.change = len(phase.phaseMeta[0].Stats) > 0
+	for i := 0; i < steps; i++ {
+		state := states[i%len(states)]
+		phase.Count([]models.TSState{{
+			Metric:     "metric",
+			State:      models.State{Value: state},
+			Value:      float64(state)*25 + 10,
+			Statistics: models.TSStats{Min: 0, Max: 100, Count: 1},
+		}})
+	}
+}
+
+// phaseTxRowSums returns the sums of the rows of the phase tx, which must
+// cover all phases
+func phaseTxRowSums(phases models.Phases) []int {
+	sums := make([]int, 0)
+	for _, txStep := range phases.Tx.Transitions {
+		So(txStep.NextStateProbs, ShouldHaveLength, len(phases.Phases))
+		sum := 0
+		for _, prob := range txStep.NextStateProbs {
+			sum += prob
+		}
+		sums = append(sums, sum)
+	}
+	return sums
+}
+
+func TestConsolidate(t *testing.T) {
+	Convey("Should merge near-identical phases", t, func() {
+		phase, detector := newTestPhase()
+		countPhase(phase, detector, 20, 0, 1)
+		countPhase(phase, detector, 20, 2, 3)
+		countPhase(phase, detector, 20, 0, 1)
+		So(phase.phaseCounters, ShouldHaveLength, 3)
+
+		first, second, divergence := phase.closestPhases()
+		So(first, ShouldEqual, 0)
+		So(second, ShouldEqual, 2)
+		So(divergence, ShouldBeLessThan, 0.1)
+
+		phase.SetConsolidation(0, 0.1, 0, 0)
+		phase.consolidate()
+		So(phase.phaseCounters, ShouldHaveLength, 2)
+		So(phase.GetPhase(), ShouldEqual, 0)
+
+		phases := phase.GetPhasesTx()
+		So(phases.Phases, ShouldHaveLength, 2)
+		So(phases.Meta[0].States, ShouldEqual, 40)
+		So(phases.Meta[0].Visits, ShouldEqual, 2)
+		So(phases.Meta[0].FirstSeen, ShouldEqual, 0)
+		So(phases.Meta[0].LastSeen, ShouldEqual, 59)
+		So(phases.Meta[1].States, ShouldEqual, 20)
+		for _, sum := range phaseTxRowSums(phases) {
+			So(sum, ShouldBeBetweenOrEqual, 99, 101)
+		}
+		// phase 0 changed to phase 1 and back
+		So(phases.Tx.Transitions["1"].NextStateProbs[0], ShouldBeGreaterThan, 0)
+	})
+
+	Convey("Should shift the ids after removing a middle phase", t, func() {
+		phase, detector := newTestPhase()
+		countPhase(phase, detector, 20, 0, 1)
+		countPhase(phase, detector, 2, 2, 3)
+		countPhase(phase, detector, 20, 2, 3)
+		So(phase.GetPhase(), ShouldEqual, 2)
+
+		// prune the rarely counted phase 1 into the most similar phase 2
+		phase.SetConsolidation(0, 0, 5, 0)
+		phase.consolidate()
+		So(phase.phaseCounters, ShouldHaveLength, 2)
+		So(phase.GetPhase(), ShouldEqual, 1)
+
+		phases := phase.GetPhasesTx()
+		So(phases.Meta[1].States, ShouldEqual, 22)
+		So(phases.Meta[1].FirstSeen, ShouldEqual, 20)
+		So(phases.Tx.Transitions, ShouldHaveLength, 2)
+		So(phases.Tx.Transitions["0"].NextStateProbs[1], ShouldBeGreaterThan, 0)
+		So(phases.Tx.Transitions["1"].NextStateProbs, ShouldResemble, []int{0, 100})
+		for _, sum := range phaseTxRowSums(phases) {
+			So(sum, ShouldBeBetweenOrEqual, 99, 101)
+		}
+
+		// counting continues in the remapped phase
+		phase.Count([]models.TSState{{
+			Metric:     "metric",
+			State:      models.State{Value: 2},
+			Value:      60,
+			Statistics: models.TSStats{Min: 0, Max: 100, Count: 1},
+		}})
+		So(phase.GetPhasesTx().Meta[1].States, ShouldEqual, 23)
+	})
+
+	Convey("Should merge the most similar phases while there are too many", t, func() {
+		phase, detector := newTestPhase()
+		countPhase(phase, detector, 20, 0, 1)
+		countPhase(phase, detector, 20, 2, 3)
+		countPhase(phase, detector, 20, 0, 1, 1)
+		countPhase(phase, detector, 20, 3, 2, 2)
+
+		phase.SetConsolidation(2, 0, 0, 0)
+		phase.consolidate()
+		phases := phase.GetPhasesTx()
+		So(phases.Phases, ShouldHaveLength, 2)
+		So(phases.Meta[0].States+phases.Meta[1].States, ShouldEqual, 80)
+		for _, sum := range phaseTxRowSums(phases) {
+			So(sum, ShouldBeBetweenOrEqual, 99, 101)
+		}
+	})
+
+	Convey("Should merge the metadata of phases", t, func() {
+		x := models.PhaseMeta{FirstSeen: 10, LastSeen: 20, Visits: 1, States: 11,
+			Stats: map[string]models.TSStats{"metric": {Min: 1, Max: 2, Avg: 1.5, Count: 11}}}
+		y := models.PhaseMeta{FirstSeen: 0, LastSeen: 30, Visits: 2, States: 5,
+			Stats: map[string]models.TSStats{"other": {Min: 3, Max: 3, Avg: 3, Count: 5}}}
+		merged := mergeMeta(x, y)
+		So(merged.FirstSeen, ShouldEqual, 0)
+		So(merged.LastSeen, ShouldEqual, 30)
+		So(merged.Visits, ShouldEqual, 3)
+		So(merged.States, ShouldEqual, 16)
+		So(merged.Stats, ShouldHaveLength, 2)
+		So(mergeMeta(models.PhaseMeta{}, y), ShouldResemble, y)
+	})
+}
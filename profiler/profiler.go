@@ -21,9 +21,10 @@ func NewProfiler(settings models.Settings) *Profiler {
 
 // Profiler is the TSProfiler implementation of spec.TSProfiler
 type Profiler struct {
-	input    chan models.TSInput
+	input    chan profilerInput
 	settings models.Settings
 	stopped  bool
+	steps    int64
 
 	// state
 	overallCounter counter.Counter
@@ -39,7 +40,7 @@ type Profiler struct {
 }
 
 func (profiler *Profiler) initialize(settings models.Settings) {
-	profiler.input = make(chan models.TSInput, 0)
+	profiler.input = make(chan profilerInput, 0)
 	profiler.settings = settings
 	profiler.stopped = false
 
@@ -47,10 +48,23 @@ func (profiler *Profiler) initialize(settings models.Settings) {
 	profiler.buffer = buffer.NewBuffer(settings.FilterStdDevs, profiler)
 	profiler.discretizer = discretizer.NewDiscretizer(settings.States, settings.FixBound, profiler)
 	profiler.period = period.NewPeriod(settings.History, settings.States, settings.BufferSize, settings.PeriodSize, profiler)
+	profiler.period.SetContextPruning(settings.ContextMinCount, settings.ContextMinDivergence)
 	profiler.phase = phase.NewPhase(settings.History, settings.States, settings.BufferSize, settings.PhaseChangeLikeliness, settings.PhaseChangeHistory, settings.PhaseChangeHistoryFadeout, profiler)
+	switch settings.PhaseDetector {
+	case models.PhaseDetectorCusum:
+		profiler.phase.SetDetector(phase.NewCusumDetector(settings.PhaseCusumDrift, settings.PhaseCusumThreshold))
+	case models.PhaseDetectorBayesian:
+		profiler.phase.SetDetector(phase.NewBayesianDetector(settings.PhaseHazard))
+	}
+	profiler.phase.SetConsolidation(settings.MaxPhases, settings.PhaseMergeDivergence, settings.PhaseMinCount, settings.PhaseConsolidationInterval)
+	profiler.phase.SetDwellTimes(settings.DwellTimes)
+	profiler.phase.SetContextPruning(settings.ContextMinCount, settings.ContextMinDivergence)
 
 	// initialize root tx counter
 	profiler.overallCounter = counter.NewCounter(settings.History, settings.States, settings.BufferSize, profiler)
+	profiler.overallCounter.SetJoint(settings.JointStates)
+	profiler.overallCounter.SetDwellTimes(settings.DwellTimes)
+	profiler.overallCounter.SetContextPruning(settings.ContextMinCount, settings.ContextMinDivergence)
 	profiler.lastStates = make([]models.TSState, 0)
 	profiler.access = &sync.Mutex{}
 
@@ -61,10 +75,34 @@ func (profiler *Profiler) initialize(settings models.Settings) {
 
 // Put adds a TSData item to the profiler
 func (profiler *Profiler) Put(data models.TSInput) {
-	profiler.input <- data
+	profiler.input <- profilerInput{data: data}
+}
+
+// Flush returns after the items put before were processed, i.e. current
+// state, phase and period path include them. Flush is not part of
+// api.TSProfiler.
+func (profiler *Profiler) Flush() {
+	reply := make(chan *models.AnomalyScore, 1)
+	profiler.input <- profilerInput{flush: true, reply: reply}
+	<-reply
+}
+
+// Score adds a TSData item to the profiler like Put and returns the anomaly
+// score of the buffer it completed, i.e. how likely the buffer's states are
+// according to the transitions counted before. The bool is false if the item
+// did not complete a buffer. Score is not part of api.TSProfiler.
+func (profiler *Profiler) Score(data models.TSInput) (models.AnomalyScore, bool) {
+	reply := make(chan *models.AnomalyScore, 1)
+	profiler.input <- profilerInput{data: data, score: true, reply: reply}
+	score := <-reply
+	if score == nil {
+		return models.AnomalyScore{}, false
+	}
+	return *score, true
 }
 
-// Get generates an returns a profile based on previously put data
+// Get generates an returns a profile based on previously put data. It is
+// safe to call while data is put from another goroutine.
 func (profiler *Profiler) Get() models.TSProfile {
 	return profiler.generateProfile()
 }
@@ -76,16 +114,22 @@ func (profiler *Profiler) GetCurrentStats() map[string]models.TSStats {
 
 // GetCurrentState returns the current state for each metric
 func (profiler *Profiler) GetCurrentState() []models.TSState {
-	return profiler.lastStates
+	profiler.access.Lock()
+	defer profiler.access.Unlock()
+	return append([]models.TSState{}, profiler.lastStates...)
 }
 
 // GetCurrentPhase returns the current phase id
 func (profiler *Profiler) GetCurrentPhase() int {
+	profiler.access.Lock()
+	defer profiler.access.Unlock()
 	return profiler.phase.GetPhase()
 }
 
 // GetCurrentPeriodPath returns the current period path
 func (profiler *Profiler) GetCurrentPeriodPath() []int {
+	profiler.access.Lock()
+	defer profiler.access.Unlock()
 	return profiler.period.GetCurrentPeriodPath()
 }
 
@@ -95,14 +139,29 @@ func (profiler *Profiler) Terminate() {
 	close(profiler.input)
 }
 
+// profilerInput is an item put to the profiler, `score` if the anomaly score
+// of the buffer it completes is requested. The item's caller waits on its own
+// `reply` for the score, nil if the item did not complete a buffer. A `flush`
+// input holds no item, it is replied to once the items before are processed.
+type profilerInput struct {
+	data  models.TSInput
+	score bool
+	flush bool
+	reply chan *models.AnomalyScore
+}
+
 // inputListener handles incoming tsdata item from input channel
 func (profiler *Profiler) inputListener() {
 	itemCount := 0
-	for !profiler.stopped {
-		input := <-profiler.input
-
-		profiler.buffer.Add(input)
+	// until Terminate closes the input channel
+	for input := range profiler.input {
+		if input.flush {
+			input.reply <- nil
+			continue
+		}
+		profiler.buffer.Add(input.data)
 		itemCount++
+		var score *models.AnomalyScore
 
 		if itemCount >= profiler.settings.BufferSize {
 			// buffer is full, trigger discretizer!
@@ -111,6 +170,13 @@ func (profiler *Profiler) inputListener() {
 
 			profiler.access.Lock()
 
+			// score before counting, against the transitions seen so far,
+			// only if anyone consumes the score
+			if input.score || profiler.settings.AnomalyCallback != nil {
+				anomalyScore := profiler.score(tsstates)
+				score = &anomalyScore
+			}
+
 			// global all time counting
 			profiler.overallCounter.Count(tsstates)
 
@@ -118,20 +184,60 @@ func (profiler *Profiler) inputListener() {
 			profiler.lastStates = tsstates
 
 			// call sub components
+			events := make([]models.Event, 0)
 			if len(profiler.settings.PeriodSize) > 0 {
-				profiler.period.Count(tsstates)
+				if periodBoundary := profiler.period.Count(tsstates); periodBoundary != nil {
+					events = append(events, models.Event{PeriodBoundary: periodBoundary})
+				}
 			}
-			if profiler.settings.PhaseChangeLikeliness != float32(0) {
-				profiler.phase.Count(tsstates)
+			if profiler.settings.PhaseDetection() {
+				if phaseChanged := profiler.phase.Count(tsstates); phaseChanged != nil {
+					events = append(events, models.Event{PhaseChanged: phaseChanged})
+				}
 			}
+			step := profiler.steps
+			profiler.steps++
 
 			itemCount = 0
 
 			profiler.access.Unlock()
+
+			if profiler.settings.AnomalyCallback != nil {
+				profiler.settings.AnomalyCallback(*score)
+			}
+			if profiler.settings.EventCallback != nil {
+				timestamp := time.Now()
+				for _, event := range events {
+					event.Step = step
+					event.Timestamp = timestamp
+					profiler.settings.EventCallback(event)
+				}
+			}
+		}
+		if input.reply != nil {
+			input.reply <- score
 		}
 	}
 }
 
+// score computes the anomaly score of tsstates under the root, the current
+// phase's and the current period node's tx matrices
+func (profiler *Profiler) score(tsstates []models.TSState) models.AnomalyScore {
+	likeliness := map[string]map[string]float32{
+		models.AnomalySourceRoot: profiler.overallCounter.MetricLikeliness(tsstates),
+	}
+	if profiler.settings.PhaseDetection() {
+		likeliness[models.AnomalySourcePhase] = profiler.phase.MetricLikeliness(tsstates)
+	}
+	if len(profiler.settings.PeriodSize) > 0 {
+		likeliness[models.AnomalySourcePeriod] = profiler.period.MetricLikeliness(tsstates)
+	}
+	score := models.NewAnomalyScore(tsstates, likeliness)
+	score.Phase = profiler.phase.GetPhase()
+	score.PeriodPath = append([]int{}, profiler.period.GetCurrentPeriodPath()...)
+	return score
+}
+
 // outputRunner schedules periodic tsprofile generation (if OutputFreq && OutputCallback are set)
 func (profiler *Profiler) outputRunner() {
 	if profiler.settings.OutputCallback == nil || profiler.settings.OutputFreq == 0 {
@@ -147,17 +253,22 @@ func (profiler *Profiler) outputRunner() {
 	}
 }
 
-// generateProfile collects the necessary data to return a TSProfile
+// generateProfile collects the necessary data to return a TSProfile,
+// consistently between two buffers
 func (profiler *Profiler) generateProfile() models.TSProfile {
+	profiler.access.Lock()
+	defer profiler.access.Unlock()
 	periodTree := profiler.period.GetTx()
 	//periodTree.Root.TxMatrix = profiler.overallCounter.GetTx()
 	rootTx := profiler.overallCounter.GetTx()
 	phases := profiler.phase.GetPhasesTx()
 	return models.TSProfile{
+		Version:    models.ProfileVersion,
 		Name:       profiler.settings.Name,
 		RootTx:     rootTx,
 		PeriodTree: periodTree,
 		Phases:     phases,
 		Settings:   profiler.settings,
+		JointTx:    profiler.overallCounter.GetJointTx(),
 	}
 }
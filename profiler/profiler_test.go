@@ -0,0 +1,193 @@
+package profiler
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// testSettings returns settings profiling two metrics with phases and periods
+func testSettings() models.Settings {
+	return models.Settings{
+		Name:                  "test",
+		BufferSize:            2,
+		States:                4,
+		History:               2,
+		FilterStdDevs:         4,
+		FixBound:              true,
+		PeriodSize:            []int{4, 6},
+		PhaseChangeLikeliness: 0.3,
+		PhaseChangeHistory:    4,
+	}
+}
+
+// testInput returns the i-th input of a periodic series of two metrics
+func testInput(i int) models.TSInput {
+	return models.TSInput{
+		Metrics: []models.TSInputMetric{
+			{Name: "sine", Value: 50 + 40*math.Sin(float64(i)/8), FixedMin: 0, FixedMax: 100},
+			{Name: "step", Value: float64(20 + 60*((i/40)%2)), FixedMin: 0, FixedMax: 100},
+		},
+	}
+}
+
+func TestProfilerConcurrentAccess(t *testing.T) {
+	Convey("Should generate consistent profiles while data is put concurrently", t, func() {
+		profiler := NewProfiler(testSettings())
+		defer profiler.Terminate()
+
+		done := make(chan bool)
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		profiles := 0
+		var errs []error
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				profile := profiler.Get()
+				profiler.GetCurrentState()
+				profiler.GetCurrentPhase()
+				profiler.GetCurrentPeriodPath()
+				profiler.GetCurrentStats()
+				if err := profile.ValidateSemantics(5); err != nil && len(profile.RootTx) > 0 {
+					errs = append(errs, err)
+				}
+				profiles++
+			}
+		}()
+		for i := 0; i < 2000; i++ {
+			profiler.Put(testInput(i))
+		}
+		profiler.Flush()
+		close(done)
+		wg.Wait()
+
+		So(profiles, ShouldBeGreaterThan, 0)
+		So(errs, ShouldBeEmpty)
+		profile := profiler.Get()
+		So(profile.RootTx, ShouldHaveLength, 2)
+		So(profile.Validate(), ShouldBeNil)
+	})
+}
+
+func TestScore(t *testing.T) {
+	Convey("Should score the buffers completed by Score", t, func() {
+		profiler := NewProfiler(testSettings())
+		defer profiler.Terminate()
+
+		_, completed := profiler.Score(testInput(0))
+		So(completed, ShouldBeFalse)
+		_, completed = profiler.Score(testInput(1))
+		So(completed, ShouldBeTrue)
+
+		i := 2
+		for ; i < 400; i++ {
+			profiler.Put(testInput(i))
+		}
+		profiler.Put(testInput(i))
+		score, completed := profiler.Score(testInput(i + 1))
+		So(completed, ShouldBeTrue)
+		So(score.Metrics, ShouldHaveLength, 2)
+		So(score.Likeliness, ShouldContainKey, models.AnomalySourceRoot)
+		So(score.Likeliness, ShouldContainKey, models.AnomalySourcePhase)
+		So(score.Metrics[0].Contribution+score.Metrics[1].Contribution, ShouldAlmostEqual, 1, 1e-9)
+
+		// a jump never seen before is more surprising
+		unusual := testInput(i + 2)
+		unusual.Metrics[0].Value = 100 - unusual.Metrics[0].Value
+		unusual.Metrics[1].Value = 100 - unusual.Metrics[1].Value
+		profiler.Put(unusual)
+		unusualScore, completed := profiler.Score(unusual)
+		So(completed, ShouldBeTrue)
+		So(unusualScore.Surprise, ShouldBeGreaterThan, score.Surprise)
+	})
+
+	Convey("Should reply the score of its own buffer to each concurrent Score call", t, func() {
+		profiler := NewProfiler(models.Settings{
+			Name:          "test",
+			BufferSize:    1,
+			States:        4,
+			History:       1,
+			FilterStdDevs: 4,
+			FixBound:      true,
+		})
+		defer profiler.Terminate()
+		input := func(value float64) models.TSInput {
+			return models.TSInput{Metrics: []models.TSInputMetric{{Name: "metric", Value: value, FixedMin: 0, FixedMax: 100}}}
+		}
+
+		// buffers are scored once their previous state was left before
+		for _, value := range []float64{10, 90, 90, 10} {
+			profiler.Put(input(value))
+		}
+
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				profiler.Put(input(10))
+			}
+		}()
+		states := make(map[int64]int)
+		for i := 0; i < 500; i++ {
+			score, completed := profiler.Score(input(90))
+			So(completed, ShouldBeTrue)
+			states[score.Metrics[0].State]++
+		}
+		wg.Wait()
+		So(states, ShouldResemble, map[int64]int{3: 500})
+	})
+}
+
+func TestCallbacks(t *testing.T) {
+	Convey("Should call the callbacks for each buffer before Flush returns", t, func() {
+		scores := make([]models.AnomalyScore, 0)
+		events := make([]models.Event, 0)
+		settings := testSettings()
+		// change phases while the transitions are still learned
+		settings.PhaseChangeLikeliness = 0.7
+		settings.AnomalyCallback = func(score models.AnomalyScore) {
+			scores = append(scores, score)
+		}
+		settings.EventCallback = func(event models.Event) {
+			events = append(events, event)
+		}
+		profiler := NewProfiler(settings)
+		defer profiler.Terminate()
+
+		for i := 0; i < 400; i++ {
+			profiler.Put(testInput(i))
+			profiler.Flush()
+			So(scores, ShouldHaveLength, (i+1)/2)
+		}
+
+		boundaries := make([]int64, 0)
+		phaseChanges := 0
+		for i, event := range events {
+			if i > 0 {
+				So(event.Step, ShouldBeGreaterThanOrEqualTo, events[i-1].Step)
+			}
+			if event.PeriodBoundary != nil {
+				boundaries = append(boundaries, event.Step)
+			}
+			if event.PhaseChanged != nil {
+				So(event.PhaseChanged.To, ShouldNotEqual, event.PhaseChanged.From)
+				phaseChanges++
+			}
+		}
+		// the period path moves on every 6 buffers
+		So(boundaries, ShouldHaveLength, 200/6)
+		So(boundaries[:3], ShouldResemble, []int64{5, 11, 17})
+		So(phaseChanges, ShouldBeGreaterThan, 0)
+		So(profiler.GetCurrentPhase(), ShouldEqual, scores[len(scores)-1].Phase)
+	})
+}
@@ -0,0 +1,50 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/cha87de/tsprofiler/models"
+)
+
+// DOT exports the first order transitions of the TxMatrix as Graphviz DOT
+// digraph with the states as nodes and the next state probabilities as
+// weighted edges. Edges with a probability below `threshold` [0,1] are
+// pruned, transitions from histories (History > 1) are omitted.
+func DOT(txMatrix models.TxMatrix, states int, threshold float64) []byte {
+	return dotGraph(txMatrix, states, threshold, txMatrix.Metric, "state")
+}
+
+// PhaseGraphDOT exports the transitions between the phases as Graphviz DOT
+// digraph, pruning edges with a probability below `threshold` [0,1]
+func PhaseGraphDOT(phasesTx models.TxMatrix, phases int, threshold float64) []byte {
+	return dotGraph(phasesTx, phases, threshold, "phases", "phase")
+}
+
+func dotGraph(txMatrix models.TxMatrix, states int, threshold float64, name string, nodeName string) []byte {
+	rows := heatmapRows(txMatrix, states)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "digraph %s {\n", strconv.Quote(name))
+	buf.WriteString("  node [shape=circle];\n")
+	for i := 0; i < states; i++ {
+		// label nodes with their step probability
+		stepProb := 0
+		if txStep, exists := txMatrix.Transitions[strconv.Itoa(i)]; exists {
+			stepProb = txStep.StepProb
+		}
+		fmt.Fprintf(&buf, "  %d [label=\"%s %d\\n%d%%\"];\n", i, nodeName, i, stepProb)
+	}
+	for from, row := range rows {
+		for to, prob := range row {
+			p := float64(prob) / 100
+			if prob <= 0 || p < threshold {
+				continue
+			}
+			fmt.Fprintf(&buf, "  %d -> %d [label=\"%.2f\", weight=%d, penwidth=%.2f];\n", from, to, p, prob, 0.5+4*p)
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
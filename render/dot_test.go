@@ -0,0 +1,52 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDOT(t *testing.T) {
+	Convey("Should export the states and the transitions above the threshold", t, func() {
+		dot := DOT(testTxMatrix("cpu"), 3, 0.25)
+		So(string(dot), ShouldEqual, `digraph "cpu" {
+  node [shape=circle];
+  0 [label="state 0\n60%"];
+  1 [label="state 1\n40%"];
+  2 [label="state 2\n0%"];
+  0 -> 0 [label="0.80", weight=80, penwidth=3.70];
+  1 -> 1 [label="0.30", weight=30, penwidth=1.70];
+  1 -> 2 [label="0.70", weight=70, penwidth=3.30];
+}
+`)
+	})
+
+	Convey("Should keep all transitions without threshold", t, func() {
+		dot := string(DOT(testTxMatrix("cpu"), 3, 0))
+		So(strings.Count(dot, " -> "), ShouldEqual, 4)
+		So(dot, ShouldContainSubstring, `0 -> 1 [label="0.20", weight=20, penwidth=1.30];`)
+		// the transitions of history 0-1 are omitted
+		So(dot, ShouldNotContainSubstring, `label="1.00"`)
+	})
+
+	Convey("Should quote the metric name", t, func() {
+		dot := string(DOT(testTxMatrix(`cpu "util"`), 3, 0.25))
+		So(dot, ShouldStartWith, `digraph "cpu \"util\"" {`)
+	})
+
+	Convey("Should label the phases", t, func() {
+		phasesTx := models.TxMatrix{
+			Transitions: map[string]models.TXStep{
+				"0": {NextStateProbs: []int{90, 10}, StepProb: 75},
+				"1": {NextStateProbs: []int{50, 50}, StepProb: 25},
+			},
+		}
+		dot := string(PhaseGraphDOT(phasesTx, 2, 0.2))
+		So(dot, ShouldStartWith, `digraph "phases" {`)
+		So(dot, ShouldContainSubstring, `0 [label="phase 0\n75%"];`)
+		So(dot, ShouldContainSubstring, `1 [label="phase 1\n25%"];`)
+		So(strings.Count(dot, " -> "), ShouldEqual, 3)
+	})
+}
@@ -0,0 +1,88 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/cha87de/tsprofiler/models"
+)
+
+// PhaseGraphSVG renders the transitions between the phases as SVG graph with
+// the phases arranged on a circle. Transitions with a probability below
+// `threshold` [0,1] are omitted.
+func PhaseGraphSVG(phasesTx models.TxMatrix, phases int, threshold float64) []byte {
+	matrix := heatmapRows(phasesTx, phases)
+	radius := 40 + 20*float64(phases)
+	if radius > 260 {
+		radius = 260
+	}
+	nodeRadius := 16.0
+	center := radius + 50
+	size := int(2 * center)
+
+	pos := func(i int) (float64, float64) {
+		if phases == 1 {
+			return center, center
+		}
+		angle := 2*math.Pi*float64(i)/float64(phases) - math.Pi/2
+		return center + radius*math.Cos(angle), center + radius*math.Sin(angle)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`, size, size, size, size)
+	buf.WriteString(`<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto-start-reverse"><path d="M 0 0 L 10 5 L 0 10 z" fill="#555"/></marker></defs>`)
+
+	for from, row := range matrix {
+		for to, prob := range row {
+			p := float64(prob) / 100
+			if prob <= 0 || p < threshold {
+				continue
+			}
+			width := 0.5 + 4*p
+			x1, y1 := pos(from)
+			if from == to {
+				// self loop above the node, pointing outwards from the center
+				dx, dy := x1-center, y1-center
+				norm := math.Hypot(dx, dy)
+				if norm == 0 {
+					dx, dy, norm = 0, -1, 1
+				}
+				lx, ly := x1+dx/norm*(nodeRadius+12), y1+dy/norm*(nodeRadius+12)
+				fmt.Fprintf(&buf, `<circle cx="%.1f" cy="%.1f" r="12" fill="none" stroke="#555" stroke-width="%.2f"><title>%d -&gt; %d: %d%%</title></circle>`, lx, ly, width, from, to, prob)
+				fmt.Fprintf(&buf, `<text x="%.1f" y="%.1f" text-anchor="middle">%d%%</text>`, x1+dx/norm*(nodeRadius+34), y1+dy/norm*(nodeRadius+34)+4, prob)
+				continue
+			}
+			x2, y2 := pos(to)
+			dx, dy := x2-x1, y2-y1
+			dist := math.Hypot(dx, dy)
+			ux, uy := dx/dist, dy/dist
+			// bend edges to the right to separate both directions
+			bend := 0.15 * dist
+			cx, cy := (x1+x2)/2-uy*bend, (y1+y2)/2+ux*bend
+			sx, sy := shorten(x1, y1, cx, cy, nodeRadius)
+			ex, ey := shorten(x2, y2, cx, cy, nodeRadius)
+			fmt.Fprintf(&buf, `<path d="M %.1f %.1f Q %.1f %.1f %.1f %.1f" fill="none" stroke="#555" stroke-width="%.2f" marker-end="url(#arrow)"><title>%d -&gt; %d: %d%%</title></path>`,
+				sx, sy, cx, cy, ex, ey, width, from, to, prob)
+			fmt.Fprintf(&buf, `<text x="%.1f" y="%.1f" text-anchor="middle">%d%%</text>`, ((x1+x2)/2+cx)/2, ((y1+y2)/2+cy)/2+4, prob)
+		}
+	}
+
+	for i := 0; i < phases; i++ {
+		x, y := pos(i)
+		fmt.Fprintf(&buf, `<circle cx="%.1f" cy="%.1f" r="%.0f" fill="#fff" stroke="black"/>`, x, y, nodeRadius)
+		fmt.Fprintf(&buf, `<text x="%.1f" y="%.1f" text-anchor="middle">%d</text>`, x, y+4, i)
+	}
+	buf.WriteString(`</svg>`)
+	return buf.Bytes()
+}
+
+// shorten moves the point (x,y) by `by` towards (tx,ty)
+func shorten(x, y, tx, ty, by float64) (float64, float64) {
+	dx, dy := tx-x, ty-y
+	dist := math.Hypot(dx, dy)
+	if dist == 0 {
+		return x, y
+	}
+	return x + dx/dist*by, y + dy/dist*by
+}
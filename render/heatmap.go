@@ -0,0 +1,166 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+
+	"github.com/cha87de/tsprofiler/models"
+)
+
+// heatmapRows returns the next state probabilities [0,100] of the first order
+// states as states x states matrix, rows of unknown states are left empty
+func heatmapRows(txMatrix models.TxMatrix, states int) [][]int {
+	rows := make([][]int, states)
+	for i := range rows {
+		rows[i] = make([]int, states)
+		txStep, exists := txMatrix.Transitions[strconv.Itoa(i)]
+		if !exists {
+			continue
+		}
+		for j, prob := range txStep.NextStateProbs {
+			if j < states {
+				rows[i][j] = prob
+			}
+		}
+	}
+	return rows
+}
+
+// grey returns the grey value for a probability [0,100], darker for higher probabilities
+func grey(prob int) uint8 {
+	if prob < 0 {
+		prob = 0
+	}
+	if prob > 100 {
+		prob = 100
+	}
+	return uint8(255 - prob*255/100)
+}
+
+// cellSize scales the cells of a matrix with `states` rows to a reasonable image size
+func cellSize(states int) int {
+	if states <= 0 {
+		return 1
+	}
+	size := 480 / states
+	if size > 40 {
+		size = 40
+	}
+	if size < 4 {
+		size = 4
+	}
+	return size
+}
+
+// HeatmapSVG renders the first order transitions of the TxMatrix as SVG
+// heatmap with the current states as rows and the next states as columns
+func HeatmapSVG(txMatrix models.TxMatrix, states int, title string) []byte {
+	rows := heatmapRows(txMatrix, states)
+	cell := cellSize(states)
+	left, top := 40, 50
+	width := left + states*cell + 80
+	height := top + states*cell + 20
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`, width, height, width, height)
+	fmt.Fprintf(&buf, `<text x="%d" y="16" font-size="13">%s</text>`, left, escape(title))
+	fmt.Fprintf(&buf, `<text x="%d" y="%d" text-anchor="middle">next state</text>`, left+states*cell/2, top-22)
+	fmt.Fprintf(&buf, `<text x="12" y="%d" text-anchor="middle" transform="rotate(-90 12 %d)">current state</text>`, top+states*cell/2, top+states*cell/2)
+
+	for i, row := range rows {
+		if cell >= 12 {
+			fmt.Fprintf(&buf, `<text x="%d" y="%d" text-anchor="end">%d</text>`, left-4, top+i*cell+cell/2+4, i)
+			fmt.Fprintf(&buf, `<text x="%d" y="%d" text-anchor="middle">%d</text>`, left+i*cell+cell/2, top-6, i)
+		}
+		for j, prob := range row {
+			g := grey(prob)
+			fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="rgb(%d,%d,%d)"><title>%d -&gt; %d: %d%%</title></rect>`,
+				left+j*cell, top+i*cell, cell, cell, g, g, g, i, j, prob)
+			if cell >= 28 && prob > 0 {
+				textColor := "black"
+				if prob > 50 {
+					textColor = "white"
+				}
+				fmt.Fprintf(&buf, `<text x="%d" y="%d" text-anchor="middle" fill="%s">%d</text>`, left+j*cell+cell/2, top+i*cell+cell/2+4, textColor, prob)
+			}
+		}
+	}
+	fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="black"/>`, left, top, states*cell, states*cell)
+
+	// color bar
+	barX := left + states*cell + 20
+	barHeight := states * cell
+	for i := 0; i < 10; i++ {
+		g := grey(100 - i*10)
+		fmt.Fprintf(&buf, `<rect x="%d" y="%.2f" width="12" height="%.2f" fill="rgb(%d,%d,%d)"/>`, barX, float64(top)+float64(i)*float64(barHeight)/10, float64(barHeight)/10+0.5, g, g, g)
+	}
+	fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="12" height="%d" fill="none" stroke="black"/>`, barX, top, barHeight)
+	fmt.Fprintf(&buf, `<text x="%d" y="%d">100%%</text>`, barX+16, top+8)
+	fmt.Fprintf(&buf, `<text x="%d" y="%d">0%%</text>`, barX+16, top+barHeight)
+	buf.WriteString(`</svg>`)
+	return buf.Bytes()
+}
+
+// HeatmapPNG renders the first order transitions of the TxMatrix as PNG
+// heatmap (without labels), like HeatmapSVG
+func HeatmapPNG(txMatrix models.TxMatrix, states int) ([]byte, error) {
+	rows := heatmapRows(txMatrix, states)
+	cell := cellSize(states)
+	margin := 10
+	barWidth := 12
+	size := states * cell
+	img := image.NewRGBA(image.Rect(0, 0, size+3*margin+barWidth, size+2*margin))
+	fillRect(img, img.Bounds(), color.White)
+
+	for i, row := range rows {
+		for j, prob := range row {
+			g := grey(prob)
+			fillRect(img, image.Rect(margin+j*cell, margin+i*cell, margin+(j+1)*cell, margin+(i+1)*cell), color.RGBA{g, g, g, 255})
+		}
+	}
+	strokeRect(img, image.Rect(margin, margin, margin+size, margin+size), color.Black)
+
+	// color bar
+	barX := size + 2*margin
+	for y := 0; y < size; y++ {
+		g := grey(100 - y*100/size)
+		fillRect(img, image.Rect(barX, margin+y, barX+barWidth, margin+y+1), color.RGBA{g, g, g, 255})
+	}
+	strokeRect(img, image.Rect(barX, margin, barX+barWidth, margin+size), color.Black)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fillRect(img *image.RGBA, rect image.Rectangle, c color.Color) {
+	rect = rect.Intersect(img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.Set(x, y, c)
+		}
+	}
+}
+
+func strokeRect(img *image.RGBA, rect image.Rectangle, c color.Color) {
+	for x := rect.Min.X; x <= rect.Max.X; x++ {
+		img.Set(x, rect.Min.Y, c)
+		img.Set(x, rect.Max.Y, c)
+	}
+	for y := rect.Min.Y; y <= rect.Max.Y; y++ {
+		img.Set(rect.Min.X, y, c)
+		img.Set(rect.Max.X, y, c)
+	}
+}
+
+// escape escapes text for the use in SVG text nodes and attributes
+func escape(text string) string {
+	return html.EscapeString(text)
+}
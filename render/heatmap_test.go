@@ -0,0 +1,90 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// testTxMatrix returns a tx matrix of three states, state 2 never seen
+func testTxMatrix(metric string) models.TxMatrix {
+	return models.TxMatrix{
+		Metric: metric,
+		Transitions: map[string]models.TXStep{
+			"0":   {NextStateProbs: []int{80, 20, 0}, StepProb: 60},
+			"1":   {NextStateProbs: []int{0, 30, 70}, StepProb: 40},
+			"0-1": {NextStateProbs: []int{0, 0, 100}, StepProb: 10},
+		},
+	}
+}
+
+// wellFormed returns the decoding error of the svg document, if any
+func wellFormed(svg []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(svg))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	Convey("Should render a cell per first order transition", t, func() {
+		svg := HeatmapSVG(testTxMatrix("cpu"), 3, "cpu")
+		So(wellFormed(svg), ShouldBeNil)
+		// 3x3 cells, the border, 10 color bar steps and the color bar border
+		So(strings.Count(string(svg), "<rect"), ShouldEqual, 9+1+10+1)
+		So(strings.Count(string(svg), "<title>"), ShouldEqual, 9)
+		So(string(svg), ShouldContainSubstring, "<title>0 -&gt; 0: 80%</title>")
+		So(string(svg), ShouldContainSubstring, "<title>1 -&gt; 2: 70%</title>")
+		// the unknown state 2 and the history 0-1 are left empty
+		So(string(svg), ShouldContainSubstring, `fill="rgb(255,255,255)"><title>2 -&gt; 2: 0%</title>`)
+		So(string(svg), ShouldNotContainSubstring, ": 100%")
+	})
+
+	Convey("Should escape the metric name in the title", t, func() {
+		svg := HeatmapSVG(testTxMatrix(`<cpu & "io">`), 3, `<cpu & "io">`)
+		So(wellFormed(svg), ShouldBeNil)
+		So(string(svg), ShouldContainSubstring, "&lt;cpu &amp; &#34;io&#34;&gt;")
+		So(string(svg), ShouldNotContainSubstring, "<cpu")
+	})
+
+	Convey("Should render empty matrices", t, func() {
+		svg := HeatmapSVG(models.TxMatrix{}, 0, "")
+		So(wellFormed(svg), ShouldBeNil)
+		So(strings.Count(string(svg), "<title>"), ShouldEqual, 0)
+
+		svg = HeatmapSVG(models.TxMatrix{}, 2, "")
+		So(wellFormed(svg), ShouldBeNil)
+		So(strings.Count(string(svg), `fill="rgb(255,255,255)"><title>`), ShouldEqual, 4)
+	})
+
+	Convey("Should render the cells into the PNG", t, func() {
+		data, err := HeatmapPNG(testTxMatrix("cpu"), 3)
+		So(err, ShouldBeNil)
+		img, err := png.Decode(bytes.NewReader(data))
+		So(err, ShouldBeNil)
+		// 40px cells with a margin of 10px and a 12px color bar
+		So(img.Bounds().Dx(), ShouldEqual, 3*40+3*10+12)
+		So(img.Bounds().Dy(), ShouldEqual, 3*40+2*10)
+		r, _, _, _ := img.At(10+20, 10+20).RGBA()
+		So(r>>8, ShouldEqual, grey(80))
+		r, _, _, _ = img.At(10+2*40+20, 10+2*40+20).RGBA()
+		So(r>>8, ShouldEqual, 255)
+
+		data, err = HeatmapPNG(models.TxMatrix{}, 0)
+		So(err, ShouldBeNil)
+		_, err = png.Decode(bytes.NewReader(data))
+		So(err, ShouldBeNil)
+	})
+}
@@ -0,0 +1,201 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+
+	"github.com/cha87de/tsprofiler/models"
+)
+
+// PhaseGraphThreshold is the min. probability of phase transitions shown in the report
+const PhaseGraphThreshold = 0.05
+
+// Report renders a self-contained HTML report of the profile, containing the
+// heatmaps of all tx matrices, the phase transition graph and the period tree.
+// If `states` is not nil, the state over time plot is added as well.
+func Report(profile models.TSProfile, states [][]int64) ([]byte, error) {
+	data := reportData{
+		Profile: profile,
+		Metrics: make([]reportMetric, 0),
+	}
+
+	for _, txMatrix := range sortedTxMatrices(profile.RootTx) {
+		data.Metrics = append(data.Metrics, reportMetric{
+			Metric:     txMatrix.Metric,
+			Stats:      txMatrix.Stats,
+			Stationary: formatDistribution(txMatrix.StationaryDistribution(profile.Settings.States)),
+			Heatmap:    template.HTML(HeatmapSVG(txMatrix, profile.Settings.States, txMatrix.Metric)),
+		})
+	}
+
+	phaseCount := len(profile.Phases.Phases)
+	if phaseCount > 0 {
+		data.PhaseGraph = template.HTML(PhaseGraphSVG(profile.Phases.Tx, phaseCount, PhaseGraphThreshold))
+		data.PhaseHeatmap = template.HTML(HeatmapSVG(profile.Phases.Tx, phaseCount, "phase transitions"))
+		occupancy := profile.Phases.Tx.StateDistribution(phaseCount)
+		stationary := profile.Phases.Tx.StationaryDistribution(phaseCount)
+		for i, phase := range profile.Phases.Phases {
+			reportPhase := reportPhase{
+				Phase:      i,
+				Occupancy:  occupancy[i],
+				Stationary: stationary[i],
+			}
+			for _, txMatrix := range sortedTxMatrices(phase) {
+				reportPhase.Heatmaps = append(reportPhase.Heatmaps, template.HTML(HeatmapSVG(txMatrix, profile.Settings.States, txMatrix.Metric)))
+			}
+			data.Phases = append(data.Phases, reportPhase)
+		}
+	}
+
+	if len(profile.Settings.PeriodSize) > 0 {
+		data.PeriodTree = template.HTML(periodTreeHTML(&profile.PeriodTree.Root, []int{}, profile.Settings.States))
+	}
+
+	if states != nil {
+		// the states log holds no metric names, columns are named like in csv2tsprofile
+		data.States = template.HTML(StatesSVG(states, nil))
+	}
+
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type reportData struct {
+	Profile      models.TSProfile
+	Metrics      []reportMetric
+	PhaseGraph   template.HTML
+	PhaseHeatmap template.HTML
+	Phases       []reportPhase
+	PeriodTree   template.HTML
+	States       template.HTML
+}
+
+type reportMetric struct {
+	Metric     string
+	Stats      models.TSStats
+	Stationary string
+	Heatmap    template.HTML
+}
+
+type reportPhase struct {
+	Phase      int
+	Occupancy  float64
+	Stationary float64
+	Heatmaps   []template.HTML
+}
+
+// sortedTxMatrices returns the matrices sorted by metric name
+func sortedTxMatrices(txMatrices []models.TxMatrix) []models.TxMatrix {
+	sorted := make([]models.TxMatrix, len(txMatrices))
+	copy(sorted, txMatrices)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Metric < sorted[j].Metric
+	})
+	return sorted
+}
+
+func formatDistribution(distribution []float64) string {
+	parts := make([]string, len(distribution))
+	for state, p := range distribution {
+		parts[state] = fmt.Sprintf("%d:%.3f", state, p)
+	}
+	return strings.Join(parts, " ")
+}
+
+// periodTreeHTML renders the period tree as nested, collapsible lists
+func periodTreeHTML(node *models.PeriodTreeNode, path []int, states int) string {
+	var buf bytes.Buffer
+	name := "root"
+	if len(path) > 0 {
+		name = strings.Trim(strings.Replace(fmt.Sprint(path), " ", ".", -1), "[]")
+	}
+	buf.WriteString(`<details`)
+	if len(path) == 0 {
+		buf.WriteString(` open`)
+	}
+	fmt.Fprintf(&buf, `><summary>%s`, template.HTMLEscapeString(name))
+	for _, txMatrix := range sortedTxMatrices(node.TxMatrix) {
+		fmt.Fprintf(&buf, ` &nbsp; %s: state %d (avg %.2f, stddev %.2f)`,
+			template.HTMLEscapeString(txMatrix.Metric), txMatrix.DominantState(), txMatrix.Stats.Avg, txMatrix.Stats.Stddev)
+	}
+	buf.WriteString(`</summary><div class="node">`)
+	for _, txMatrix := range sortedTxMatrices(node.TxMatrix) {
+		buf.Write(HeatmapSVG(txMatrix, states, txMatrix.Metric))
+	}
+	for i := range node.Children {
+		childPath := make([]int, len(path)+1)
+		copy(childPath, path)
+		childPath[len(path)] = i
+		buf.WriteString(periodTreeHTML(&node.Children[i], childPath, states))
+	}
+	buf.WriteString(`</div></details>`)
+	return buf.String()
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>TSProfile {{.Profile.Name}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; text-align: left; }
+svg { margin: 4px; vertical-align: top; }
+details { margin-left: 1em; }
+summary { cursor: pointer; }
+.node { margin-left: 1em; }
+</style>
+</head>
+<body>
+<h1>TSProfile {{.Profile.Name}}</h1>
+<table>
+<tr><th>version</th><td>{{.Profile.Version}}</td></tr>
+<tr><th>states</th><td>{{.Profile.Settings.States}}</td></tr>
+<tr><th>history</th><td>{{.Profile.Settings.History}}</td></tr>
+<tr><th>buffersize</th><td>{{.Profile.Settings.BufferSize}}</td></tr>
+<tr><th>period size</th><td>{{.Profile.Settings.PeriodSize}}</td></tr>
+</table>
+
+<h2>Root transitions</h2>
+{{range .Metrics}}
+<h3>{{.Metric}}</h3>
+<table>
+<tr><th>min</th><th>max</th><th>avg</th><th>stddev</th><th>count</th></tr>
+<tr><td>{{printf "%.2f" .Stats.Min}}</td><td>{{printf "%.2f" .Stats.Max}}</td><td>{{printf "%.2f" .Stats.Avg}}</td><td>{{printf "%.2f" .Stats.Stddev}}</td><td>{{.Stats.Count}}</td></tr>
+</table>
+<p>stationary distribution: {{.Stationary}}</p>
+{{.Heatmap}}
+{{end}}
+
+<h2>Phases</h2>
+{{if .Phases}}
+{{.PhaseGraph}}
+{{.PhaseHeatmap}}
+<table>
+<tr><th>phase</th><th>occupancy</th><th>stationary</th></tr>
+{{range .Phases}}<tr><td>{{.Phase}}</td><td>{{printf "%.3f" .Occupancy}}</td><td>{{printf "%.3f" .Stationary}}</td></tr>
+{{end}}</table>
+{{range .Phases}}
+<details><summary>phase {{.Phase}}</summary>{{range .Heatmaps}}{{.}}{{end}}</details>
+{{end}}
+{{else}}
+<p>no phases detected</p>
+{{end}}
+
+<h2>Period tree</h2>
+{{if .PeriodTree}}{{.PeriodTree}}{{else}}<p>no period size configured</p>{{end}}
+
+{{if .States}}
+<h2>States over time</h2>
+{{.States}}
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,79 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReport(t *testing.T) {
+	Convey("Should render the empty profile", t, func() {
+		report, err := Report(models.TSProfile{}, nil)
+		So(err, ShouldBeNil)
+		So(strings.Count(string(report), "<h3>"), ShouldEqual, 0)
+		So(strings.Count(string(report), "<svg"), ShouldEqual, 0)
+		So(string(report), ShouldContainSubstring, "no phases detected")
+		So(string(report), ShouldContainSubstring, "no period size configured")
+		So(string(report), ShouldNotContainSubstring, "States over time")
+	})
+
+	Convey("Should render each metric, phase and period node", t, func() {
+		profile := models.TSProfile{
+			Name:       "test",
+			RootTx:     []models.TxMatrix{testTxMatrix("mem"), testTxMatrix("cpu")},
+			PeriodTree: models.NewPeriodTree([]int{2, 4}),
+			Settings: models.Settings{
+				States:     3,
+				History:    1,
+				PeriodSize: []int{2, 4},
+			},
+		}
+		profile.Phases = models.Phases{
+			Phases: [][]models.TxMatrix{{testTxMatrix("cpu")}, {testTxMatrix("cpu")}},
+			Tx: models.TxMatrix{
+				Transitions: map[string]models.TXStep{
+					"0": {NextStateProbs: []int{90, 10}, StepProb: 50},
+					"1": {NextStateProbs: []int{10, 90}, StepProb: 50},
+				},
+			},
+		}
+		profile.PeriodTree.Root.TxMatrix = []models.TxMatrix{testTxMatrix("cpu")}
+		report, err := Report(profile, [][]int64{{0}, {1}})
+		So(err, ShouldBeNil)
+		html := string(report)
+
+		// the metrics sorted by name
+		So(strings.Count(html, "<h3>"), ShouldEqual, 2)
+		So(strings.Index(html, "<h3>cpu</h3>"), ShouldBeLessThan, strings.Index(html, "<h3>mem</h3>"))
+		// the phase graph, the phase heatmap and a row and details per phase
+		So(strings.Count(html, "<details><summary>phase "), ShouldEqual, 2)
+		So(strings.Count(html, "-&gt; 1: 10%</title></path>"), ShouldEqual, 1)
+		// the period tree root with its two children
+		So(strings.Count(html, "<details"), ShouldEqual, 2+3)
+		So(html, ShouldContainSubstring, "<details open><summary>root &nbsp; cpu: state")
+		So(html, ShouldContainSubstring, "States over time")
+		// heatmaps of 2 metrics, the phases, 2 phases, the period root and the phase graph and states
+		So(strings.Count(html, "<svg"), ShouldEqual, 2+1+2+1+1+1)
+	})
+
+	Convey("Should escape the metric names", t, func() {
+		profile := models.TSProfile{
+			Name:       `<b>"test"</b>`,
+			RootTx:     []models.TxMatrix{testTxMatrix(`<cpu & "io">`)},
+			PeriodTree: models.NewPeriodTree([]int{2, 4}),
+			Settings: models.Settings{
+				States:     3,
+				PeriodSize: []int{2, 4},
+			},
+		}
+		profile.PeriodTree.Root.TxMatrix = []models.TxMatrix{testTxMatrix(`<cpu & "io">`)}
+		report, err := Report(profile, nil)
+		So(err, ShouldBeNil)
+		So(string(report), ShouldNotContainSubstring, "<cpu")
+		So(string(report), ShouldNotContainSubstring, "<b>")
+		So(string(report), ShouldContainSubstring, "<h3>&lt;cpu &amp; &#34;io&#34;&gt;</h3>")
+		So(string(report), ShouldContainSubstring, "<summary>root &nbsp; &lt;cpu &amp; &#34;io&#34;&gt;: state")
+	})
+}
@@ -0,0 +1,210 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// palette holds the line colors for multiple metrics
+var palette = []color.RGBA{
+	{31, 119, 180, 255},
+	{255, 127, 14, 255},
+	{44, 160, 44, 255},
+	{214, 39, 40, 255},
+	{148, 103, 189, 255},
+	{140, 86, 75, 255},
+}
+
+// ReadStates reads a states log as written by csv2tsprofile's --out.states:
+// one line per step with the space separated state of each metric
+func ReadStates(reader io.Reader) ([][]int64, error) {
+	states := make([][]int64, 0)
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		row := make([]int64, len(fields))
+		for i, field := range fields {
+			state, err := strconv.ParseInt(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+			row[i] = state
+		}
+		states = append(states, row)
+	}
+	return states, scanner.Err()
+}
+
+// statesPlot holds the layout of a state over time plot
+type statesPlot struct {
+	states    [][]int64
+	metrics   int
+	maxState  int64
+	width     int
+	height    int
+	left, top int
+	plotW     int
+	plotH     int
+}
+
+func newStatesPlot(states [][]int64) statesPlot {
+	plot := statesPlot{
+		states: states,
+		left:   40,
+		top:    30,
+		plotW:  800,
+		plotH:  240,
+	}
+	for _, row := range states {
+		if len(row) > plot.metrics {
+			plot.metrics = len(row)
+		}
+		for _, state := range row {
+			if state > plot.maxState {
+				plot.maxState = state
+			}
+		}
+	}
+	if plot.maxState == 0 {
+		plot.maxState = 1
+	}
+	plot.width = plot.left + plot.plotW + 20
+	plot.height = plot.top + plot.plotH + 40
+	return plot
+}
+
+func (plot *statesPlot) x(step int) float64 {
+	if len(plot.states) <= 1 {
+		return float64(plot.left)
+	}
+	return float64(plot.left) + float64(step)*float64(plot.plotW)/float64(len(plot.states)-1)
+}
+
+func (plot *statesPlot) y(state int64) float64 {
+	return float64(plot.top+plot.plotH) - float64(state)*float64(plot.plotH)/float64(plot.maxState)
+}
+
+// StatesSVG renders the states of each metric over time as SVG step plot
+func StatesSVG(states [][]int64, metrics []string) []byte {
+	plot := newStatesPlot(states)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`, plot.width, plot.height, plot.width, plot.height)
+	fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="black"/>`, plot.left, plot.top, plot.plotW, plot.plotH)
+	for state := int64(0); state <= plot.maxState; state++ {
+		fmt.Fprintf(&buf, `<text x="%d" y="%.1f" text-anchor="end">%d</text>`, plot.left-4, plot.y(state)+4, state)
+		fmt.Fprintf(&buf, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`, plot.left, plot.y(state), plot.left+plot.plotW, plot.y(state))
+	}
+	lastStep := len(states) - 1
+	if lastStep < 0 {
+		lastStep = 0
+	}
+	fmt.Fprintf(&buf, `<text x="%d" y="%d" text-anchor="middle">step (0 - %d)</text>`, plot.left+plot.plotW/2, plot.top+plot.plotH+16, lastStep)
+
+	for m := 0; m < plot.metrics; m++ {
+		c := palette[m%len(palette)]
+		points := make([]string, 0, 2*len(states))
+		for step, row := range states {
+			if m >= len(row) {
+				continue
+			}
+			if len(points) > 0 {
+				// step plot: keep the previous state until this step
+				previous := points[len(points)-1]
+				points = append(points, fmt.Sprintf("%.1f,%s", plot.x(step), previous[strings.Index(previous, ",")+1:]))
+			}
+			points = append(points, fmt.Sprintf("%.1f,%.1f", plot.x(step), plot.y(row[m])))
+		}
+		fmt.Fprintf(&buf, `<polyline fill="none" stroke="rgb(%d,%d,%d)" stroke-width="1.5" points="%s"/>`, c.R, c.G, c.B, strings.Join(points, " "))
+
+		name := fmt.Sprintf("metric_%d", m)
+		if m < len(metrics) {
+			name = metrics[m]
+		}
+		fmt.Fprintf(&buf, `<text x="%d" y="18" fill="rgb(%d,%d,%d)">%s</text>`, plot.left+m*120, c.R, c.G, c.B, escape(name))
+	}
+	buf.WriteString(`</svg>`)
+	return buf.Bytes()
+}
+
+// StatesPNG renders the states of each metric over time as PNG step plot (without labels)
+func StatesPNG(states [][]int64) ([]byte, error) {
+	plot := newStatesPlot(states)
+	img := image.NewRGBA(image.Rect(0, 0, plot.width, plot.height))
+	fillRect(img, img.Bounds(), color.White)
+	for state := int64(0); state <= plot.maxState; state++ {
+		y := int(plot.y(state))
+		drawLine(img, plot.left, y, plot.left+plot.plotW, y, color.RGBA{221, 221, 221, 255})
+	}
+	strokeRect(img, image.Rect(plot.left, plot.top, plot.left+plot.plotW, plot.top+plot.plotH), color.Black)
+
+	for m := 0; m < plot.metrics; m++ {
+		c := palette[m%len(palette)]
+		lastX, lastY := -1, -1
+		for step, row := range states {
+			if m >= len(row) {
+				continue
+			}
+			x, y := int(plot.x(step)), int(plot.y(row[m]))
+			if lastX >= 0 {
+				drawLine(img, lastX, lastY, x, lastY, c)
+				drawLine(img, x, lastY, x, y, c)
+			}
+			lastX, lastY = x, y
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawLine draws a line with Bresenham's algorithm
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
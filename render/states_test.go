@@ -0,0 +1,54 @@
+package render
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStates(t *testing.T) {
+	Convey("Should read the states log and skip empty lines", t, func() {
+		states, err := ReadStates(strings.NewReader("0 1\n\n2 3\n"))
+		So(err, ShouldBeNil)
+		So(states, ShouldResemble, [][]int64{{0, 1}, {2, 3}})
+
+		_, err = ReadStates(strings.NewReader("0 1\n2 x\n"))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldStartWith, "line 2:")
+	})
+
+	Convey("Should render a line and a legend per metric", t, func() {
+		states := [][]int64{{0, 2}, {1, 2}, {3, 0}}
+		svg := StatesSVG(states, []string{"cpu"})
+		So(wellFormed(svg), ShouldBeNil)
+		So(strings.Count(string(svg), "<polyline"), ShouldEqual, 2)
+		// a grid line per state 0 to 3
+		So(strings.Count(string(svg), "<line"), ShouldEqual, 4)
+		So(string(svg), ShouldContainSubstring, ">cpu</text>")
+		So(string(svg), ShouldContainSubstring, ">metric_1</text>")
+		So(string(svg), ShouldContainSubstring, "step (0 - 2)")
+		// the step plot holds the previous state until the next step
+		So(string(svg), ShouldContainSubstring, `points="40.0,270.0 440.0,270.0 440.0,190.0 840.0,190.0 840.0,30.0"`)
+	})
+
+	Convey("Should escape the metric names in the legend", t, func() {
+		svg := StatesSVG([][]int64{{0}, {1}}, []string{`<cpu & "io">`})
+		So(wellFormed(svg), ShouldBeNil)
+		So(string(svg), ShouldContainSubstring, ">&lt;cpu &amp; &#34;io&#34;&gt;</text>")
+	})
+
+	Convey("Should render empty states", t, func() {
+		svg := StatesSVG([][]int64{}, nil)
+		So(wellFormed(svg), ShouldBeNil)
+		So(strings.Count(string(svg), "<polyline"), ShouldEqual, 0)
+		So(string(svg), ShouldContainSubstring, "step (0 - 0)")
+
+		data, err := StatesPNG([][]int64{})
+		So(err, ShouldBeNil)
+		_, err = png.Decode(bytes.NewReader(data))
+		So(err, ShouldBeNil)
+	})
+}
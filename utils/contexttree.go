@@ -0,0 +1,320 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cha87de/tsprofiler/models"
+)
+
+// ContextTree is a variable order Markov model built from the counts of the
+// next states after each context, i.e. the state history like "3-1-2" with
+// the oldest state first. The parent of a context is the context without its
+// oldest state, the single states are the first order contexts.
+type ContextTree struct {
+	counts        map[string][]int64
+	minCount      int64
+	minDivergence float64
+
+	children map[string][]string
+	// n1 and n2 count per order the next states counted once resp. twice
+	n1 map[int]int64
+	n2 map[int]int64
+	// qualified holds the longer contexts counted often enough and diverging
+	// from their parent, qualifiedBelow per context the amount of qualified
+	// contexts ending with it
+	qualified      map[string]bool
+	qualifiedBelow map[string]int
+
+	// keys and probs of all contexts, nil if outdated
+	keys  []string
+	probs map[string][]float64
+}
+
+// NewContextTree builds the tree of the contexts of the given counts, pruned
+// and smoothed: contexts longer than one state are pruned if counted less than
+// `minCount` times or if their next states diverge less than `minDivergence`
+// (Jensen-Shannon divergence) from their parent's, unless a longer context
+// below them is kept. The next state probabilities of the longer contexts are
+// smoothed by interpolated absolute discounting (Kneser-Ney style) with the
+// probabilities of the parent, the first order contexts keep the observed
+// frequencies. The tree shares the counts, see Update.
+func NewContextTree(counts map[string][]int64, minCount int64, minDivergence float64) *ContextTree {
+	if counts == nil {
+		counts = make(map[string][]int64)
+	}
+	tree := &ContextTree{
+		counts:         counts,
+		minCount:       minCount,
+		minDivergence:  minDivergence,
+		children:       make(map[string][]string),
+		n1:             make(map[int]int64),
+		n2:             make(map[int]int64),
+		qualified:      make(map[string]bool),
+		qualifiedBelow: make(map[string]int),
+	}
+	for key, row := range counts {
+		if parent, hasParent := contextParent(key); hasParent {
+			tree.children[parent] = append(tree.children[parent], key)
+		}
+		order := contextOrder(key)
+		for _, n := range row {
+			if n == 1 {
+				tree.n1[order]++
+			} else if n == 2 {
+				tree.n2[order]++
+			}
+		}
+	}
+	for key := range counts {
+		tree.qualify(key)
+	}
+	return tree
+}
+
+// Update updates the tree after the count of the next state `next` of
+// `context` was increased (and its row created if new), instead of building
+// the tree anew for each count
+func (tree *ContextTree) Update(context string, row []int64, next int64) {
+	if Sum(row) == 1 {
+		// first count of the context, the counts may be shared already
+		if parent, hasParent := contextParent(context); hasParent {
+			tree.children[parent] = append(tree.children[parent], context)
+		}
+	}
+	tree.counts[context] = row
+	order := contextOrder(context)
+	switch row[next] {
+	case 1:
+		tree.n1[order]++
+	case 2:
+		tree.n1[order]--
+		tree.n2[order]++
+	case 3:
+		tree.n2[order]--
+	}
+	// the divergence of the context and of its children changed
+	tree.qualify(context)
+	for _, child := range tree.children[context] {
+		tree.qualify(child)
+	}
+	tree.keys = nil
+	tree.probs = nil
+}
+
+// qualify updates whether the longer context is counted often enough and
+// diverges from its parent
+func (tree *ContextTree) qualify(key string) {
+	parent, hasParent := contextParent(key)
+	if !hasParent {
+		return
+	}
+	row := tree.counts[key]
+	qualified := Sum(row) >= tree.minCount && countDivergence(row, tree.counts[parent]) >= tree.minDivergence
+	if qualified == tree.qualified[key] {
+		return
+	}
+	tree.qualified[key] = qualified
+	change := 1
+	if !qualified {
+		change = -1
+	}
+	for ; hasParent; parent, hasParent = contextParent(parent) {
+		tree.qualifiedBelow[parent] += change
+	}
+}
+
+// kept returns whether the context is a first order context, qualified or a
+// qualified context ends with it, i.e. whether it is kept in the pruned tree
+func (tree *ContextTree) kept(key string) bool {
+	if _, exists := tree.counts[key]; !exists {
+		return false
+	}
+	_, hasParent := contextParent(key)
+	return !hasParent || tree.qualified[key] || tree.qualifiedBelow[key] > 0
+}
+
+// discount estimates the discount of the order as n1 / (n1 + 2*n2)
+func (tree *ContextTree) discount(order int) float64 {
+	n1, n2 := tree.n1[order], tree.n2[order]
+	if n1+n2 <= 0 {
+		return 0.5
+	}
+	return float64(n1) / float64(n1+2*n2)
+}
+
+// probabilities computes the next state probabilities of the context from
+// the ones of its parent
+func (tree *ContextTree) probabilities(key string, parentProbs []float64, hasParent bool) []float64 {
+	row := tree.counts[key]
+	total := float64(Sum(row))
+	probs := make([]float64, len(row))
+	if total <= 0 {
+		if hasParent {
+			copy(probs, parentProbs)
+		}
+		return probs
+	}
+	if !hasParent {
+		// first order context (or its parent is unknown): observed frequencies
+		for state, n := range row {
+			probs[state] = float64(n) / total
+		}
+		return probs
+	}
+	discount := tree.discount(contextOrder(key))
+	var distinct float64
+	for _, n := range row {
+		if n > 0 {
+			distinct++
+		}
+	}
+	backoff := discount * distinct / total
+	for state, n := range row {
+		probs[state] = float64(n) / total
+		if n > 0 {
+			probs[state] = (float64(n) - discount) / total
+		}
+		if state < len(parentProbs) {
+			probs[state] += backoff * parentProbs[state]
+		}
+	}
+	return probs
+}
+
+// smooth computes the next state probabilities of all contexts, parents first
+func (tree *ContextTree) smooth() {
+	if tree.probs != nil {
+		return
+	}
+	tree.keys = make([]string, 0, len(tree.counts))
+	for key := range tree.counts {
+		tree.keys = append(tree.keys, key)
+	}
+	// shorter contexts first, parents before their children
+	sort.Slice(tree.keys, func(i, j int) bool {
+		orderI, orderJ := contextOrder(tree.keys[i]), contextOrder(tree.keys[j])
+		if orderI != orderJ {
+			return orderI < orderJ
+		}
+		return tree.keys[i] < tree.keys[j]
+	})
+	tree.probs = make(map[string][]float64)
+	for _, key := range tree.keys {
+		parent, _ := contextParent(key)
+		parentProbs, hasParent := tree.probs[parent]
+		tree.probs[key] = tree.probabilities(key, parentProbs, hasParent)
+	}
+}
+
+// Contexts returns the kept contexts, shorter contexts first
+func (tree *ContextTree) Contexts() []string {
+	tree.smooth()
+	contexts := make([]string, 0, len(tree.keys))
+	for _, key := range tree.keys {
+		if tree.kept(key) {
+			contexts = append(contexts, key)
+		}
+	}
+	return contexts
+}
+
+// Probabilities returns the next state probabilities of the longest kept
+// context matching the end of `stateHistory`, nil if none matches. Only the
+// probabilities of the context and its parents are computed.
+func (tree *ContextTree) Probabilities(stateHistory string) []float64 {
+	for {
+		if tree.kept(stateHistory) {
+			return tree.contextProbabilities(stateHistory)
+		}
+		index := strings.Index(stateHistory, "-")
+		if index < 0 {
+			return nil
+		}
+		stateHistory = stateHistory[index+1:]
+	}
+}
+
+// contextProbabilities computes the next state probabilities of the context
+// along its parents, like smooth
+func (tree *ContextTree) contextProbabilities(key string) []float64 {
+	if tree.probs != nil {
+		return tree.probs[key]
+	}
+	parent, hasParent := contextParent(key)
+	var parentProbs []float64
+	if _, exists := tree.counts[parent]; hasParent && exists {
+		parentProbs = tree.contextProbabilities(parent)
+	} else {
+		hasParent = false
+	}
+	return tree.probabilities(key, parentProbs, hasParent)
+}
+
+// Transitions returns the TXSteps of the kept contexts: the next state
+// probabilities in percent and the step probability, the share of the
+// context's counts of `maxCount`. First order contexts are rounded like
+// ComputeProbabilities, longer contexts to percentages summing up to 100.
+func (tree *ContextTree) Transitions(maxCount float64) map[string]models.TXStep {
+	output := make(map[string]models.TXStep)
+	for _, key := range tree.Contexts() {
+		row := tree.counts[key]
+		if _, hasParent := contextParent(key); !hasParent {
+			output[key] = ComputeProbabilities(map[string][]int64{key: row}, maxCount)[key]
+			continue
+		}
+		stepProb := float64(Sum(row)) / maxCount * 100
+		output[key] = models.TXStep{
+			NextStateProbs: Percentages(tree.probs[key]),
+			StepProb:       int(Round(stepProb)),
+		}
+	}
+	return output
+}
+
+// Percentages rounds the probabilities [0,1] to percentages summing up to 100
+// (by the largest remainders), all 0 if the probabilities sum up to 0
+func Percentages(probs []float64) []int {
+	percentages := make([]int, len(probs))
+	var total float64
+	for _, prob := range probs {
+		total += prob
+	}
+	if total <= 0 {
+		return percentages
+	}
+	remainders := make([]float64, len(probs))
+	missing := 100
+	for i, prob := range probs {
+		exact := prob / total * 100
+		percentages[i] = int(exact)
+		remainders[i] = exact - float64(percentages[i])
+		missing -= percentages[i]
+	}
+	order := make([]int, len(probs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return remainders[order[i]] > remainders[order[j]]
+	})
+	for i := 0; i < missing && i < len(order); i++ {
+		percentages[order[i]]++
+	}
+	return percentages
+}
+
+// contextParent returns the parent of the context, i.e. the context without
+// its oldest state, false for first order contexts
+func contextParent(key string) (string, bool) {
+	index := strings.Index(key, "-")
+	if index < 0 {
+		return "", false
+	}
+	return key[index+1:], true
+}
+
+// contextOrder returns the amount of states of the context
+func contextOrder(key string) int {
+	return strings.Count(key, "-") + 1
+}
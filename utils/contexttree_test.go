@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestContextTree(t *testing.T) {
+	counts := map[string][]int64{
+		"0":   {3, 1},
+		"1":   {1, 3},
+		"0-0": {3, 0},
+		"1-0": {0, 1},
+		"0-1": {1, 1},
+		"1-1": {0, 2},
+	}
+
+	Convey("Should prune rare and non diverging contexts", t, func() {
+		tree := NewContextTree(counts, 2, 0.1)
+		// 1-0 counted once, 0-1 diverges by 0.049 from 1
+		So(tree.Contexts(), ShouldResemble, []string{"0", "1", "0-0", "1-1"})
+		So(NewContextTree(counts, 1, 0).Contexts(), ShouldHaveLength, 6)
+	})
+
+	Convey("Should keep the contexts between a kept context and its first order", t, func() {
+		withChild := map[string][]int64{"1-0-1": {2, 0}}
+		for key, row := range counts {
+			withChild[key] = row
+		}
+		tree := NewContextTree(withChild, 2, 0.1)
+		So(tree.Contexts(), ShouldResemble, []string{"0", "1", "0-0", "0-1", "1-1", "1-0-1"})
+	})
+
+	Convey("Should smooth longer contexts with their parent", t, func() {
+		tree := NewContextTree(counts, 2, 0.1)
+		// discount 3 / (3 + 2*1) = 0.6: (3-0.6)/3 + 0.6/3*0.75 resp. 0.6/3*0.25
+		probs := tree.Probabilities("0-0")
+		So(probs[0], ShouldAlmostEqual, 0.95)
+		So(probs[1], ShouldAlmostEqual, 0.05)
+		So(tree.Probabilities("1-0"), ShouldResemble, []float64{0.75, 0.25})
+		So(tree.Probabilities("5"), ShouldBeNil)
+	})
+
+	Convey("Should compute the transitions of the kept contexts", t, func() {
+		transitions := NewContextTree(counts, 2, 0.1).Transitions(4)
+		So(transitions, ShouldHaveLength, 4)
+		So(transitions["0"].NextStateProbs, ShouldResemble, []int{75, 25})
+		So(transitions["0"].StepProb, ShouldEqual, 100)
+		So(transitions["0-0"].NextStateProbs, ShouldResemble, []int{95, 5})
+		So(transitions["0-0"].StepProb, ShouldEqual, 75)
+	})
+
+	Convey("Should update the tree like a tree built anew", t, func() {
+		random := rand.New(rand.NewSource(1))
+		shared := make(map[string][]int64)
+		tree := NewContextTree(shared, 2, 0.01)
+		unshared := NewContextTree(nil, 2, 0.01)
+		history := []string{"0", "0", "0"}
+		for step := 0; step < 500; step++ {
+			next := int64(random.Intn(3))
+			if random.Intn(4) == 0 {
+				// stay in the state more often
+				next, _ = strconv.ParseInt(history[2], 10, 64)
+			}
+			for i := range history {
+				context := strings.Join(history[i:], "-")
+				if _, exists := shared[context]; !exists {
+					shared[context] = make([]int64, 3)
+				}
+				shared[context][next]++
+				tree.Update(context, shared[context], next)
+				unshared.Update(context, shared[context], next)
+			}
+			history = append(history[1:], strconv.FormatInt(next, 10))
+
+			built := NewContextTree(shared, 2, 0.01)
+			So(tree.Probabilities(strings.Join(history, "-")), ShouldResemble, built.Probabilities(strings.Join(history, "-")))
+			if step%50 == 0 {
+				So(tree.Contexts(), ShouldResemble, built.Contexts())
+				So(tree.Transitions(float64(step+1)), ShouldResemble, built.Transitions(float64(step+1)))
+				So(unshared.Transitions(float64(step+1)), ShouldResemble, built.Transitions(float64(step+1)))
+			}
+		}
+		So(len(tree.Contexts()), ShouldBeLessThan, len(shared))
+	})
+
+	Convey("Should round probabilities to percentages summing up to 100", t, func() {
+		So(Percentages([]float64{1.0 / 3, 1.0 / 3, 1.0 / 3}), ShouldResemble, []int{34, 33, 33})
+		So(Percentages([]float64{0.125, 0.875}), ShouldResemble, []int{13, 87})
+		So(Percentages([]float64{0, 0}), ShouldResemble, []int{0, 0})
+	})
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"math"
+
 	"github.com/cha87de/tsprofiler/models"
 )
 
@@ -29,23 +31,46 @@ func SimpleDiscretize(value float64, maxstate int, min float64, max float64) mod
 // ClosestDiscretize returns a state between min and max with maxstate steps of given value, finding the closest state
 func ClosestDiscretize(value float64, maxstate int, min float64, max float64) models.State {
 	stateStepSize := float64(max-min) / float64(maxstate)
-	for i := 0; i < maxstate; i++ {
-		lowerbound := float64(i)*stateStepSize - 0.5*stateStepSize
-		upperbound := float64(i)*stateStepSize + 0.5*stateStepSize
-		if value >= lowerbound && value < upperbound {
-			return models.State{
-				Value: int64(i),
-			}
+	if math.IsNaN(value) || (stateStepSize <= 0 && value < 0) {
+		return models.State{
+			Value: int64(0),
 		}
 	}
-	maxupperbound := float64(maxstate-1)*stateStepSize + 0.5*stateStepSize
-	if value >= maxupperbound {
-		// exceeding the bound towards top
+	if stateStepSize <= 0 {
 		return models.State{
 			Value: int64(maxstate - 1),
 		}
 	}
+	// round instead of comparing against each state's bounds, which may leave
+	// gaps between the states due to floating point errors
+	state := math.Floor(value/stateStepSize + 0.5)
+	if state < 0 {
+		state = 0
+	}
+	if state > float64(maxstate-1) {
+		// exceeding the bound towards top
+		state = float64(maxstate - 1)
+	}
 	return models.State{
-		Value: int64(0),
+		Value: int64(state),
+	}
+}
+
+// ClosestStateBounds returns the range [lower, upper) of values which
+// ClosestDiscretize maps to the given state, limited to min and max
+func ClosestStateBounds(state int, maxstate int, min float64, max float64) (float64, float64) {
+	stateStepSize := float64(max-min) / float64(maxstate)
+	lower := (float64(state) - 0.5) * stateStepSize
+	upper := (float64(state) + 0.5) * stateStepSize
+	if state <= 0 {
+		// state 0 takes all values below
+		lower = min
+	}
+	if state >= maxstate-1 {
+		// the last state takes all values above
+		upper = max
 	}
+	lower = math.Min(math.Max(lower, min), max)
+	upper = math.Min(math.Max(upper, lower), max)
+	return lower, upper
 }
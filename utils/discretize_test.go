@@ -22,5 +22,23 @@ func TestClosestDiscretize(t *testing.T) {
 		So(ClosestDiscretize(70, 2, 0, 100).Value, ShouldEqual, 1)
 		//So(ClosestDiscretize(0, 4, 0, 0).Value, ShouldEqual, 3)
 		So(ClosestDiscretize(91, 4, 0, 100).Value, ShouldEqual, 3)
+		// no gaps between states due to floating point errors
+		So(ClosestDiscretize(75, 6, 0, 100).Value, ShouldEqual, 5)
+		So(ClosestDiscretize(-30, 4, 0, 100).Value, ShouldEqual, 0)
+	})
+}
+
+func TestClosestStateBounds(t *testing.T) {
+	Convey("Should return the value range of ClosestDiscretize's states", t, func() {
+		lower, upper := ClosestStateBounds(0, 4, 0, 100)
+		So(lower, ShouldEqual, 0)
+		So(upper, ShouldEqual, 12.5)
+		lower, upper = ClosestStateBounds(2, 4, 0, 100)
+		So(lower, ShouldEqual, 37.5)
+		So(upper, ShouldEqual, 62.5)
+		So(ClosestDiscretize(lower, 4, 0, 100).Value, ShouldEqual, 2)
+		lower, upper = ClosestStateBounds(3, 4, 0, 100)
+		So(lower, ShouldEqual, 62.5)
+		So(upper, ShouldEqual, 100)
 	})
 }
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"github.com/cha87de/tsprofiler/models"
+)
+
+// countDivergence returns the Jensen-Shannon divergence [0,1] of the
+// distributions given by the counts p and q. It is 1 if only one of them has
+// any counts.
+func countDivergence(p []int64, q []int64) float64 {
+	sumP := float64(Sum(p))
+	sumQ := float64(Sum(q))
+	if sumP <= 0 || sumQ <= 0 {
+		if sumP <= 0 && sumQ <= 0 {
+			return 0
+		}
+		return 1
+	}
+	probsP := make([]float64, len(p))
+	for i, n := range p {
+		probsP[i] = float64(n) / sumP
+	}
+	probsQ := make([]float64, len(q))
+	for i, n := range q {
+		probsQ[i] = float64(n) / sumQ
+	}
+	return models.JSDivergence(probsP, probsQ)
+}
+
+// MatrixDivergence returns the Jensen-Shannon divergence [0,1] of the rows of
+// the count matrices x and y, averaged weighted by the rows' counts. Rows
+// counted in one matrix only diverge by 1.
+func MatrixDivergence(x map[string][]int64, y map[string][]int64) float64 {
+	var divergenceSum, weightSum float64
+	for key, rowX := range x {
+		rowY := y[key]
+		weight := float64(Sum(rowX) + Sum(rowY))
+		divergenceSum += weight * countDivergence(rowX, rowY)
+		weightSum += weight
+	}
+	for key, rowY := range y {
+		if _, exists := x[key]; exists {
+			continue
+		}
+		weight := float64(Sum(rowY))
+		divergenceSum += weight
+		weightSum += weight
+	}
+	if weightSum <= 0 {
+		return 0
+	}
+	return divergenceSum / weightSum
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDivergence(t *testing.T) {
+	Convey("Should compute the Jensen-Shannon divergence of counts", t, func() {
+		So(countDivergence([]int64{1, 2, 3}, []int64{2, 4, 6}), ShouldAlmostEqual, 0)
+		So(countDivergence([]int64{5, 0}, []int64{0, 3}), ShouldAlmostEqual, 1)
+		So(countDivergence([]int64{1, 1}, []int64{1, 0}), ShouldAlmostEqual, 0.311278, 1e-6)
+		So(countDivergence([]int64{1, 1}, []int64{1, 0}), ShouldAlmostEqual, countDivergence([]int64{1, 0}, []int64{1, 1}))
+		So(countDivergence([]int64{0, 0}, []int64{0, 0}), ShouldEqual, 0)
+		So(countDivergence([]int64{0, 0}, []int64{1, 0}), ShouldEqual, 1)
+	})
+
+	Convey("Should weight the rows' divergences by their counts", t, func() {
+		x := map[string][]int64{
+			"0": {3, 1},
+			"1": {0, 2},
+		}
+		So(MatrixDivergence(x, x), ShouldAlmostEqual, 0)
+		y := map[string][]int64{
+			"0": {6, 2},
+			"2": {2, 2},
+		}
+		// row 0 equal (weight 12), rows 1 and 2 in one matrix only (weights 2 and 4)
+		So(MatrixDivergence(x, y), ShouldAlmostEqual, 6.0/18.0)
+		So(MatrixDivergence(x, map[string][]int64{}), ShouldAlmostEqual, 1)
+		So(MatrixDivergence(map[string][]int64{}, map[string][]int64{}), ShouldEqual, 0)
+	})
+}
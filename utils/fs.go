@@ -1,25 +1,99 @@
 package utils
 
 import (
-	"encoding/json"
-	"fmt"
+	"encoding/csv"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/cha87de/tsprofiler/models"
 )
 
-// ReadProfileFromFile returns the TSProfile model read from the given json file
-func ReadProfileFromFile(filepath string) models.TSProfile {
+// ReadProfile reads a TSProfile in the given format from reader and validates it
+func ReadProfile(reader io.Reader, format models.ProfileFormat) (models.TSProfile, error) {
+	byteValue, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return models.TSProfile{}, err
+	}
+	profile, err := models.UnmarshalProfile(byteValue, format)
+	if err != nil {
+		return profile, err
+	}
+	return profile, profile.Validate()
+}
+
+// ReadProfileFromFile returns the TSProfile model read from the given json or binary file
+func ReadProfileFromFile(filepath string) (models.TSProfile, error) {
+	return ReadProfileFromFileFormat(filepath, models.ProfileFormatAuto)
+}
+
+// ReadProfileFromFileFormat returns the TSProfile model read from the given file in the given format
+func ReadProfileFromFileFormat(filepath string, format models.ProfileFormat) (models.TSProfile, error) {
 	filehandler, err := os.Open(filepath)
 	if err != nil {
-		fmt.Println(err)
+		return models.TSProfile{}, err
 	}
 	defer filehandler.Close()
-	byteValue, _ := ioutil.ReadAll(filehandler)
+	return ReadProfile(filehandler, format)
+}
 
-	var profile models.TSProfile
-	json.Unmarshal(byteValue, &profile)
+// WriteFileAtomic writes data to a temporary file next to filename and
+// renames it afterwards, so readers never see a partially written file
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	tmpfile, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return err
+	}
+	// clean up temporary file on any error
+	defer os.Remove(tmpfile.Name())
 
-	return profile
+	if _, err := tmpfile.Write(data); err != nil {
+		tmpfile.Close()
+		return err
+	}
+	if err := tmpfile.Sync(); err != nil {
+		tmpfile.Close()
+		return err
+	}
+	if err := tmpfile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpfile.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpfile.Name(), filename)
+}
+
+// ReadCSV reads the rows of a CSV file, skipping values which are no numbers
+func ReadCSV(input io.Reader) ([][]float64, error) {
+	reader := csv.NewReader(input)
+	rows := make([][]float64, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var row []float64
+		for _, rawValue := range record {
+			value, err := strconv.ParseFloat(strings.TrimSpace(rawValue), 64)
+			if err != nil {
+				continue
+			}
+			row = append(row, value)
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
 }
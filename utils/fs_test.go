@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadCSV(t *testing.T) {
+	Convey("Should read the numbers of a CSV file", t, func() {
+		rows, err := ReadCSV(strings.NewReader("a,b\n1,2\n3, 4\n"))
+		So(err, ShouldBeNil)
+		So(rows, ShouldResemble, [][]float64{{1, 2}, {3, 4}})
+	})
+}
@@ -0,0 +1,299 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// jsonSchemaDraft is the JSON Schema version generated by JSONSchema
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// JSONSchema generates a JSON Schema for the json encoding of v's type. Named
+// struct types are placed in "definitions", fields tagged with omitempty are
+// optional, and nil slices and maps may be encoded as null.
+func JSONSchema(v interface{}, title string) map[string]interface{} {
+	generator := schemaGenerator{
+		definitions: make(map[string]map[string]interface{}),
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	root := generator.schema(t)
+
+	schema := make(map[string]interface{})
+	if t.Kind() == reflect.Struct {
+		// inline the root definition, siblings of $ref are ignored
+		for key, value := range generator.definitions[t.Name()] {
+			schema[key] = value
+		}
+	} else {
+		for key, value := range root {
+			schema[key] = value
+		}
+	}
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = title
+	schema["definitions"] = generator.definitions
+	return schema
+}
+
+type schemaGenerator struct {
+	definitions map[string]map[string]interface{}
+}
+
+func (generator *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		name := t.Name()
+		if _, exists := generator.definitions[name]; !exists {
+			// register first to handle recursive types like PeriodTreeNode
+			generator.definitions[name] = nil
+			generator.definitions[name] = generator.structSchema(t)
+		}
+		return map[string]interface{}{
+			"$ref": "#/definitions/" + name,
+		}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  []string{"array", "null"},
+			"items": generator.schema(t.Elem()),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 []string{"object", "null"},
+			"additionalProperties": generator.schema(t.Elem()),
+		}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	// anything else is not restricted
+	return map[string]interface{}{}
+}
+
+func (generator *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Type.Kind() == reflect.Func || field.Type.Kind() == reflect.Chan {
+			// unexported or not encoded
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		if name == "" {
+			name = field.Name
+		}
+		optional := false
+		for _, option := range parts[1:] {
+			if option == "omitempty" {
+				optional = true
+			}
+		}
+		properties[name] = generator.schema(field.Type)
+		if !optional {
+			required = append(required, name)
+		}
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// ValidateJSONSchema validates a generic json document (as decoded by
+// encoding/json into interface{}) against the schema and returns the
+// violations. Only the keywords generated by JSONSchema are supported: $ref,
+// type, properties, required, additionalProperties, items and minimum.
+func ValidateJSONSchema(schema map[string]interface{}, document interface{}) []string {
+	validator := schemaValidator{
+		root:       normalizeSchema(schema),
+		violations: make([]string, 0),
+	}
+	validator.validate(validator.root, document, "")
+	return validator.violations
+}
+
+// normalizeSchema converts the schema to its generic json representation
+func normalizeSchema(schema map[string]interface{}) map[string]interface{} {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return schema
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return schema
+	}
+	return normalized
+}
+
+type schemaValidator struct {
+	root       map[string]interface{}
+	violations []string
+}
+
+func (validator *schemaValidator) fail(path string, format string, args ...interface{}) {
+	if path == "" {
+		path = "(root)"
+	}
+	validator.violations = append(validator.violations, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (validator *schemaValidator) resolve(schema map[string]interface{}) map[string]interface{} {
+	ref, ok := schema["$ref"].(string)
+	if !ok {
+		return schema
+	}
+	const prefix = "#/definitions/"
+	definitions, _ := validator.root["definitions"].(map[string]interface{})
+	definition, ok := definitions[strings.TrimPrefix(ref, prefix)].(map[string]interface{})
+	if !strings.HasPrefix(ref, prefix) || !ok {
+		return map[string]interface{}{}
+	}
+	return definition
+}
+
+func (validator *schemaValidator) validate(schema map[string]interface{}, document interface{}, path string) {
+	schema = validator.resolve(schema)
+
+	if types, exists := schema["type"]; exists {
+		if !matchesType(types, document) {
+			validator.fail(path, "expected %v, got %s", types, jsonType(document))
+			return
+		}
+	}
+
+	if minimum, ok := schema["minimum"].(float64); ok {
+		if value, ok := jsonNumber(document); ok && value < minimum {
+			validator.fail(path, "%v is lower than minimum %v", value, minimum)
+		}
+	}
+
+	switch value := document.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				name, _ := r.(string)
+				if _, exists := value[name]; !exists {
+					validator.fail(path, "missing required property %s", name)
+				}
+			}
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if property, ok := properties[key].(map[string]interface{}); ok {
+				validator.validate(property, value[key], joinJSONPath(path, key))
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					validator.fail(path, "unknown property %s", key)
+				}
+			case map[string]interface{}:
+				validator.validate(additional, value[key], joinJSONPath(path, key))
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				validator.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+func matchesType(types interface{}, document interface{}) bool {
+	switch t := types.(type) {
+	case string:
+		return matchesSingleType(t, document)
+	case []interface{}:
+		for _, single := range t {
+			if s, ok := single.(string); ok && matchesSingleType(s, document) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesSingleType(t string, document interface{}) bool {
+	actual := jsonType(document)
+	if t == "number" && actual == "integer" {
+		return true
+	}
+	return t == actual
+}
+
+// jsonType returns the JSON Schema type name of a generic json value
+func jsonType(document interface{}) string {
+	switch value := document.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+func jsonNumber(document interface{}) (float64, bool) {
+	switch value := document.(type) {
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
+
+func joinJSONPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJSONSchema(t *testing.T) {
+	schema := JSONSchema(models.TSProfile{}, "TSProfile")
+
+	Convey("Should match the published schema", t, func() {
+		generated, err := json.MarshalIndent(schema, "", "  ")
+		So(err, ShouldBeNil)
+		published, err := ioutil.ReadFile("../docs/tsprofile.schema.json")
+		So(err, ShouldBeNil)
+		So(string(generated)+"\n", ShouldEqual, string(published))
+	})
+
+	Convey("Should validate documents against the schema", t, func() {
+		var valid interface{}
+		json.Unmarshal([]byte(`{
+			"version": 1,
+			"name": "test",
+			"roottx": [],
+			"periodTree": {"root": {"uuid": 0, "maxChilds": 0, "maxCounts": 0, "children": null, "txmatrix": []}},
+			"phases": {"phases": [], "tx": {"metric": "", "transitions": null, "stats": {"min": 0, "max": 0, "stddev": 0, "avg": 0, "count": 0, "stddevsum": 0}}},
+			"settings": {"buffersize": 1, "states": 4, "history": 1, "filterstddevs": 2, "fixbound": false, "periodsize": null, "phaseChangeLikeliness": 0.5, "phaseChangeHistory": 1, "phaseChangeHistoryFadeout": false}
+		}`), &valid)
+		So(ValidateJSONSchema(schema, valid), ShouldBeEmpty)
+
+		var invalid interface{}
+		json.Unmarshal([]byte(`{
+			"version": 1.5,
+			"name": "test",
+			"roottx": [{"metric": "m", "transitions": {"0": {"nextProbs": ["a"], "probability": 1}}, "stats": {}}],
+			"periodTree": {"root": {}},
+			"phases": {"phases": [], "tx": {}},
+			"settings": {},
+			"unknown": 1
+		}`), &invalid)
+		violations := ValidateJSONSchema(schema, invalid)
+		So(violations, ShouldContain, "(root): unknown property unknown")
+		So(violations, ShouldContain, "version: expected integer, got number")
+		So(violations, ShouldContain, "roottx[0].transitions.0.nextProbs[0]: expected integer, got string")
+		So(violations, ShouldContain, "periodTree.root: missing required property maxCounts")
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"math"
 	"sort"
 
+	"github.com/cha87de/tsprofiler/models"
 	"gonum.org/v1/gonum/stat"
 )
 
@@ -42,3 +43,34 @@ func Round(x float64) float64 {
 	}
 	return t
 }
+
+// MergeStats combines the statistics of two disjoint sets of values
+func MergeStats(x models.TSStats, y models.TSStats) models.TSStats {
+	if x.Count <= 0 {
+		return y
+	}
+	if y.Count <= 0 {
+		return x
+	}
+	count := x.Count + y.Count
+	delta := y.Avg - x.Avg
+	stddevSum := x.StddevSum + y.StddevSum + delta*delta*float64(x.Count)*float64(y.Count)/float64(count)
+	return models.TSStats{
+		Min:       math.Min(x.Min, y.Min),
+		Max:       math.Max(x.Max, y.Max),
+		Stddev:    math.Sqrt(stddevSum / float64(count)),
+		Avg:       x.Avg + delta*float64(y.Count)/float64(count),
+		Count:     count,
+		StddevSum: stddevSum,
+	}
+}
+
+// ValueStats returns the statistics of a single value
+func ValueStats(value float64) models.TSStats {
+	return models.TSStats{
+		Min:   value,
+		Max:   value,
+		Avg:   value,
+		Count: 1,
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/cha87de/tsprofiler/models"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMergeStats(t *testing.T) {
+	Convey("Should merge the statistics of values", t, func() {
+		stats := models.TSStats{}
+		for _, v := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+			stats = MergeStats(stats, ValueStats(v))
+		}
+		So(stats.Count, ShouldEqual, 8)
+		So(stats.Avg, ShouldEqual, 5)
+		So(stats.Stddev, ShouldEqual, 2)
+		So(stats.Min, ShouldEqual, 2)
+		So(stats.Max, ShouldEqual, 9)
+
+		merged := MergeStats(
+			MergeStats(ValueStats(2), ValueStats(4)),
+			MergeStats(ValueStats(6), ValueStats(8)),
+		)
+		So(merged.Avg, ShouldEqual, 5)
+		So(merged.StddevSum, ShouldEqual, 20)
+		So(MergeStats(models.TSStats{}, merged), ShouldResemble, merged)
+	})
+}
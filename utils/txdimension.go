@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -86,3 +87,102 @@ func ChangeDimension(sourceMatrix map[string][]int64, oldStats models.TSStats, n
 	}
 	return targetMatrix
 }
+
+// ChangeJointDimension transforms the states of the metric at `component` in
+// the joint state keys of the given jointMatrix from stats oldStats to the new
+// shape specified in newStats
+func ChangeJointDimension(jointMatrix map[string]map[string]int64, component int, oldStats models.TSStats, newStats models.TSStats, states int) map[string]map[string]int64 {
+	oldStateStepSize := float64(oldStats.Max-oldStats.Min) / float64(states)
+	newMin := math.Min(newStats.Min, oldStats.Min)
+	newMax := math.Max(newStats.Max, oldStats.Max)
+
+	changeKey := func(key string) (string, bool) {
+		jointStates, err := models.ParseJointKey(key)
+		if err != nil || component >= len(jointStates) {
+			return "", false
+		}
+		value := float64(jointStates[component])*oldStateStepSize + oldStats.Min
+		newState := ClosestDiscretize(value, states, newMin, newMax)
+		if newState.Value < 0 || newState.Value >= int64(states) {
+			return "", false
+		}
+		jointStates[component] = newState.Value
+		return models.JointKey(jointStates), true
+	}
+
+	targetMatrix := make(map[string]map[string]int64)
+	for key, nextCounts := range jointMatrix {
+		newKey, ok := changeKey(key)
+		if !ok {
+			continue
+		}
+		if _, exists := targetMatrix[newKey]; !exists {
+			targetMatrix[newKey] = make(map[string]int64)
+		}
+		for nextKey, n := range nextCounts {
+			newNextKey, ok := changeKey(nextKey)
+			if !ok {
+				continue
+			}
+			targetMatrix[newKey][newNextKey] += n
+		}
+	}
+	return targetMatrix
+}
+
+// ChangeStateStatsDimension moves the given per state statistics from stats
+// oldStats to the states of the new shape specified in newStats, by the
+// average value of each state
+func ChangeStateStatsDimension(stateStats []models.TSStats, oldStats models.TSStats, newStats models.TSStats, states int) []models.TSStats {
+	newMin := math.Min(newStats.Min, oldStats.Min)
+	newMax := math.Max(newStats.Max, oldStats.Max)
+
+	targetStats := make([]models.TSStats, states)
+	for _, stats := range stateStats {
+		if stats.Count <= 0 {
+			continue
+		}
+		newState := ClosestDiscretize(stats.Avg, states, newMin, newMax)
+		if newState.Value < 0 || newState.Value >= int64(states) {
+			continue
+		}
+		targetStats[newState.Value] = MergeStats(targetStats[newState.Value], stats)
+	}
+	return targetStats
+}
+
+// ChangeDwellDimension moves the given per state dwell time histograms from
+// stats oldStats to the states of the new shape specified in newStats, summing
+// up the histograms of states moved to the same state
+func ChangeDwellDimension(dwellTimes [][]int64, oldStats models.TSStats, newStats models.TSStats, states int) [][]int64 {
+	oldStateStepSize := float64(oldStats.Max-oldStats.Min) / float64(states)
+	newMin := math.Min(newStats.Min, oldStats.Min)
+	newMax := math.Max(newStats.Max, oldStats.Max)
+
+	targetDwellTimes := make([][]int64, states)
+	for state, histogram := range dwellTimes {
+		if len(histogram) == 0 {
+			continue
+		}
+		value := float64(state)*oldStateStepSize + oldStats.Min
+		newState := ClosestDiscretize(value, states, newMin, newMax)
+		if newState.Value < 0 || newState.Value >= int64(states) {
+			continue
+		}
+		targetDwellTimes[newState.Value] = MergeHistograms(targetDwellTimes[newState.Value], histogram)
+	}
+	return targetDwellTimes
+}
+
+// MergeHistograms returns the sum of the histograms x and y
+func MergeHistograms(x []int64, y []int64) []int64 {
+	merged := make([]int64, len(x))
+	copy(merged, x)
+	for i, n := range y {
+		if i >= len(merged) {
+			merged = append(merged, 0)
+		}
+		merged[i] += n
+	}
+	return merged
+}
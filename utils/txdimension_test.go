@@ -97,3 +97,63 @@ func TestChangeDimension(t *testing.T) {
 		)
 	})
 }
+
+func TestChangeJointDimension(t *testing.T) {
+	Convey("Should ChangeJointDimension of a single metric correctly", t, func() {
+		So(
+			ChangeJointDimension(map[string]map[string]int64{
+				"0,3": {"1,3": 5, "1,0": 2},
+			}, 1, models.TSStats{
+				Min: 50, Max: 55,
+			}, models.TSStats{
+				Min: 0, Max: 100,
+			}, 4),
+			ShouldResemble,
+			map[string]map[string]int64{
+				"0,2": {"1,2": 7},
+			},
+		)
+	})
+}
+
+func TestChangeStateStatsDimension(t *testing.T) {
+	Convey("Should move state stats to the states of the new shape", t, func() {
+		stateStats := ChangeStateStatsDimension([]models.TSStats{
+			{Min: 0, Max: 10, Avg: 5, Count: 2},
+			{Min: 10, Max: 20, Avg: 15, Count: 2},
+			{},
+			{Min: 70, Max: 80, Avg: 75, Count: 1},
+		}, models.TSStats{
+			Min: 0, Max: 80,
+		}, models.TSStats{
+			Min: 0, Max: 160,
+		}, 4)
+		So(stateStats, ShouldHaveLength, 4)
+		So(stateStats[0].Count, ShouldEqual, 4)
+		So(stateStats[0].Avg, ShouldEqual, 10)
+		So(stateStats[1].Count, ShouldEqual, 0)
+		So(stateStats[2].Avg, ShouldEqual, 75)
+	})
+}
+
+func TestChangeDwellDimension(t *testing.T) {
+	Convey("Should move dwell times to the states of the new shape", t, func() {
+		dwellTimes := ChangeDwellDimension([][]int64{
+			{0, 2},
+			{1},
+			nil,
+			{3, 0, 1},
+		}, models.TSStats{
+			Min: 0, Max: 80,
+		}, models.TSStats{
+			Min: 0, Max: 320,
+		}, 4)
+		So(dwellTimes, ShouldResemble, [][]int64{
+			{1, 2},
+			{3, 0, 1},
+			nil,
+			nil,
+		})
+		So(MergeHistograms([]int64{1}, []int64{0, 2}), ShouldResemble, []int64{1, 2})
+	})
+}